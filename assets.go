@@ -0,0 +1,67 @@
+package main
+
+import (
+  "bytes"
+  "compress/gzip"
+  "crypto/sha256"
+  "encoding/hex"
+  "fmt"
+  "os"
+  "path/filepath"
+)
+
+// storePhoto writes a recipe's photo into the images folder, shrinking it
+// first if asked to, and returns its path relative to the output directory.
+//
+// With AssetStore photos are named after a hash of their content, so a photo
+// shared by many recipes (or unchanged between runs) is only stored once. With
+// GzipOriginals every photo but the first, which are rarely looked at, is kept
+// gzipped when that actually saves space.
+func (c *Converter) storePhoto(r Recipe, index int, content []byte, ext string) (string, error) {
+  if c.Images.enabled() {
+    // a photo we can't decode is still worth copying as is
+    if resized, err := ResizePhoto(content, ext, c.Images); err != nil {
+      c.emit(ConversionWarning, r, fmt.Sprintf("photo %d not resized: %s", index+1, err))
+    } else {
+      content = resized
+    }
+  }
+
+  name := photoName(r, index, ext)
+  if c.AssetStore {
+    sum := sha256.Sum256(content)
+    name = hex.EncodeToString(sum[:])[:32] + ext
+  }
+
+  if c.GzipOriginals && index > 0 {
+    if compressed, err := gzipContent(content); err != nil {
+      return "", err
+    } else if len(compressed) < len(content)*9/10 {
+      content = compressed
+      name += ".gz"
+    }
+  }
+
+  path := filepath.Join(c.ImagesDir, name)
+  if c.AssetStore {
+    if _, err := os.Stat(filepath.Join(c.OutputDir, path)); err == nil {
+      return path, nil
+    }
+  }
+  return path, os.WriteFile(filepath.Join(c.OutputDir, path), content, 0644)
+}
+
+func gzipContent(content []byte) ([]byte, error) {
+  var buffer bytes.Buffer
+  writer, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+  if err != nil {
+    return nil, err
+  }
+  if _, err := writer.Write(content); err != nil {
+    return nil, err
+  }
+  if err := writer.Close(); err != nil {
+    return nil, err
+  }
+  return buffer.Bytes(), nil
+}
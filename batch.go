@@ -0,0 +1,342 @@
+package main
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+)
+
+// SourceSpec is one export to convert with ConvertAll.
+type SourceSpec struct {
+  // Name identifies the source in errors.
+  Name string
+  Reader io.Reader
+  // Paths, used instead of Reader, are the files of an export with one HTML
+  // file per recipe. Their photos are found next to each file.
+  Paths []string
+  // CSV marks Reader as a CSV export, see ScrapeCSVExport. Other inputs are
+  // told apart by sniffing, see ScrapeExport.
+  CSV bool
+  // ExportDir is where the export's relative photo paths are resolved from.
+  ExportDir string
+  // Configure, when set, adjusts the extraction options (Normalizer,
+  // Transforms, DefaultYields, Classifier, ...) for this source only, e.g. for
+  // an export written in another language.
+  Configure func(*Converter)
+}
+
+// SinkSpec is where ConvertAll writes to. Converter carries the output
+// options, OutputDir included.
+type SinkSpec struct {
+  Converter *Converter
+}
+
+// Report sums up a ConvertAll run. Events holds everything but the
+// discovered, converted and image events.
+type Report struct {
+  Discovered int
+  Converted int
+  Skipped int
+  Events []ProgressEvent
+}
+
+// ConvertAll converts several exports into one output tree. Recipes found in
+// more than one source are written once, the last source wins, and duplicate
+// handling then works across all of them.
+func ConvertAll(ctx context.Context, sources []SourceSpec, sink SinkSpec) (Report, error) {
+  reports, err := ConvertAllTo(ctx, sources, []SinkSpec{sink})
+  return reports[0], err
+}
+
+// ConvertAllTo is ConvertAll writing to several sinks, each with its own
+// options, while reading and parsing every source just once.
+func ConvertAllTo(ctx context.Context, sources []SourceSpec, sinks []SinkSpec) ([]Report, error) {
+  reports := make([]Report, len(sinks))
+  scraped := make([]scrapedSource, 0, len(sources))
+  for _, source := range sources {
+    recipes, version, err := source.scrape()
+    if err != nil {
+      return reports, fmt.Errorf("%s: %w", source.Name, err)
+    }
+    scraped = append(scraped, scrapedSource{source, recipes, version})
+  }
+
+  for i, sink := range sinks {
+    var err error
+    if reports[i], err = convertToSink(ctx, scraped, sink); err != nil {
+      return reports, err
+    }
+  }
+  return reports, nil
+}
+
+func (s SourceSpec) scrape() ([]Recipe, ExportVersion, error) {
+  if len(s.Paths) > 0 {
+    return scrapeExportFiles(s.Paths)
+  }
+  if s.CSV {
+    recipes, err := ScrapeCSVExport(s.Reader)
+    return recipes, ExportCSV, err
+  }
+  return ScrapeExport(s.Reader)
+}
+
+type scrapedSource struct {
+  SourceSpec
+  recipes []Recipe
+  version ExportVersion
+}
+
+func convertToSink(ctx context.Context, sources []scrapedSource, sink SinkSpec) (Report, error) {
+  report := Report{Events: make([]ProgressEvent, 0)}
+  c := sink.Converter
+  if c == nil {
+    c = NewConverter()
+  }
+
+  forward := c.Progress
+  progress := make(chan ProgressEvent)
+  var wg sync.WaitGroup
+  wg.Add(1)
+  go func() {
+    defer wg.Done()
+    for event := range progress {
+      switch event.Kind {
+      case RecipeDiscovered:
+        report.Discovered++
+      case RecipeConverted:
+        report.Converted++
+      case ImageProcessed:
+      case RecipeSkipped:
+        report.Skipped++
+        report.Events = append(report.Events, event)
+      default:
+        report.Events = append(report.Events, event)
+      }
+      if forward != nil {
+        forward <- event
+      }
+    }
+  }()
+
+  sinkConverter := *c
+  sinkConverter.Progress = progress
+  err := convertSources(ctx, sources, &sinkConverter)
+  close(progress)
+  wg.Wait()
+  return report, err
+}
+
+func convertSources(ctx context.Context, sources []scrapedSource, sink *Converter) error {
+  if err := CheckWritable(sink.OutputDir); err != nil {
+    return err
+  }
+
+  recipes := make([]Recipe, 0)
+  byUUID := map[string]int{}
+  for _, source := range sources {
+    if err := ctx.Err(); err != nil {
+      return err
+    }
+
+    converter := *sink
+    converter.ExportDir = source.ExportDir
+    if converter.ExportDir == "" {
+      converter.ExportDir = "."
+    }
+    if source.Configure != nil {
+      source.Configure(&converter)
+    }
+
+    // every sink edits its own copy
+    copies := make([]Recipe, 0, len(source.recipes))
+    for _, recipe := range source.recipes {
+      copies = append(copies, recipe.clone())
+    }
+    converter.emit(ExportDetected, Recipe{}, fmt.Sprintf("%s: %s, %d recipe(s)", source.Name, source.version, len(source.recipes)))
+    extracted, err := converter.prepare(copies)
+    if err != nil {
+      return fmt.Errorf("%s: %w", source.Name, err)
+    }
+    for _, recipe := range extracted {
+      if i, exists := byUUID[recipe.Metadata.UUID]; exists {
+        recipes[i] = recipe
+        continue
+      }
+      byUUID[recipe.Metadata.UUID] = len(recipes)
+      recipes = append(recipes, recipe)
+    }
+  }
+
+  return sink.writeAll(ctx, recipes)
+}
+
+func (r Recipe) clone() Recipe {
+  copyList := func(list []string) []string {
+    return append([]string(nil), list...)
+  }
+  r.PhotoPaths = copyList(r.PhotoPaths)
+  r.IngredientLines = copyList(r.IngredientLines)
+  r.InstructionLines = copyList(r.InstructionLines)
+  r.NotesLines = copyList(r.NotesLines)
+  r.MakeAhead = copyList(r.MakeAhead)
+  r.Metadata.CategoryList = copyList(r.Metadata.CategoryList)
+  r.Metadata.CourseList = copyList(r.Metadata.CourseList)
+  r.Metadata.CollectionList = copyList(r.Metadata.CollectionList)
+  r.Metadata.Unparsed = copyList(r.Metadata.Unparsed)
+  r.Links = append([]RecipeLink(nil), r.Links...)
+  r.Ingredients = append([]Ingredient(nil), r.Ingredients...)
+  return r
+}
+
+// ExpandExportPaths expands shell-style globs like exports/*.html among the
+// paths, for shells (and config files) that don't. A glob matching nothing
+// is an error, so a typo doesn't quietly convert nothing.
+func ExpandExportPaths(paths []string) ([]string, error) {
+  expanded := make([]string, 0, len(paths))
+  for _, path := range paths {
+    if !strings.ContainsAny(path, "*?[") {
+      expanded = append(expanded, path)
+      continue
+    }
+    matches, err := filepath.Glob(path)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    if len(matches) == 0 {
+      return nil, fmt.Errorf("%s: no such files", path)
+    }
+    expanded = append(expanded, matches...)
+  }
+  return expanded, nil
+}
+
+// OpenExports opens export files, or folders or zips of them, as sources for
+// ConvertAll. They are ordered oldest first, so where exports share recipes
+// the newest export wins. The returned function closes the files and removes
+// unpacked zips again.
+func OpenExports(paths []string) ([]SourceSpec, func(), error) {
+  sources := make([]SourceSpec, 0, len(paths))
+  modTimes := map[string]time.Time{}
+  cleanups := make([]func(), 0)
+  cleanup := func() {
+    for _, fn := range cleanups {
+      fn()
+    }
+  }
+
+  for _, path := range paths {
+    path = exportFile(path)
+    info, err := os.Stat(path)
+    if err != nil {
+      cleanup()
+      return nil, nil, err
+    }
+
+    if info.IsDir() {
+      // no recipes.html, so an export with one file per recipe
+      files, err := recipeFiles(path)
+      if err != nil {
+        cleanup()
+        return nil, nil, err
+      }
+      modTimes[path] = info.ModTime()
+      sources = append(sources, SourceSpec{Name: path, Paths: files, ExportDir: path})
+      continue
+    }
+
+    exportPath := path
+    if isZipFile(path) {
+      dir, err := os.MkdirTemp("", "recipekeeper2recipemd-")
+      if err != nil {
+        cleanup()
+        return nil, nil, err
+      }
+      cleanups = append(cleanups, func() { os.RemoveAll(dir) })
+      if exportPath, err = extractExport(path, dir, 0); err != nil {
+        cleanup()
+        return nil, nil, fmt.Errorf("%s: %w", path, err)
+      }
+    }
+
+    file, err := os.Open(exportPath)
+    if err != nil {
+      cleanup()
+      return nil, nil, err
+    }
+    cleanups = append(cleanups, func() { file.Close() })
+
+    modTimes[path] = info.ModTime()
+    sources = append(sources, SourceSpec{Name: path, Reader: file, ExportDir: filepath.Dir(exportPath), CSV: strings.EqualFold(filepath.Ext(exportPath), ".csv")})
+  }
+
+  sort.SliceStable(sources, func(i, j int) bool {
+    return modTimes[sources[i].Name].Before(modTimes[sources[j].Name])
+  })
+  return sources, cleanup, nil
+}
+
+// recipeFiles lists the HTML files in dir and its subfolders.
+func recipeFiles(dir string) ([]string, error) {
+  files := make([]string, 0)
+  err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".html", ".htm":
+      if !d.IsDir() {
+        files = append(files, path)
+      }
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  if len(files) == 0 {
+    return nil, fmt.Errorf("%s: %w: the folder has neither a recipes.html nor per recipe HTML files", dir, ErrNoRecipes)
+  }
+  return files, nil
+}
+
+// scrapeExportFiles scrapes an export with one HTML file per recipe. Files
+// without recipes, like an index page, are skipped.
+func scrapeExportFiles(paths []string) ([]Recipe, ExportVersion, error) {
+  recipes := make([]Recipe, 0, len(paths))
+  version := ExportUnknown
+  for _, path := range paths {
+    file, err := os.Open(path)
+    if err != nil {
+      return nil, version, err
+    }
+    found, fileVersion, err := ScrapeExport(file)
+    file.Close()
+    if errors.Is(err, ErrNoRecipes) {
+      continue
+    } else if err != nil {
+      return nil, version, fmt.Errorf("%s: %w", path, err)
+    }
+
+    if version == ExportUnknown {
+      version = fileVersion
+    }
+    for _, recipe := range found {
+      recipe.exportDir = filepath.Dir(path)
+      recipes = append(recipes, recipe)
+    }
+  }
+
+  if len(recipes) == 0 {
+    return nil, version, fmt.Errorf("%w: none of the %d HTML files has a recipe in it", ErrNoRecipes, len(paths))
+  }
+  return recipes, version, nil
+}
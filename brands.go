@@ -0,0 +1,108 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "regexp"
+  "sort"
+  "strings"
+)
+
+// Brands removes brand names from ingredient lines, so "8 oz Kraft shredded
+// cheddar" becomes "8 oz shredded cheddar (Kraft)". The brand is kept as the
+// ingredient's note and the name is left to merge in shopping lists.
+type Brands struct {
+  re *regexp.Regexp
+}
+
+func DefaultBrandNames() []string {
+  return []string{
+    "Kraft", "Heinz", "Hellmann's", "Best Foods", "Philadelphia", "Campbell's",
+    "Pillsbury", "Betty Crocker", "Duncan Hines", "Nestlé", "Nestle", "Toll House",
+    "Hershey's", "Ghirardelli", "Domino", "C&H", "Gold Medal",
+    "King Arthur", "Land O'Lakes", "Kerrygold", "Tillamook", "Cabot", "Velveeta",
+    "Knorr", "Maggi", "Swanson", "Kikkoman", "Lea & Perrins", "Tabasco", "Frank's",
+    "Huy Fong", "McCormick", "Morton", "Diamond Crystal",
+    "Barilla", "De Cecco", "Mutti", "Cento", "Hunt's", "Del Monte", "Goya",
+    "Old El Paso", "Rotel", "Ro-Tel", "Jell-O", "Cool Whip", "Ritz", "Oreo",
+    "Nabisco", "Quaker", "Kellogg's", "Rice Krispies", "Bisquick", "Crisco",
+    "Karo", "Mazola", "Fleischmann's", "Red Star", "Bob's Red Mill", "Arm & Hammer",
+    "Clabber Girl", "Rumford", "Carnation", "Eagle Brand", "Libby's", "Dole",
+    "Sun-Maid", "Ocean Spray", "Tropicana", "Minute Maid", "Uncle Ben's",
+    "Ben's Original", "Oscar Mayer", "Hormel", "Spam", "Jimmy Dean", "Johnsonville",
+    "Daisy", "Breakstone's", "Sargento", "Galbani", "Polly-O", "Stouffer's",
+    "Lipton", "Hidden Valley", "Miracle Whip", "Grey Poupon", "French's",
+  }
+}
+
+// NewBrands matches the given brand names, ignoring case and the ® and ™
+// signs after them.
+func NewBrands(names []string) *Brands {
+  names = append([]string(nil), names...)
+  // longer names first, so "Huy Fong" is not cut short by a shorter brand
+  sort.Slice(names, func(i, j int) bool {
+    return len(names[i]) > len(names[j])
+  })
+  patterns := make([]string, 0, len(names))
+  for _, name := range names {
+    if name = strings.TrimSpace(name); name != "" {
+      patterns = append(patterns, regexp.QuoteMeta(name))
+    }
+  }
+  if len(patterns) == 0 {
+    return &Brands{}
+  }
+  return &Brands{regexp.MustCompile(`(?i)(^|[\s(])(` + strings.Join(patterns, "|") + `)(?:'s|’s)?[®™]?\s+`)}
+}
+
+// LoadBrands reads a JSON list of brand names, used along with the defaults.
+func LoadBrands(path string) (*Brands, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+
+  names := make([]string, 0)
+  if err := json.Unmarshal(content, &names); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+  return NewBrands(append(DefaultBrandNames(), names...)), nil
+}
+
+// Strip removes the brands from an ingredient line and adds them in
+// parentheses at the end. Lines that would be left without a name, like
+// "1 jar Nutella", stay as they are.
+func (b *Brands) Strip(line string) string {
+  if b == nil || b.re == nil {
+    return line
+  }
+
+  brands := make([]string, 0)
+  stripped := b.re.ReplaceAllStringFunc(line, func(match string) string {
+    groups := b.re.FindStringSubmatch(match)
+    brands = append(brands, groups[2])
+    return groups[1]
+  })
+  if len(brands) == 0 || ParseIngredient(stripped).Name == "" {
+    return line
+  }
+  return strings.TrimSpace(stripped) + " (" + strings.Join(brands, ", ") + ")"
+}
+
+// StripBrands removes the brands from the recipe's ingredient lines.
+func StripBrands(r Recipe, brands *Brands) Recipe {
+  if brands == nil {
+    return r
+  }
+  lines := make([]string, len(r.IngredientLines))
+  for i, line := range r.IngredientLines {
+    if IsSectionHeading(line) {
+      lines[i] = line
+    } else {
+      lines[i] = brands.Strip(line)
+    }
+  }
+  r.IngredientLines = lines
+  return r
+}
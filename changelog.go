@@ -0,0 +1,129 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "strings"
+)
+
+// A per recipe changelog, so that when a re-import changes a recipe there is a
+// record of what changed and when. The history lives in the manifest, and each
+// entry is worked out by comparing the file rendered last time (the merge
+// base) with the new one.
+
+const changelogLength = 10
+
+type ChangeEntry struct {
+  Date string `json:"date"`
+  Summary string `json:"summary"`
+}
+
+// DescribeChanges summarizes the differences between two versions of a recipe,
+// e.g. "ingredients (+1 -1), instructions".
+func DescribeChanges(before Recipe, after Recipe) string {
+  changes := make([]string, 0)
+  changed := func(name string, differs bool) {
+    if differs {
+      changes = append(changes, name)
+    }
+  }
+  changedList := func(name string, before []string, after []string) {
+    added, removed := listDiff(before, after)
+    switch {
+    case added > 0 || removed > 0:
+      changes = append(changes, fmt.Sprintf("%s (+%d -%d)", name, added, removed))
+    case !equalLines(before, after):
+      changes = append(changes, name+" (reordered)")
+    }
+  }
+
+  changed("title", before.Title != after.Title)
+  changed("description", before.Description != after.Description)
+  changed("yield", before.Metadata.Yield != after.Metadata.Yield)
+  changedList("tags", before.Metadata.CategoryList, after.Metadata.CategoryList)
+  changedList("ingredients", before.IngredientLines, after.IngredientLines)
+  changedList("instructions", before.InstructionLines, after.InstructionLines)
+  changedList("notes", before.NotesLines, after.NotesLines)
+  changedList("photos", before.PhotoPaths, after.PhotoPaths)
+  changed("nutrition", before.Nutrition != after.Nutrition)
+  return strings.Join(changes, ", ")
+}
+
+// listDiff counts the lines only in after (added) and only in before (removed).
+func listDiff(before []string, after []string) (int, int) {
+  counts := map[string]int{}
+  for _, line := range before {
+    counts[line]++
+  }
+  added := 0
+  for _, line := range after {
+    if counts[line] > 0 {
+      counts[line]--
+    } else {
+      added++
+    }
+  }
+  removed := 0
+  for _, count := range counts {
+    removed += count
+  }
+  return added, removed
+}
+
+// changelog returns the recipe's history including this run, newest first.
+func (c *Converter) changelog(recipe Recipe, rendered []byte, manifest *Manifest) []ChangeEntry {
+  date := manifest.GeneratedAt.Format("2006-01-02")
+  if c.previous == nil {
+    return []ChangeEntry{{date, "converted"}}
+  }
+  entry, exists := c.previous.Recipes[recipe.Metadata.UUID]
+  if !exists {
+    return []ChangeEntry{{date, "converted"}}
+  }
+
+  history := entry.Changes
+  base, err := LoadBase(c.OutputDir, entry.Hash)
+  if err != nil {
+    return history
+  }
+  labels := c.formatOptions().Labels
+  before, err := ParseRecipeMDLabels(bytes.NewReader(base), labels)
+  if err != nil {
+    return history
+  }
+  after, err := ParseRecipeMDLabels(bytes.NewReader(rendered), labels)
+  if err != nil {
+    return history
+  }
+
+  if summary := DescribeChanges(before, after); summary != "" {
+    history = append([]ChangeEntry{{date, summary}}, history...)
+  }
+  if len(history) > changelogLength {
+    history = history[:changelogLength]
+  }
+  return history
+}
+
+func formatChangelog(history []ChangeEntry, options FormatOptions) string {
+  var output strings.Builder
+  output.WriteString("\n" + options.heading("Changelog") + "\n\n")
+  for _, entry := range history {
+    output.WriteString(fmt.Sprintf("- %s: %s\n", entry.Date, entry.Summary))
+  }
+  return output.String()
+}
+
+// formatOptions are the options the recipes are rendered with, for the bits
+// the converter adds itself.
+func (c *Converter) formatOptions() FormatOptions {
+  switch renderer := c.Renderer.(type) {
+  case RecipeMDRenderer:
+    return renderer.Options
+  case SiteRenderer:
+    return renderer.Options
+  case TemplateRenderer:
+    return renderer.Options
+  }
+  return FormatOptions{}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Which characters are allowed in each kind of output. Keeping unicode in the
+// recipes themselves while making file names and URLs plain ASCII is a common
+// wish, e.g. for old sync tools or web servers.
+
+type Charset string
+
+const (
+  CharsetUnicode Charset = "unicode"
+  CharsetASCII Charset = "ascii"
+)
+
+type CharsetPolicy struct {
+  // FileNames covers the names of files and folders we create.
+  FileNames Charset
+  // Content covers the text of the recipes.
+  Content Charset
+  // URLs covers generated links and anchors: tags, site slugs and sections.
+  URLs Charset
+}
+
+func DefaultCharsetPolicy() CharsetPolicy {
+  return CharsetPolicy{CharsetUnicode, CharsetUnicode, CharsetUnicode}
+}
+
+func ParseCharset(name string) (Charset, error) {
+  switch charset := Charset(name); charset {
+  case CharsetUnicode, CharsetASCII:
+    return charset, nil
+  }
+  return "", fmt.Errorf("unknown charset %q, expected unicode or ascii", name)
+}
+
+func (c Charset) Apply(text string) string {
+  if c == CharsetASCII {
+    return ToASCII(text)
+  }
+  return text
+}
+
+// Latin letters with diacritics and ligatures, spelled the way they usually
+// are without them.
+var asciiTransliterations = map[rune]string{}
+
+func init() {
+  for ascii, letters := range map[string]string{
+    "a": "àáâãäåāăą", "A": "ÀÁÂÃÄÅĀĂĄ",
+    "c": "çćĉċč", "C": "ÇĆĈĊČ",
+    "d": "ďđð", "D": "ĎĐÐ",
+    "e": "èéêëēĕėęě", "E": "ÈÉÊËĒĔĖĘĚ",
+    "g": "ĝğġģ", "G": "ĜĞĠĢ",
+    "i": "ìíîïĩīĭįı", "I": "ÌÍÎÏĨĪĬĮİ",
+    "l": "ĺļľŀł", "L": "ĹĻĽĿŁ",
+    "n": "ñńņňŉ", "N": "ÑŃŅŇ",
+    "o": "òóôõöøōŏő", "O": "ÒÓÔÕÖØŌŎŐ",
+    "r": "ŕŗř", "R": "ŔŖŘ",
+    "s": "śŝşš", "S": "ŚŜŞŠ",
+    "t": "ţťŧ", "T": "ŢŤŦ",
+    "u": "ùúûüũūŭůűų", "U": "ÙÚÛÜŨŪŬŮŰŲ",
+    "y": "ýÿŷ", "Y": "ÝŸŶ",
+    "z": "źżž", "Z": "ŹŻŽ",
+  } {
+    for _, r := range letters {
+      asciiTransliterations[r] = ascii
+    }
+  }
+  for r, ascii := range map[rune]string{
+    'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'þ': "th", 'Þ': "Th",
+  } {
+    asciiTransliterations[r] = ascii
+  }
+}
+
+// ToASCII transliterates accented letters and drops whatever else isn't
+// ASCII. The text normalizer has usually dealt with the punctuation already.
+func ToASCII(text string) string {
+  var output strings.Builder
+  for _, r := range text {
+    if r < 0x80 {
+      output.WriteRune(r)
+    } else if ascii, exists := asciiTransliterations[r]; exists {
+      output.WriteString(ascii)
+    } else if fraction, exists := fractions[r]; exists {
+      output.WriteString(fraction)
+    } else if replacement, exists := defaultReplacements[r]; exists && replacement != "" {
+      output.WriteString(replacement)
+    }
+  }
+  return output.String()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+  "fmt"
+  "html"
+  "net/url"
+  "regexp"
+  "sort"
+  "strings"
+)
+
+// Recipe Keeper only gives us a free text source, so we pull out what we can:
+// a URL (and its site), or a book with an optional author and page.
+type Citation struct {
+  Site string
+  Author string
+  URL string
+  Book string
+  Page string
+}
+
+var citationPageRe = regexp.MustCompile(`(?i)[,;]?\s*\b(?:pp?|pg|pages?)\.?\s*(\d+(?:\s*[-–]\s*\d+)?)\s*$`)
+var citationAuthorRe = regexp.MustCompile(`(?i)^(.+?)\s+by\s+(.+)$`)
+
+func ParseCitation(source string) Citation {
+  citation := Citation{}
+  source = strings.TrimSpace(source)
+  if source == "" {
+    return citation
+  }
+
+  if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+    citation.URL = source
+    citation.Site = strings.TrimPrefix(u.Hostname(), "www.")
+    return citation
+  }
+
+  if matches := citationPageRe.FindStringSubmatch(source); matches != nil {
+    citation.Page = strings.ReplaceAll(matches[1], " ", "")
+    source = strings.TrimSpace(source[:len(source)-len(matches[0])])
+  }
+
+  if matches := citationAuthorRe.FindStringSubmatch(source); matches != nil {
+    citation.Book = strings.TrimSpace(matches[1])
+    citation.Author = strings.TrimSpace(matches[2])
+  } else {
+    citation.Book = source
+  }
+
+  return citation
+}
+
+func (c Citation) IsEmpty() bool {
+  return c == Citation{}
+}
+
+func (c Citation) String() string {
+  if c.URL != "" {
+    return fmt.Sprintf("%s, <%s>", c.Site, c.URL)
+  }
+  return c.join(plainText, func(book string) string { return "*" + book + "*" })
+}
+
+// Text is the citation without markup, for PDFs.
+func (c Citation) Text() string {
+  if c.URL != "" {
+    return c.Site + ", " + c.URL
+  }
+  return c.join(plainText, plainText)
+}
+
+// HTML is the citation with the book in italics and the site linked.
+func (c Citation) HTML() string {
+  if c.URL != "" {
+    return fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(c.URL), html.EscapeString(c.Site))
+  }
+  return c.join(html.EscapeString, func(book string) string { return "<i>" + html.EscapeString(book) + "</i>" })
+}
+
+func plainText(text string) string {
+  return text
+}
+
+// join lists the author, book and page of a book, the book formatted by book
+// and the rest by escape.
+func (c Citation) join(escape func(string) string, book func(string) string) string {
+  parts := make([]string, 0, 3)
+  if c.Author != "" {
+    parts = append(parts, escape(c.Author))
+  }
+  if c.Book != "" {
+    parts = append(parts, book(c.Book))
+  }
+  if c.Page != "" {
+    parts = append(parts, "p. "+escape(c.Page))
+  }
+  return strings.Join(parts, ", ")
+}
+
+// FormatSource renders URL sources as a Markdown link named after the site,
+// and leaves anything else ("Cookbook, p. 42") as plain text.
+func FormatSource(source string) string {
+  citation := ParseCitation(source)
+  if citation.URL == "" {
+    return source
+  }
+  return fmt.Sprintf("[%s](%s)", citation.Site, citation.URL)
+}
+
+var markdownLinkRe = regexp.MustCompile(`^\[[^\]]*\]\(([^)\s]+)\)$`)
+
+// UnformatSource is the inverse of FormatSource.
+func UnformatSource(formatted string) string {
+  if matches := markdownLinkRe.FindStringSubmatch(formatted); matches != nil {
+    return matches[1]
+  }
+  return formatted
+}
+
+// Citations numbers the distinct sources of a set of recipes so that collected
+// outputs (cookbooks, sites) can mark each recipe and print a credits section.
+type Citations struct {
+  entries []Citation
+  markers map[string]int
+  recipes map[int][]string
+}
+
+func NewCitations(recipes []Recipe) *Citations {
+  c := &Citations{markers: map[string]int{}, recipes: map[int][]string{}}
+
+  for _, recipe := range recipes {
+    citation := ParseCitation(recipe.Metadata.Source)
+    if citation.IsEmpty() {
+      continue
+    }
+
+    key := citation.String()
+    marker, exists := c.markers[key]
+    if !exists {
+      c.entries = append(c.entries, citation)
+      marker = len(c.entries)
+      c.markers[key] = marker
+    }
+    c.recipes[marker] = append(c.recipes[marker], recipe.Title)
+  }
+
+  return c
+}
+
+// Marker returns the citation number for a recipe, or 0 if it has no source
+// or there are no citations.
+func (c *Citations) Marker(r Recipe) int {
+  if c == nil {
+    return 0
+  }
+  citation := ParseCitation(r.Metadata.Source)
+  if citation.IsEmpty() {
+    return 0
+  }
+  return c.markers[citation.String()]
+}
+
+func (c *Citations) Len() int {
+  return len(c.entries)
+}
+
+// Credit is a numbered source and the titles of the recipes taken from it.
+type Credit struct {
+  Marker int
+  Citation Citation
+  Titles []string
+}
+
+// Credits lists the sources in order of their markers.
+func (c *Citations) Credits() []Credit {
+  credits := make([]Credit, 0, len(c.entries))
+  for i, citation := range c.entries {
+    titles := append([]string(nil), c.recipes[i+1]...)
+    sort.Strings(titles)
+    credits = append(credits, Credit{i + 1, citation, titles})
+  }
+  return credits
+}
+
+// FormatCredits is the credits section in Markdown.
+func (c *Citations) FormatCredits(options FormatOptions) string {
+  if len(c.entries) == 0 {
+    return ""
+  }
+
+  var output strings.Builder
+  output.WriteString("## " + options.Labels.get("Credits") + "\n\n")
+  for _, credit := range c.Credits() {
+    output.WriteString(fmt.Sprintf("%d. %s — %s\n", credit.Marker, credit.Citation, strings.Join(credit.Titles, ", ")))
+  }
+  return output.String()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "sort"
+  "strings"
+)
+
+// A ClassifierRule suggests a course or a cuisine for recipes whose title or
+// ingredients mention one of its keywords. Title matches count double.
+type ClassifierRule struct {
+  Kind string `json:"kind"`
+  Tag string `json:"tag"`
+  Keywords []string `json:"keywords"`
+}
+
+const (
+  inferCourse = "course"
+  inferCuisine = "cuisine"
+)
+
+// a tag needs at least a title match, or two ingredient matches, to be used
+const minInferenceScore = 2
+
+func DefaultClassifierRules() []ClassifierRule {
+  return []ClassifierRule{
+    {inferCourse, "Dessert", []string{"cake", "cookie", "cookies", "brownie", "brownies", "pie", "tart", "pudding", "ice cream", "cheesecake", "frosting", "icing"}},
+    {inferCourse, "Breakfast", []string{"pancake", "pancakes", "waffle", "waffles", "omelette", "omelet", "granola", "porridge", "oatmeal", "muffin", "muffins"}},
+    {inferCourse, "Soup", []string{"soup", "stew", "chowder", "broth", "bisque"}},
+    {inferCourse, "Salad", []string{"salad", "slaw", "vinaigrette"}},
+    {inferCourse, "Bread", []string{"bread", "loaf", "focaccia", "baguette", "rolls", "sourdough"}},
+    {inferCourse, "Drink", []string{"cocktail", "smoothie", "lemonade", "punch", "latte", "vodka", "gin", "rum", "tequila"}},
+    {inferCourse, "Side Dish", []string{"side", "roasted vegetables", "mashed potatoes", "pilaf"}},
+    {inferCourse, "Main Dish", []string{"chicken", "beef", "pork", "lamb", "salmon", "curry", "lasagna", "casserole", "roast"}},
+    {inferCuisine, "Italian", []string{"pasta", "risotto", "parmesan", "mozzarella", "pesto", "lasagna", "pizza", "gnocchi", "basil"}},
+    {inferCuisine, "Mexican", []string{"tortilla", "tortillas", "taco", "tacos", "enchilada", "salsa", "jalapeno", "chipotle", "quesadilla"}},
+    {inferCuisine, "Indian", []string{"garam masala", "curry", "paneer", "ghee", "turmeric", "naan", "dal", "masala", "tikka"}},
+    {inferCuisine, "Chinese", []string{"soy sauce", "hoisin", "bok choy", "wok", "five spice", "szechuan", "dumplings"}},
+    {inferCuisine, "Japanese", []string{"miso", "mirin", "sake", "nori", "wasabi", "teriyaki", "dashi", "ramen"}},
+    {inferCuisine, "Thai", []string{"fish sauce", "lemongrass", "coconut milk", "thai basil", "galangal", "pad thai"}},
+    {inferCuisine, "French", []string{"gruyere", "dijon", "bechamel", "creme fraiche", "baguette", "gratin"}},
+  }
+}
+
+// LoadClassifierRules reads a JSON list of rules used instead of the defaults.
+func LoadClassifierRules(path string) ([]ClassifierRule, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+
+  rules := make([]ClassifierRule, 0)
+  if err := json.Unmarshal(content, &rules); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+  for _, rule := range rules {
+    if rule.Kind != inferCourse && rule.Kind != inferCuisine {
+      return nil, fmt.Errorf("%s: rule %q has unknown kind %q", path, rule.Tag, rule.Kind)
+    }
+  }
+  return rules, nil
+}
+
+// An Inference is a tag suggested for a recipe and the keywords behind it.
+type Inference struct {
+  Kind string
+  Tag string
+  Keywords []string
+}
+
+func (i Inference) String() string {
+  return fmt.Sprintf("inferred %s %q from %s", i.Kind, i.Tag, strings.Join(i.Keywords, ", "))
+}
+
+// inferTag returns the best scoring tag of one kind, if any scores enough.
+func inferTag(r Recipe, rules []ClassifierRule, kind string) (Inference, bool) {
+  best := Inference{}
+  bestScore := 0
+
+  for _, rule := range rules {
+    if rule.Kind != kind {
+      continue
+    }
+
+    score := 0
+    matched := make([]string, 0)
+    for _, keyword := range rule.Keywords {
+      re := wordBoundaryRe(keyword)
+      if re.MatchString(r.Title) {
+        score += 2
+        matched = append(matched, keyword)
+        continue
+      }
+      for _, line := range r.IngredientLines {
+        if re.MatchString(line) {
+          score++
+          matched = append(matched, keyword)
+          break
+        }
+      }
+    }
+
+    if score > bestScore {
+      sort.Strings(matched)
+      best = Inference{kind, rule.Tag, matched}
+      bestScore = score
+    }
+  }
+
+  return best, bestScore >= minInferenceScore
+}
+
+// InferTags fills in a missing course and, when there are no categories, a
+// cuisine. The returned inferences say what was added so it can be reviewed.
+func InferTags(r Recipe, rules []ClassifierRule) (Recipe, []Inference) {
+  inferences := make([]Inference, 0)
+
+  if len(r.Metadata.CourseList) == 0 {
+    if inference, ok := inferTag(r, rules, inferCourse); ok {
+      r.Metadata.CourseList = []string{inference.Tag}
+      inferences = append(inferences, inference)
+    }
+  }
+  if len(r.Metadata.CategoryList) == 0 {
+    if inference, ok := inferTag(r, rules, inferCuisine); ok {
+      r.Metadata.CategoryList = []string{inference.Tag}
+      inferences = append(inferences, inference)
+    }
+  }
+
+  return r, inferences
+}
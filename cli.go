@@ -0,0 +1,723 @@
+package main
+
+import (
+  "flag"
+  "context"
+  "errors"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+var commands = map[string]func(args []string) error{
+  "convert": runConvert,
+  "validate": runValidate,
+  "review": runReview,
+  "serve": runServe,
+  "shopping-list": runShoppingList,
+  "copy": runCopy,
+  "fingerprint": runFingerprint,
+  "compare-libraries": runCompareLibraries,
+  "daemon": runDaemon,
+  "search": runSearch,
+  "fetch": runFetch,
+}
+
+// A repeatable key=value flag.
+type mapFlag map[string]string
+
+func (m mapFlag) String() string {
+  pairs := make([]string, 0, len(m))
+  for key, value := range m {
+    pairs = append(pairs, key+"="+value)
+  }
+  sort.Strings(pairs)
+  return strings.Join(pairs, ",")
+}
+
+func (m mapFlag) Set(value string) error {
+  key, val, found := strings.Cut(value, "=")
+  if !found {
+    return fmt.Errorf("expected key=value, got %q", value)
+  }
+  m[strings.TrimSpace(key)] = strings.TrimSpace(val)
+  return nil
+}
+
+func contains(list []string, value string) bool {
+  for _, item := range list {
+    if item == value {
+      return true
+    }
+  }
+  return false
+}
+
+const defaultExportPath = "/home/kalebo/Downloads/RecipeKeeper_20230630_093852/recipes.html"
+
+// convertRun is one conversion set up from the flags, the config file and
+// possibly one of its pipelines.
+type convertRun struct {
+  name string
+  converter *Converter
+  paths []string
+  pipelines []string
+  gitCommit bool
+  watch bool
+  watchInterval time.Duration
+  // report is where to write the ConversionReport, if anywhere
+  report string
+  // formats are the output formats, several for --format all
+  formats []OutputFormat
+}
+
+// forced are options that beat even the command line, those fanOutFormats
+// sets for each format.
+func newConvertRun(args []string, config Config, name string, pipeline map[string]interface{}, forced map[string]interface{}) (*convertRun, error) {
+  flags := flag.NewFlagSet("convert", flag.ExitOnError)
+  flags.String("config", defaultConfigFile, "JSON file with defaults for these flags and named pipelines, see Config")
+  pipelines := flags.String("pipeline", "", "comma separated pipelines from the config file to run, all of them if not given")
+  favoritesOnly := flags.Bool("favorites-only", false, "only convert recipes marked as favourite")
+  onlyTags := flags.String("only-tags", "", "only convert recipes with one of these comma separated tags (categories, collections, or favorite)")
+  output := flags.String("output", outputDir, "folder to write the converted files to")
+  fileMode := flags.String("file-mode", fmt.Sprintf("%o", defaultFileMode), "octal permissions of the written recipe files")
+  verify := flags.Bool("verify", false, "re-read each written file and flag lossy conversions")
+  noNormalize := flags.Bool("no-normalize", false, "keep bullets, smart quotes, etc. as they are in the export")
+  keepFractions := flags.Bool("keep-fractions", false, "keep unicode fractions like ½ instead of writing 1/2")
+  spellDegrees := flags.Bool("spell-degrees", false, "write degree signs as the word, e.g. 350 degrees F")
+  replacements := flags.String("replacements", "", "JSON file of extra character replacements for the text cleanup")
+  skipJunk := flags.Bool("skip-junk", false, "skip empty and placeholder recipes")
+  stamp := flags.Bool("stamp", false, "add a comment with the converter version and config hash to each file")
+  defaultYields := mapFlag{}
+  flags.Var(defaultYields, "default-yield", "`course=yield` to use when a recipe has no yield, e.g. Cocktail=\"1 drink\" (repeatable, * matches any course)")
+  updateFields := flags.String("update-fields", "", "only refresh these comma separated fields ("+strings.Join(updatableFields, ", ")+") in existing files")
+  lint := flags.Bool("lint", false, "report implausible ingredient amounts and times")
+  plausibility := flags.String("plausibility", "", "JSON file with the plausibility ranges used by --lint")
+  downloadPhotos := flags.Bool("download-photos", false, "download photos referenced by URL into the images folder")
+  photoOptions := DefaultPhotoDownloadOptions()
+  flags.DurationVar(&photoOptions.Timeout, "photo-timeout", photoOptions.Timeout, "timeout for each photo download")
+  flags.IntVar(&photoOptions.Retries, "photo-retries", photoOptions.Retries, "how often to retry a failed photo download")
+  flags.Int64Var(&photoOptions.MaxBytes, "photo-max-bytes", photoOptions.MaxBytes, "skip downloaded photos larger than this")
+  layout := flags.String("layout", LayoutFlat, "file layout: flat, collection for one folder per collection, or hugo or jekyll for static site content")
+  aliases := flags.String("aliases", AliasSymlink, "with the collection layout, how recipes appear in their other collections: symlink, stub or none")
+  transforms := flags.String("transforms", "", "JSON file of regex find/replace rules applied to recipe fields")
+  heroImage := flags.Bool("hero-image", false, "show the first photo right below the recipe title")
+  assetStore := flags.Bool("asset-store", false, "name photos by content hash so each distinct photo is stored once")
+  gzipOriginals := flags.Bool("gzip-originals", false, "store all but the first photo of each recipe gzipped")
+  var imageOptions ImageOptions
+  flags.IntVar(&imageOptions.MaxSize, "max-image-size", 0, "shrink copied JPEG and PNG photos so their longest side is at most this many pixels")
+  flags.IntVar(&imageOptions.Quality, "image-quality", 0, "re-encode copied JPEG photos at this quality (1-100)")
+  reference := flags.Bool("reference-validate", false, "check every written file with the reference recipemd tool, if it is installed")
+  index := flags.Bool("index", false, "write an index.md table linking to every recipe")
+  format := flags.String("format", "recipemd", "output format: recipemd, html for a small static site, text for plain text, pdf, cookbook-pdf for all recipes in one PDF, epub for an e-book cookbook, or csv for a single spreadsheet of recipe metadata; a file extension like txt works too, and a comma separated list or all writes each format into a folder of its own")
+  textWidth := flags.Int("text-width", 72, "with --format text, wrap lines longer than this, 0 to not wrap")
+  emphasize := flags.Bool("emphasize-amounts", false, "wrap ingredient amounts and units in * and report lines that couldn't be parsed confidently")
+  normalizeNutrition := flags.Bool("normalize-nutrition", false, "write nutrition values as a number and a standard unit, e.g. 245 kcal and 12 g")
+  highlightIngredients := flags.Bool("highlight-ingredients", false, "put the ingredients in bold wherever the steps mention them")
+  credits := flags.Bool("credits", false, "number the recipe sources and list them in a credits section of the cookbooks and indexes")
+  amountMarkup := flags.Bool("amount-markup", false, "wrap ingredient amounts in a <span> with data-amount and data-unit attributes")
+  minConfidence := flags.Float64("min-confidence", 0.7, "report ingredient lines parsed with less confidence (0-1), and leave them as they are with --emphasize-amounts or --amount-markup")
+  obsidian := flags.Bool("obsidian", false, "write an Obsidian vault: front matter, #tags, [[wiki links]] and photos in attachments/ (same as --compat obsidian)")
+  strict := flags.Bool("strict-recipemd", false, "write only what the RecipeMD spec allows, moving the metadata and notes into the description and leaving out the rest")
+  compat := flags.String("compat", "", "format for the app the files are imported into: recipemd, recipesage or obsidian")
+  reviewState := flags.String("review-state", "", "leave out the recipes excluded with the review command, using its state file")
+  fileTimes := flags.Bool("file-times", false, "set the modification time of each recipe file to when the recipe was last changed, if the export has the dates")
+  prune := flags.Bool("prune", false, "delete the files of recipes converted before that are no longer in the export, unless edited by hand")
+  watch := flags.Bool("watch", false, "keep running and convert again whenever the export changes")
+  watchInterval := flags.Duration("watch-interval", 2*time.Second, "how often --watch checks the export for changes")
+  inferTags := flags.Bool("infer-tags", false, "suggest a course and cuisine for recipes without one, listed in the lint report")
+  classifier := flags.String("classifier", "", "JSON file of keyword rules used by --infer-tags instead of the built in ones")
+  makeAhead := flags.Bool("make-ahead", false, "call out make-ahead steps and long resting times below the description and tag the recipe make-ahead")
+  dividedSplits := flags.Bool("divided-splits", false, "list the parts of divided ingredients used in the steps after them, e.g. 2 cups sugar, divided (1 cup + 1 cup)")
+  stripBrands := flags.Bool("strip-brands", false, "take brand names out of the ingredients, keeping them in parentheses after the name")
+  brands := flags.String("brands", "", "JSON list of brand names removed by --strip-brands along with the built in ones")
+  gitCommit := flags.Bool("git-commit", false, "commit the changed files when the output directory is in a git repository")
+  fileNameCharset := flags.String("filename-charset", string(CharsetUnicode), "characters allowed in file and folder names: unicode or ascii")
+  contentCharset := flags.String("content-charset", string(CharsetUnicode), "characters allowed in the recipe text: unicode or ascii")
+  urlCharset := flags.String("url-charset", string(CharsetUnicode), "characters allowed in tags, slugs and other generated links: unicode or ascii")
+  duplicates := flags.String("duplicates", DuplicatesReport, "what to do with duplicate recipes: report, skip, merge, or suffix their titles")
+  incomplete := flags.String("incomplete", IncompletePlaceholder, "what to do with recipes without a title or ingredients: placeholder to title them after their id, skip, or fail the run")
+  dumpIR := flags.String("dump-ir", "", "write the extracted recipes, before any cleanup, as JSON into this folder")
+  frontmatter := flags.Bool("frontmatter", false, "add a YAML front matter block with the recipe metadata")
+  steps := flags.String("steps", string(StepsPlain), "how to write instruction steps: plain, numbered or bulleted")
+  ratingStyle := flags.String("rating", string(RatingProse), "how to write the rating: prose (Rating: 3-star), stars (★★★☆☆) or frontmatter, only in the front matter")
+  fractionStyle := flags.String("fractions", string(FractionsAsIs), "how to write fractions in ingredients and steps: as-is, ascii or unicode")
+  scale := flags.Float64("scale", 1, "multiply ingredient amounts and yields by this factor")
+  servings := flags.Int("servings", 0, "scale every recipe to this many servings, going by its yield")
+  units := flags.String("units", UnitsAsIs, "convert amounts and oven temperatures to metric or imperial")
+  preferUnits := flags.String("prefer-units", UnitsAsIs, "of ingredients given both ways, like 1 cup (240 ml) milk, keep only the metric or imperial quantity")
+  weigh := flags.Bool("weigh", false, "give measured ingredients of known density, like 2 cups flour, by weight instead")
+  densities := flags.String("densities", "", "JSON file of {\"ingredient\": grams per ml} densities used by --weigh along with the built in ones")
+  changelog := flags.Bool("changelog", false, "keep a changelog section in each file of what changed between conversions")
+  annotateTemperatures := flags.Bool("annotate-temperatures", false, "add the other scale after temperatures in the instructions, e.g. 350°F (175°C)")
+  lang := flags.String("lang", "", "language of headings and other labels: en, de, fr or es")
+  labelsPath := flags.String("labels", "", "JSON file of {\"English label\": \"translation\"} pairs overriding the --lang labels")
+  memoryLimit := flags.Uint64("memory-limit", 0, "heap size in MiB past which to stop resizing photos, verifying and indexing to save memory")
+  templatePath := flags.String("template", "", "render recipes with this Go text/template instead, see TemplateRenderer")
+  report := flags.String("report", "", "write a JSON report of every recipe's output file, warnings and errors to this file")
+  headingLevel := flags.Int("heading-level", 3, "heading level of the Instructions, Notes, ... sections")
+  timingSummary := flags.Bool("timing-summary", false, "start the instructions with how long each of their titled sections takes, e.g. Dough 20 min · Bake 35 min")
+  if err := ApplyOptions(flags, config.Options, config.Path); err != nil {
+    return nil, err
+  }
+  warn := func(message string) {
+    fmt.Fprintf(os.Stderr, "warning: %s\n", message)
+  }
+  if forced != nil {
+    // the run before fanning out warned already
+    warn = func(string) {}
+  }
+  if err := ApplyPipeline(flags, args, pipeline, name, warn); err != nil {
+    return nil, err
+  }
+  flags.Parse(args)
+  if err := ApplyOptions(flags, forced, "format "+name); err != nil {
+    return nil, err
+  }
+
+  // several exports (e.g. from different devices) are merged into one output
+  paths, err := ExpandExportPaths(flags.Args())
+  if err != nil {
+    return nil, err
+  }
+  if len(paths) == 0 {
+    paths = []string{defaultExportPath}
+  }
+  var newest time.Time
+  for i, path := range paths {
+    paths[i] = exportFile(path)
+    info, err := os.Stat(paths[i])
+    if err != nil {
+      return nil, err
+    }
+    if info.ModTime().After(newest) {
+      newest = info.ModTime()
+    }
+  }
+
+  // pipelines sharing the --report each get their own, report.NAME.json
+  reportPath := *report
+  if _, own := pipeline["report"]; reportPath != "" && name != "" && !own {
+    reportPath = namedReportPath(reportPath, name)
+  }
+
+  converter := NewConverter()
+  converter.OutputDir = *output
+  mode, err := strconv.ParseUint(*fileMode, 8, 32)
+  if err != nil || mode > 0777 || mode&0400 == 0 {
+    return nil, fmt.Errorf("file mode %q must be octal permissions readable by the owner, e.g. 644", *fileMode)
+  }
+  converter.FileMode = os.FileMode(mode)
+  converter.Verify = *verify
+  converter.FavoritesOnly = *favoritesOnly
+  converter.OnlyTags = splitList(*onlyTags)
+  formatOptions := FormatOptions{
+    Frontmatter: *frontmatter,
+    HeroImage: *heroImage,
+    EmphasizeAmounts: *emphasize,
+    AmountMarkup: *amountMarkup,
+    HighlightIngredients: *highlightIngredients,
+    Credits: *credits,
+    NormalizeNutrition: *normalizeNutrition,
+    MinConfidence: *minConfidence,
+    StepsStyle: StepsStyle(*steps),
+    FractionStyle: FractionStyle(*fractionStyle),
+    RatingStyle: RatingStyle(*ratingStyle),
+    HeadingLevel: *headingLevel,
+    TimingSummary: *timingSummary,
+  }
+  if *obsidian {
+    if *compat != "" && *compat != CompatObsidian {
+      return nil, fmt.Errorf("--obsidian and --compat %s can't be combined", *compat)
+    }
+    *compat = CompatObsidian
+  }
+  if formatOptions.Labels, err = LoadLabels(*lang, *labelsPath); err != nil {
+    return nil, err
+  }
+  if *compat != "" {
+    if err := ApplyCompat(*compat, &formatOptions); err != nil {
+      return nil, err
+    }
+  }
+  for _, setting := range []struct {
+    charset *Charset
+    name string
+  }{
+    {&converter.Charsets.FileNames, *fileNameCharset},
+    {&converter.Charsets.Content, *contentCharset},
+    {&converter.Charsets.URLs, *urlCharset},
+  } {
+    if *setting.charset, err = ParseCharset(setting.name); err != nil {
+      return nil, err
+    }
+  }
+  formatOptions.TagCharset = converter.Charsets.URLs
+  if *strict {
+    for _, conflict := range []struct {
+      set bool
+      name string
+    }{
+      {*frontmatter || formatOptions.Frontmatter, "front matter"},
+      {isSiteLayout(*layout), "the " + *layout + " layout"},
+      {*templatePath != "", "--template"},
+      {*changelog, "--changelog"},
+      {*updateFields != "", "--update-fields"},
+    } {
+      if conflict.set {
+        return nil, fmt.Errorf("--strict-recipemd can't be combined with %s", conflict.name)
+      }
+    }
+    formatOptions.Strict = true
+  }
+  if err := formatOptions.validate(); err != nil {
+    return nil, err
+  }
+  converter.MinConfidence = *minConfidence
+  formats, err := SelectFormats(*format)
+  if err != nil {
+    return nil, err
+  }
+  if len(formats) == 1 {
+    settings := RenderSettings{Options: formatOptions, Language: *lang, TextWidth: *textWidth, Date: newest.UTC().Truncate(time.Second)}
+    if converter.Renderer, converter.Collection, err = formats[0].New(settings); err != nil {
+      return nil, err
+    }
+  } else if *templatePath != "" || isSiteLayout(*layout) {
+    return nil, errors.New("several formats can't be combined with --template or a site layout")
+  }
+  if *reference {
+    if converter.ReferenceValidator = FindReferenceValidator(); converter.ReferenceValidator == "" {
+      fmt.Fprintf(os.Stderr, "warning: %s not found, skipping reference validation\n", referenceCommand)
+    }
+  }
+  converter.ExportDir = filepath.Dir(paths[0])
+  if *compat == CompatObsidian {
+    converter.ImagesDir = "attachments"
+  }
+  converter.DownloadPhotos = *downloadPhotos
+  converter.PhotoDownload = photoOptions
+  converter.AssetStore = *assetStore
+  converter.GzipOriginals = *gzipOriginals
+  if imageOptions.Quality < 0 || imageOptions.Quality > 100 {
+    return nil, fmt.Errorf("image quality must be between 1 and 100, got %d", imageOptions.Quality)
+  }
+  converter.Images = imageOptions
+  converter.SkipJunk = *skipJunk
+  if *reviewState != "" {
+    if converter.Review, err = LoadReviewState(*reviewState); err != nil {
+      return nil, err
+    }
+  }
+  converter.Index = *index
+  converter.DumpIR = *dumpIR
+  converter.Stamp = *stamp
+  converter.Changelog = *changelog
+  converter.DefaultYields = defaultYields
+  if *scale <= 0 {
+    return nil, fmt.Errorf("scale must be positive, got %g", *scale)
+  }
+  if *scale != 1 && *servings > 0 {
+    return nil, errors.New("--scale and --servings can't be combined")
+  }
+  converter.Scale = *scale
+  converter.Servings = *servings
+  converter.MemoryLimit = *memoryLimit << 20
+  converter.AnnotateTemperatures = *annotateTemperatures
+  converter.DividedSplits = *dividedSplits
+  converter.Prune = *prune
+  converter.FileTimes = *fileTimes
+  converter.MakeAhead = *makeAhead
+  switch *units {
+  case UnitsAsIs, UnitsMetric, UnitsImperial:
+    converter.Units = *units
+  default:
+    return nil, fmt.Errorf("unknown unit system %q", *units)
+  }
+  switch *preferUnits {
+  case UnitsAsIs, UnitsMetric, UnitsImperial:
+    converter.PreferUnits = *preferUnits
+  default:
+    return nil, fmt.Errorf("unknown unit system %q for --prefer-units", *preferUnits)
+  }
+  converter.Weigh = *weigh
+  if *densities != "" {
+    if converter.Densities, err = LoadDensities(*densities); err != nil {
+      return nil, err
+    }
+  }
+  if *transforms != "" {
+    if converter.Transforms, err = LoadTransformRules(*transforms); err != nil {
+      return nil, err
+    }
+  }
+  switch *layout {
+  case LayoutFlat, LayoutCollection:
+    converter.Layout = *layout
+  case LayoutHugo, LayoutJekyll:
+    // the export has no dates, so date the recipes by the export itself
+    converter.Layout = *layout
+    converter.Renderer = SiteRenderer{Layout: *layout, Date: newest.UTC().Truncate(time.Second), Options: formatOptions}
+    converter.ImagesDir = siteImagesDir(*layout)
+  default:
+    return nil, fmt.Errorf("unknown layout %q", *layout)
+  }
+  if *templatePath != "" {
+    if isSiteLayout(*layout) {
+      return nil, fmt.Errorf("--template can't be combined with the %s layout", *layout)
+    }
+    if converter.Renderer, err = LoadTemplateRenderer(*templatePath, formatOptions); err != nil {
+      return nil, err
+    }
+  }
+  switch *incomplete {
+  case IncompletePlaceholder, IncompleteSkip, IncompleteFail:
+    converter.Incomplete = *incomplete
+  default:
+    return nil, fmt.Errorf("unknown incomplete recipe handling %q", *incomplete)
+  }
+  switch *duplicates {
+  case DuplicatesReport, DuplicatesSkip, DuplicatesMerge, DuplicatesSuffix:
+    converter.Duplicates = *duplicates
+  default:
+    return nil, fmt.Errorf("unknown duplicates handling %q", *duplicates)
+  }
+  switch *aliases {
+  case AliasNone, AliasSymlink, AliasStub:
+    converter.Aliases = *aliases
+  default:
+    return nil, fmt.Errorf("unknown alias style %q", *aliases)
+  }
+  if *inferTags || *classifier != "" {
+    converter.Classifier = DefaultClassifierRules()
+    if *classifier != "" {
+      if converter.Classifier, err = LoadClassifierRules(*classifier); err != nil {
+        return nil, err
+      }
+    }
+  }
+  if *stripBrands || *brands != "" {
+    converter.Brands = NewBrands(DefaultBrandNames())
+    if *brands != "" {
+      if converter.Brands, err = LoadBrands(*brands); err != nil {
+        return nil, err
+      }
+    }
+  }
+  if *lint || *plausibility != "" {
+    ranges := DefaultPlausibility()
+    if *plausibility != "" {
+      if ranges, err = LoadPlausibility(*plausibility); err != nil {
+        return nil, err
+      }
+    }
+    converter.Lint = &ranges
+  }
+  if *updateFields != "" {
+    for _, field := range splitList(*updateFields) {
+      if !contains(updatableFields, field) {
+        return nil, fmt.Errorf("unknown field %q for --update-fields", field)
+      }
+      converter.UpdateFields = append(converter.UpdateFields, field)
+    }
+  }
+
+  options := map[string]string{}
+  flags.VisitAll(func(f *flag.Flag) {
+    options[f.Name] = f.Value.String()
+  })
+  converter.Snapshot, err = NewOptionsSnapshot(options, *replacements, *plausibility, *transforms, *classifier, *brands, *densities)
+  if err != nil {
+    return nil, err
+  }
+  converter.Normalizer.ConvertFractions = !*keepFractions
+  if *noNormalize {
+    converter.Normalizer.Replacements = map[rune]string{}
+  } else {
+    if *spellDegrees {
+      converter.Normalizer.SpellDegrees()
+    }
+    if *replacements != "" {
+      if err := converter.Normalizer.LoadReplacements(*replacements); err != nil {
+        return nil, err
+      }
+    }
+  }
+
+
+  return &convertRun{
+    name: name,
+    converter: converter,
+    paths: paths,
+    pipelines: splitList(*pipelines),
+    gitCommit: *gitCommit,
+    watch: *watch,
+    watchInterval: *watchInterval,
+    report: reportPath,
+    formats: formats,
+  }, nil
+}
+
+func runConvert(args []string) error {
+  base, runs, err := newConvertRuns(args)
+  if err != nil {
+    return err
+  }
+  convert := func() error {
+    return convertAndCommit(runs, base.paths)
+  }
+
+  if !base.watch {
+    return convert()
+  }
+
+  if err := convert(); err != nil {
+    fmt.Fprintf(os.Stderr, "error: %s\n", err)
+  }
+  fmt.Fprintf(os.Stderr, "watching %s for changes\n", strings.Join(base.paths, ", "))
+  return WatchFiles(base.paths, base.watchInterval, func() {
+    fmt.Fprintf(os.Stderr, "export changed, converting\n")
+    if err := convert(); err != nil {
+      fmt.Fprintf(os.Stderr, "error: %s\n", err)
+    }
+  })
+}
+
+// newConvertRuns sets up a run for each pipeline of the config file, or just
+// the one for the command line options when it has none. The returned base
+// run holds the options given on the command line.
+func newConvertRuns(args []string) (*convertRun, []*convertRun, error) {
+  configPath, explicit := configArg(args)
+  config, err := LoadConfig(configPath, explicit)
+  if err != nil {
+    return nil, nil, err
+  }
+  base, err := newConvertRun(args, config, "", nil, nil)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  runs := []*convertRun{base}
+  if len(config.Pipelines) > 0 {
+    names := base.pipelines
+    if len(names) == 0 {
+      for name := range config.Pipelines {
+        names = append(names, name)
+      }
+      sort.Strings(names)
+    }
+    runs = runs[:0]
+    for _, name := range names {
+      pipeline, exists := config.Pipelines[name]
+      if !exists {
+        return nil, nil, fmt.Errorf("no pipeline %q in %s", name, config.Path)
+      }
+      run, err := newConvertRun(args, config, name, pipeline, nil)
+      if err != nil {
+        return nil, nil, err
+      }
+      runs = append(runs, run)
+    }
+  } else if len(base.pipelines) > 0 {
+    return nil, nil, fmt.Errorf("--pipeline: %s defines no pipelines", configPath)
+  }
+
+  expanded := make([]*convertRun, 0, len(runs))
+  for _, run := range runs {
+    formatRuns, err := fanOutFormats(args, config, run)
+    if err != nil {
+      return nil, nil, err
+    }
+    expanded = append(expanded, formatRuns...)
+  }
+  runs = expanded
+
+  for _, run := range runs {
+    if run.gitCommit && !IsGitRepo(run.converter.OutputDir) {
+      return nil, nil, fmt.Errorf("--git-commit: %s is not in a git repository", run.converter.OutputDir)
+    }
+  }
+  return base, runs, nil
+}
+
+func namedReportPath(path string, name string) string {
+  ext := filepath.Ext(path)
+  return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// fanOutFormats sets up a run per format for a run with several, each
+// writing into a folder of its own named after the format. The runs still
+// share one pass over the exports.
+func fanOutFormats(args []string, config Config, run *convertRun) ([]*convertRun, error) {
+  if len(run.formats) < 2 {
+    return []*convertRun{run}, nil
+  }
+  pipeline := config.Pipelines[run.name]
+  runs := make([]*convertRun, 0, len(run.formats))
+  for _, format := range run.formats {
+    forced := map[string]interface{}{
+      "format": format.Name,
+      "output": filepath.Join(run.converter.OutputDir, format.Name),
+    }
+    if _, own := pipeline["report"]; own && run.report != "" {
+      forced["report"] = namedReportPath(run.report, format.Name)
+    }
+    name := format.Name
+    if run.name != "" {
+      name = run.name + "." + format.Name
+    }
+    formatRun, err := newConvertRun(args, config, name, pipeline, forced)
+    if err != nil {
+      return nil, err
+    }
+    runs = append(runs, formatRun)
+  }
+  return runs, nil
+}
+
+// convertAndCommit converts the exports, then commits the output of the runs
+// with --git-commit.
+func convertAndCommit(runs []*convertRun, paths []string) error {
+  if err := convertExports(runs, paths); err != nil {
+    return err
+  }
+  for _, run := range runs {
+    if !run.gitCommit {
+      continue
+    }
+    message, err := GitCommitOutput(run.converter.OutputDir)
+    if message != "" {
+      fmt.Fprintf(os.Stderr, "committed: %s\n", message)
+    }
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// convertExports converts the exports for all runs in one pass, printing
+// warnings as they come and each run's report once it is done.
+func convertExports(runs []*convertRun, paths []string) error {
+  sources, cleanup, err := OpenExports(paths)
+  if err != nil {
+    return err
+  }
+  defer cleanup()
+
+  sinks := make([]SinkSpec, 0, len(runs))
+  reports := make([]*runReport, 0, len(runs))
+  for _, run := range runs {
+    report := startRunReport(run.name)
+    if run.report != "" {
+      report.json = NewConversionReport(run.name, run.converter.OutputDir)
+      report.jsonPath = run.report
+    }
+    run.converter.Progress = report.progress
+    sinks = append(sinks, SinkSpec{run.converter})
+    reports = append(reports, report)
+  }
+
+  _, err = ConvertAllTo(context.Background(), sources, sinks)
+  for _, report := range reports {
+    if reportErr := report.finish(err); reportErr != nil && err == nil {
+      err = reportErr
+    }
+  }
+  return err
+}
+
+// runReport collects the progress events of one run. With pipelines, every
+// line is prefixed with the pipeline's name.
+type runReport struct {
+  prefix string
+  progress chan ProgressEvent
+  wg sync.WaitGroup
+
+  skipped []ProgressEvent
+  findings []ProgressEvent
+  violations []ProgressEvent
+  uncertain []ProgressEvent
+  duplicates []ProgressEvent
+  incomplete []ProgressEvent
+
+  // json records everything, for --report to write to jsonPath
+  json *ConversionReport
+  jsonPath string
+}
+
+func startRunReport(name string) *runReport {
+  r := &runReport{progress: make(chan ProgressEvent)}
+  if name != "" {
+    r.prefix = "[" + name + "] "
+  }
+  r.wg.Add(1)
+  go func() {
+    defer r.wg.Done()
+    for event := range r.progress {
+      if r.json != nil {
+        r.json.Add(event)
+      }
+      switch event.Kind {
+      case ExportDetected:
+        fmt.Fprintf(os.Stderr, "%s%s\n", r.prefix, event.Message)
+      case RecipeRemoved:
+        fmt.Fprintf(os.Stderr, "%sremoved %s (%s)\n", r.prefix, event.Message, event.Title)
+      case ConversionWarning:
+        fmt.Fprintf(os.Stderr, "%swarning: %s (%s): %s\n", r.prefix, event.Title, event.UUID, event.Message)
+      case RecipeFailed:
+        fmt.Fprintf(os.Stderr, "%serror: %s (%s): %s\n", r.prefix, event.Title, event.UUID, event.Message)
+      case RecipeSkipped:
+        r.skipped = append(r.skipped, event)
+      case LintWarning:
+        r.findings = append(r.findings, event)
+      case ReferenceViolation:
+        r.violations = append(r.violations, event)
+      case UncertainIngredient:
+        r.uncertain = append(r.uncertain, event)
+      case DuplicateRecipe:
+        r.duplicates = append(r.duplicates, event)
+      case IncompleteRecipe:
+        r.incomplete = append(r.incomplete, event)
+      }
+    }
+  }()
+  return r
+}
+
+// finish waits for the last events and prints the report, and writes the
+// JSON one with err, the error the conversion ended with.
+func (r *runReport) finish(err error) error {
+  close(r.progress)
+  r.wg.Wait()
+
+  for _, section := range []struct {
+    header string
+    events []ProgressEvent
+  }{
+    {"skipped %d recipe(s):", r.skipped},
+    {"%d incomplete recipe(s):", r.incomplete},
+    {"lint report, %d finding(s):", r.findings},
+    {"%d duplicate recipe(s):", r.duplicates},
+    {"%d ingredient line(s) left unparsed:", r.uncertain},
+    {"recipemd reported %d problem(s):", r.violations},
+  } {
+    if len(section.events) == 0 {
+      continue
+    }
+    fmt.Fprintf(os.Stderr, r.prefix+section.header+"\n", len(section.events))
+    for _, event := range section.events {
+      fmt.Fprintf(os.Stderr, "  %q (%s): %s\n", event.Title, event.UUID, event.Message)
+    }
+  }
+
+  if r.jsonPath == "" {
+    return nil
+  }
+  return r.json.Write(r.jsonPath, err)
+}
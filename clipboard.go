@@ -0,0 +1,133 @@
+package main
+
+import (
+  "encoding/base64"
+  "errors"
+  "flag"
+  "fmt"
+  "os"
+  "os/exec"
+  "strings"
+)
+
+// clipboardCommands are tried in order to put text on the system clipboard.
+// The X and Wayland ones fail without a display, and then the next is tried.
+var clipboardCommands = [][]string{
+  {"pbcopy"},
+  {"wl-copy"},
+  {"xclip", "-selection", "clipboard"},
+  {"xsel", "--clipboard", "--input"},
+  {"clip.exe"},
+}
+
+// CopyToClipboard puts text on the clipboard and says how. Over SSH, or when
+// none of the clipboard tools work, it asks the terminal to do it with an OSC
+// 52 escape sequence, which most terminals (and tmux) understand.
+func CopyToClipboard(text string) (string, error) {
+  if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+    for _, command := range clipboardCommands {
+      if _, err := exec.LookPath(command[0]); err != nil {
+        continue
+      }
+      cmd := exec.Command(command[0], command[1:]...)
+      cmd.Stdin = strings.NewReader(text)
+      if err := cmd.Run(); err == nil {
+        return command[0], nil
+      }
+    }
+  }
+
+  tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+  if err != nil {
+    return "", errors.New("no clipboard tool found, and no terminal to copy through")
+  }
+  defer tty.Close()
+  if _, err := tty.WriteString(osc52(text, os.Getenv("TMUX") != "")); err != nil {
+    return "", err
+  }
+  return "the terminal (OSC 52)", nil
+}
+
+func osc52(text string, tmux bool) string {
+  sequence := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+  if tmux {
+    // tmux passes it on to the outer terminal when wrapped like this
+    sequence = "\x1bPtmux;" + strings.ReplaceAll(sequence, "\x1b", "\x1b\x1b") + "\x1b\\"
+  }
+  return sequence
+}
+
+// matchRecipe finds the recipe whose title contains match. Several matches
+// are only fine if one of them is titled exactly that.
+func matchRecipe(recipes []Recipe, match string) (Recipe, error) {
+  found := make([]Recipe, 0)
+  for _, recipe := range recipes {
+    if strings.EqualFold(recipe.Title, match) {
+      return recipe, nil
+    }
+    if strings.Contains(strings.ToLower(recipe.Title), strings.ToLower(match)) {
+      found = append(found, recipe)
+    }
+  }
+
+  switch len(found) {
+  case 0:
+    return Recipe{}, fmt.Errorf("no recipe title contains %q", match)
+  case 1:
+    return found[0], nil
+  }
+  titles := make([]string, 0, len(found))
+  for _, recipe := range found {
+    titles = append(titles, recipe.Title)
+  }
+  return Recipe{}, fmt.Errorf("%q matches %d recipes: %s", match, len(found), strings.Join(titles, "; "))
+}
+
+func runCopy(args []string) error {
+  flags := flag.NewFlagSet("copy", flag.ExitOnError)
+  match := flags.String("match", "", "copy the recipe whose title contains this")
+  frontmatter := flags.Bool("frontmatter", false, "add a YAML front matter block with the recipe metadata")
+  stdout := flags.Bool("stdout", false, "print the recipe instead of copying it")
+  flags.Parse(args)
+
+  if *match == "" {
+    return errors.New("usage: recipekeeper2recipemd copy -match title [export]")
+  }
+  path := defaultExportPath
+  if flags.NArg() > 0 {
+    path = flags.Arg(0)
+  }
+
+  file, err := os.Open(exportFile(path))
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+
+  recipes, err := ScrapeRecipeKeeperExportHtml(file)
+  if err != nil {
+    return err
+  }
+  recipe, err := matchRecipe(recipes, *match)
+  if err != nil {
+    return err
+  }
+  // the photos are files next to the export, of no use where this is pasted
+  recipe = DefaultTextNormalizer().NormalizeRecipe(recipe)
+  recipe.PhotoPaths = nil
+
+  content, err := FormatRecipeMD(recipe, FormatOptions{Frontmatter: *frontmatter})
+  if err != nil {
+    return err
+  }
+  if *stdout {
+    _, err := os.Stdout.WriteString(content)
+    return err
+  }
+  method, err := CopyToClipboard(content)
+  if err != nil {
+    return err
+  }
+  fmt.Fprintf(os.Stderr, "copied %q using %s\n", recipe.Title, method)
+  return nil
+}
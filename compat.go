@@ -0,0 +1,47 @@
+package main
+
+import (
+  "fmt"
+)
+
+// Compatibility profiles pin the formatting quirks the app a collection is
+// imported into expects, overriding the individual format flags.
+const (
+  // CompatRecipeMD is for the recipemd reference tools, which want the title
+  // on the first line and amounts marked with *.
+  CompatRecipeMD = "recipemd"
+  // CompatRecipeSage is for RecipeSage's import, which takes lines literally
+  // and numbers the steps itself.
+  CompatRecipeSage = "recipesage"
+  // CompatObsidian is for Obsidian and its recipe plugins, which read the
+  // front matter and link notes with [[...]].
+  CompatObsidian = "obsidian"
+)
+
+func ApplyCompat(profile string, options *FormatOptions) error {
+  switch profile {
+  case CompatRecipeMD:
+    options.Frontmatter = false
+    options.HashTags = false
+    options.WikiLinks = false
+    options.AmountMarkup = false
+    options.EmphasizeAmounts = true
+    options.FractionStyle = FractionsASCII
+  case CompatRecipeSage:
+    options.Frontmatter = false
+    options.HeroImage = false
+    options.HashTags = false
+    options.WikiLinks = false
+    options.AmountMarkup = false
+    options.EmphasizeAmounts = false
+    options.StepsStyle = StepsPlain
+  case CompatObsidian:
+    options.Frontmatter = true
+    options.HashTags = true
+    options.WikiLinks = true
+    options.AmountMarkup = false
+  default:
+    return fmt.Errorf("unknown compatibility profile %q", profile)
+  }
+  return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+  "bytes"
+  "flag"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+  "time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var compatRecipe = Recipe{
+  Title: "Apple Pie",
+  Description: "Grandma's, with a lattice top.",
+  Metadata: RecipeMetadata{
+    UUID: "apple-pie",
+    Rating: 4,
+    Source: "Grandma",
+    CategoryList: []string{"Dessert", "Baking"},
+    Yield: "8 slices",
+    PrepTime: 30 * time.Minute,
+    CookTime: 45 * time.Minute,
+  },
+  IngredientLines: []string{"1 1/2 cups flour", "1/2 cup butter", "6 apples", "1 batch Pie Crust"},
+  InstructionLines: []string{"Roll out the Pie Crust.", "Slice the apples.", "Bake for 45 minutes."},
+  NotesLines: []string{"Best the next day."},
+  Links: []RecipeLink{{Text: "Pie Crust", Target: "pie-crust"}},
+}
+
+// TestCompatProfiles pins the files each profile writes, see testdata/compat.
+// Run with -update to rewrite them after a deliberate change.
+func TestCompatProfiles(t *testing.T) {
+  for _, profile := range []string{CompatRecipeMD, CompatRecipeSage, CompatObsidian} {
+    options := FormatOptions{}
+    if err := ApplyCompat(profile, &options); err != nil {
+      t.Fatal(err)
+    }
+    content, err := RecipeMDRenderer{Options: options}.Render(compatRecipe)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    golden := filepath.Join("testdata", "compat", profile+".md")
+    if *update {
+      if err := os.WriteFile(golden, content, 0644); err != nil {
+        t.Fatal(err)
+      }
+    }
+    want, err := os.ReadFile(golden)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if !bytes.Equal(content, want) {
+      t.Errorf("%s: wrote\n%s\nwant\n%s", profile, content, want)
+    }
+
+    // whatever the quirks, the file is still RecipeMD
+    parsed, err := ParseRecipeMD(bytes.NewReader(content))
+    if err != nil {
+      t.Fatalf("%s: %s", profile, err)
+    }
+    if parsed.Title != compatRecipe.Title || len(parsed.IngredientLines) != len(compatRecipe.IngredientLines) || !reflect.DeepEqual(parsed.InstructionLines, compatRecipe.InstructionLines) {
+      t.Errorf("%s: read back %q, %q, %q", profile, parsed.Title, parsed.IngredientLines, parsed.InstructionLines)
+    }
+  }
+}
+
+func TestCompatProfileQuirks(t *testing.T) {
+  render := func(profile string) string {
+    options := FormatOptions{StepsStyle: StepsNumbered, HashTags: true}
+    if err := ApplyCompat(profile, &options); err != nil {
+      t.Fatal(err)
+    }
+    content, _ := RecipeMDRenderer{Options: options}.Render(compatRecipe)
+    return string(content)
+  }
+
+  sage := render(CompatRecipeSage)
+  if strings.HasPrefix(sage, "---") || strings.Contains(sage, "1. ") || strings.Contains(sage, "#Dessert") || strings.Contains(sage, "[[") {
+    t.Errorf("recipesage has front matter, numbered steps, tags or wiki links:\n%s", sage)
+  }
+  obsidian := render(CompatObsidian)
+  if !strings.HasPrefix(obsidian, "---\n") || !strings.Contains(obsidian, "[[pie-crust|Pie Crust]]") {
+    t.Errorf("obsidian has no front matter or wiki links:\n%s", obsidian)
+  }
+  if err := ApplyCompat("paprika", &FormatOptions{}); err == nil {
+    t.Errorf("an unknown profile was accepted")
+  }
+}
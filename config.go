@@ -0,0 +1,210 @@
+package main
+
+import (
+  "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// A config file holds defaults for the convert flags, so a long lived setup
+// doesn't need them all on every run. It is a JSON object of flag names to
+// values, like the other config files:
+//
+//   {
+//     "output": "vault",
+//     "obsidian": true,
+//     "max-image-size": 1600,
+//     "default-yield": {"Cocktail": "1 drink"},
+//     "update-fields": ["photos", "nutrition"]
+//   }
+//
+// Lists set repeatable flags once per item and other flags to the items
+// joined by commas, objects set them once per key=value pair. Flags given on
+// the command line still win.
+//
+// The "pipelines" key names sets of options for separate outputs, which are
+// then all written from one pass over the export:
+//
+//   "pipelines": {
+//     "site": {"favorites-only": true, "scale": 2, "units": "metric", "layout": "hugo", "output": "site"},
+//     "vault": {"obsidian": true, "output": "vault"}
+//   }
+//
+// A pipeline's options override the rest of the config, but flags given on
+// the command line still win, with a warning for each pipeline option they
+// override. Each pipeline has its own --git-commit, while --watch and
+// --watch-interval, like the exports to read, are for all of them and can't
+// be set in a pipeline.
+const defaultConfigFile = ".recipekeeper2recipemd.json"
+
+type Config struct {
+  Path string
+  Options map[string]interface{}
+  Pipelines map[string]map[string]interface{}
+}
+
+// configArg finds the --config flag in args before they are parsed, since the
+// config has to be applied first.
+func configArg(args []string) (string, bool) {
+  for i, arg := range args {
+    if arg == "--" {
+      break
+    }
+    name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+    if !strings.HasPrefix(arg, "-") || name != "config" {
+      continue
+    }
+    if hasValue {
+      return value, true
+    }
+    if i+1 < len(args) {
+      return args[i+1], true
+    }
+  }
+  return defaultConfigFile, false
+}
+
+// LoadConfig reads the config file at path. A missing default config file is
+// fine, a missing explicitly given one is not.
+func LoadConfig(path string, explicit bool) (Config, error) {
+  config := Config{Path: path, Options: map[string]interface{}{}}
+  content, err := os.ReadFile(path)
+  if os.IsNotExist(err) && !explicit {
+    return config, nil
+  } else if err != nil {
+    return config, err
+  }
+
+  if err := json.Unmarshal(content, &config.Options); err != nil {
+    return config, fmt.Errorf("%s: %w", path, err)
+  }
+  if pipelines, exists := config.Options["pipelines"]; exists {
+    delete(config.Options, "pipelines")
+    encoded, _ := json.Marshal(pipelines)
+    if err := json.Unmarshal(encoded, &config.Pipelines); err != nil {
+      return config, fmt.Errorf("%s: pipelines: %w", path, err)
+    }
+  }
+  return config, nil
+}
+
+// ApplyOptions sets flags from config options, source naming them in errors.
+func ApplyOptions(flags *flag.FlagSet, options map[string]interface{}, source string) error {
+  names := make([]string, 0, len(options))
+  for name := range options {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  for _, name := range names {
+    if flags.Lookup(name) == nil || name == "config" || name == "pipeline" {
+      return fmt.Errorf("%s: unknown option %q", source, name)
+    }
+    values, err := configValues(options[name])
+    if err != nil {
+      return fmt.Errorf("%s: %s: %w", source, name, err)
+    }
+    // setting a plain flag again replaces it, so it takes the list at once
+    _, list := options[name].([]interface{})
+    if _, repeatable := flags.Lookup(name).Value.(mapFlag); list && !repeatable {
+      values = []string{strings.Join(values, ",")}
+    }
+    for _, value := range values {
+      if err := flags.Set(name, value); err != nil {
+        return fmt.Errorf("%s: %s: %w", source, name, err)
+      }
+    }
+  }
+  return nil
+}
+
+// sharedOptions are the options of the whole conversion rather than of an
+// output, see runConvert.
+var sharedOptions = []string{"watch", "watch-interval"}
+
+// ApplyPipeline sets flags from the options of the pipeline name, leaving
+// out those also given in args since the command line wins, and telling
+// warn about each of them.
+func ApplyPipeline(flags *flag.FlagSet, args []string, options map[string]interface{}, name string, warn func(string)) error {
+  explicit := explicitFlags(flags, args)
+  kept := make(map[string]interface{}, len(options))
+  for option, value := range options {
+    switch {
+    case contains(sharedOptions, option):
+      return fmt.Errorf("pipeline %s: %q is shared by all pipelines, set it outside of them", name, option)
+    case explicit[option]:
+      warn(fmt.Sprintf("pipeline %s: --%s on the command line overrides its %q option", name, option, option))
+    default:
+      kept[option] = value
+    }
+  }
+  return ApplyOptions(flags, kept, "pipeline "+name)
+}
+
+// explicitFlags are the names of the flags set in args, found by parsing
+// them into a copy of flags that keeps no values.
+func explicitFlags(flags *flag.FlagSet, args []string) map[string]bool {
+  probe := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+  probe.SetOutput(io.Discard)
+  flags.VisitAll(func(f *flag.Flag) {
+    boolean, _ := f.Value.(interface{ IsBoolFlag() bool })
+    probe.Var(probeValue{boolean != nil && boolean.IsBoolFlag()}, f.Name, "")
+  })
+  probe.Parse(args)
+
+  explicit := map[string]bool{}
+  probe.Visit(func(f *flag.Flag) {
+    explicit[f.Name] = true
+  })
+  return explicit
+}
+
+type probeValue struct {
+  boolean bool
+}
+
+func (probeValue) String() string { return "" }
+func (probeValue) Set(string) error { return nil }
+func (v probeValue) IsBoolFlag() bool { return v.boolean }
+
+func configValues(value interface{}) ([]string, error) {
+  switch value := value.(type) {
+  case string:
+    return []string{value}, nil
+  case bool:
+    return []string{strconv.FormatBool(value)}, nil
+  case float64:
+    return []string{strconv.FormatFloat(value, 'f', -1, 64)}, nil
+  case []interface{}:
+    values := make([]string, 0, len(value))
+    for _, item := range value {
+      itemValues, err := configValues(item)
+      if err != nil || len(itemValues) != 1 {
+        return nil, fmt.Errorf("lists may only hold strings, numbers and booleans")
+      }
+      values = append(values, itemValues[0])
+    }
+    return values, nil
+  case map[string]interface{}:
+    keys := make([]string, 0, len(value))
+    for key := range value {
+      keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    values := make([]string, 0, len(value))
+    for _, key := range keys {
+      itemValues, err := configValues(value[key])
+      if err != nil || len(itemValues) != 1 {
+        return nil, fmt.Errorf("objects may only hold strings, numbers and booleans")
+      }
+      values = append(values, key+"="+itemValues[0])
+    }
+    return values, nil
+  }
+  return nil, fmt.Errorf("unsupported value %v", value)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+  "encoding/json"
+  "flag"
+  "testing"
+)
+
+func TestApplyOptionsLists(t *testing.T) {
+  // the example from the Config documentation
+  options := map[string]interface{}{}
+  if err := json.Unmarshal([]byte(`{"update-fields": ["photos", "nutrition"], "default-yield": {"Cocktail": "1 drink", "Soup": "4 bowls"}}`), &options); err != nil {
+    t.Fatal(err)
+  }
+  flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+  updateFields := flags.String("update-fields", "", "")
+  defaultYields := mapFlag{}
+  flags.Var(defaultYields, "default-yield", "")
+
+  if err := ApplyOptions(flags, options, "config"); err != nil {
+    t.Fatal(err)
+  }
+  if *updateFields != "photos,nutrition" {
+    t.Errorf("update-fields is %q, want %q", *updateFields, "photos,nutrition")
+  }
+  if defaultYields["Cocktail"] != "1 drink" || defaultYields["Soup"] != "4 bowls" {
+    t.Errorf("default-yield is %v", defaultYields)
+  }
+}
+
+func TestApplyPipelineCommandLineWins(t *testing.T) {
+  flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+  output := flags.String("output", "recipes", "")
+  units := flags.String("units", "", "")
+  favoritesOnly := flags.Bool("favorites-only", false, "")
+  flags.Bool("watch", false, "")
+  args := []string{"--output=mine", "--favorites-only", "recipes.html"}
+
+  var warnings []string
+  warn := func(message string) { warnings = append(warnings, message) }
+  pipeline := map[string]interface{}{"output": "site", "units": "metric", "favorites-only": false}
+  if err := ApplyPipeline(flags, args, pipeline, "site", warn); err != nil {
+    t.Fatal(err)
+  }
+  flags.Parse(args)
+  if *output != "mine" || !*favoritesOnly || *units != "metric" {
+    t.Errorf("output %q, favorites-only %v, units %q", *output, *favoritesOnly, *units)
+  }
+  if len(warnings) != 2 {
+    t.Errorf("warned %q, want one warning for each overridden option", warnings)
+  }
+
+  if err := ApplyPipeline(flags, nil, map[string]interface{}{"watch": true}, "site", warn); err == nil {
+    t.Errorf("a pipeline set watch")
+  }
+}
@@ -0,0 +1,742 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+)
+
+type ProgressKind int
+
+const (
+  RecipeDiscovered ProgressKind = iota
+  RecipeConverted
+  RecipeSkipped
+  ImageProcessed
+  ConversionWarning
+  LintWarning
+  ReferenceViolation
+  UncertainIngredient
+  DuplicateRecipe
+  ExportDetected
+  RecipeRemoved
+  RecipeFailed
+  IncompleteRecipe
+)
+
+func (k ProgressKind) String() string {
+  switch k {
+  case RecipeDiscovered:
+    return "discovered"
+  case RecipeConverted:
+    return "converted"
+  case RecipeSkipped:
+    return "skipped"
+  case ImageProcessed:
+    return "image"
+  case ConversionWarning:
+    return "warning"
+  case LintWarning:
+    return "lint"
+  case ReferenceViolation:
+    return "recipemd"
+  case UncertainIngredient:
+    return "ingredient"
+  case DuplicateRecipe:
+    return "duplicate"
+  case ExportDetected:
+    return "export"
+  case RecipeRemoved:
+    return "removed"
+  case RecipeFailed:
+    return "failed"
+  case IncompleteRecipe:
+    return "incomplete"
+  }
+  return "unknown"
+}
+
+// ProgressEvent is one step of a conversion. For RecipeConverted the
+// Message is the path of the written file, relative to the output folder.
+type ProgressEvent struct {
+  Kind ProgressKind
+  UUID string
+  Title string
+  Message string
+}
+
+// Converter drives a whole export through extraction and rendering. It is the
+// entry point for using the tool as a library.
+type Converter struct {
+  Renderer Renderer
+
+  // Collection, when set, replaces the per recipe files with a single file
+  // covering the whole export.
+  Collection CollectionRenderer
+
+  // Progress, when set, receives an event for every step of the conversion.
+  // Sends block, so the receiver has to keep draining it until Convert returns.
+  Progress chan<- ProgressEvent
+
+  // Verify re-reads every written RecipeMD file and reports fields that did
+  // not survive the round trip.
+  Verify bool
+
+  // Normalizer cleans up the text of every extracted recipe, fractions
+  // included. Nil leaves the text exactly as it was in the export.
+  Normalizer *TextNormalizer
+
+  // SkipJunk leaves out empty and placeholder recipes (see JunkReason).
+  SkipJunk bool
+
+  // Review, when set, leaves out the recipes excluded during review.
+  Review *ReviewState
+
+  // Charsets limits the characters used in file names, content and URLs.
+  Charsets CharsetPolicy
+
+  // Duplicates says what to do with duplicate recipes: report, skip, merge
+  // or suffix (see FindDuplicates). Empty only reports them.
+  Duplicates string
+
+  // Incomplete says what to do with recipes without a title or ingredients:
+  // placeholder, skip or fail (see IncompleteReason). Empty is placeholder.
+  Incomplete string
+
+  // Transforms are find/replace rules applied after the cleanup.
+  Transforms []TransformRule
+
+  // Brands, when set, are taken out of the ingredient lines, see Brands.
+  Brands *Brands
+
+  // Classifier, when set, suggests a course and cuisine for recipes missing
+  // them (see InferTags). What was inferred is reported as lint findings.
+  Classifier []ClassifierRule
+
+  // DefaultYields fills in missing yields by course or category, see DefaultYield.
+  DefaultYields map[string]string
+
+  // Scale multiplies ingredient amounts and yields, or Servings scales each
+  // recipe to that many servings instead. See ScaleRecipe.
+  Scale float64
+  Servings int
+  // Units converts amounts and temperatures to UnitsMetric or UnitsImperial.
+  Units string
+  // Densities turn volumes into weights with Weigh, the built in ones if nil.
+  Densities Densities
+  // Weigh gives ingredients of known density by weight, whatever the Units.
+  Weigh bool
+  // PreferUnits keeps only the UnitsMetric or UnitsImperial quantity of
+  // ingredients given both ways, see PreferIngredientUnits.
+  PreferUnits string
+  // AnnotateTemperatures adds the other scale after oven temperatures in the
+  // instructions, see AnnotateTemperatures.
+  AnnotateTemperatures bool
+  // MakeAhead calls out make-ahead steps and long resting times at the top of
+  // each recipe and tags it, see AnnotateMakeAhead.
+  MakeAhead bool
+  // DividedSplits adds the parts of divided ingredients found in the steps
+  // to their lines, see AnnotateDivided.
+  DividedSplits bool
+
+  // Snapshot describes the version and options of this run. It is recorded in
+  // the manifest, and in each file as well when Stamp is set.
+  Snapshot OptionsSnapshot
+  Stamp bool
+
+  // Changelog adds a section to each file listing when and how the recipe
+  // changed between conversions.
+  Changelog bool
+
+  // UpdateFields, when set, only refreshes these field blocks in files that
+  // already exist instead of rewriting them (see PatchFields).
+  UpdateFields []string
+
+  // FavoritesOnly and OnlyTags limit the conversion to favourites, and to
+  // recipes with one of the tags (categories, collections, or favorite).
+  FavoritesOnly bool
+  OnlyTags []string
+
+  // MemoryLimit, in bytes, is the heap size past which the conversion drops
+  // optional work to save memory, see memoryWatchdog.
+  MemoryLimit uint64
+
+  // FileTimes sets the modification time of the recipe files to when the
+  // recipe was last changed in Recipe Keeper, if the export says.
+  FileTimes bool
+
+  // Prune deletes the files of recipes converted on an earlier run that are
+  // no longer written, see pruneRemoved.
+  Prune bool
+
+  // OutputDir is where the converted files go, and FileMode the mode of the
+  // recipe files, 0644 if unset.
+  OutputDir string
+  FileMode os.FileMode
+
+  // ExportDir is where the export's relative photo paths are resolved from.
+  ExportDir string
+
+  // DownloadPhotos fetches photos referenced by URL into the images folder
+  // instead of leaving the remote reference in place.
+  DownloadPhotos bool
+  PhotoDownload PhotoDownloadOptions
+
+  // Adding keeps the recipes converted before in the manifest, for adding
+  // recipes to the output rather than converting a whole export.
+  Adding bool
+
+  // AssetStore names photos by content hash so duplicates are stored once,
+  // GzipOriginals compresses all but each recipe's first photo. See storePhoto.
+  AssetStore bool
+  GzipOriginals bool
+  Images ImageOptions
+  // ImagesDir is the folder under the output directory photos are copied to.
+  ImagesDir string
+
+  // Layout arranges the files flat or in one folder per collection, with
+  // Aliases deciding how recipes appear in their other collections' folders.
+  Layout string
+  Aliases string
+
+  // Lint, when set, checks every recipe for implausible amounts and times.
+  Lint *Plausibility
+
+  // ReferenceValidator is the path of the reference recipemd command to check
+  // every written file with, see FindReferenceValidator.
+  ReferenceValidator string
+
+  // MinConfidence, when set, reports every ingredient line parsed with less
+  // confidence than this (see ParseIngredient).
+  MinConfidence float64
+
+  // DumpIR, when set, is a folder the extracted recipes are written to as
+  // JSON before anything else happens to them (see DumpRecipes).
+  DumpIR string
+
+  // Index writes an index.md table of every converted recipe.
+  Index bool
+
+  // file names by UUID for the site layouts
+  slugs map[string]string
+
+  // the manifest of the previous run, used to merge hand edits on reconversion
+  previous *Manifest
+}
+
+func NewConverter() *Converter {
+  return &Converter{
+    Renderer: RecipeMDRenderer{},
+    Normalizer: DefaultTextNormalizer(),
+    Snapshot: OptionsSnapshot{Version: ConverterVersion()},
+    OutputDir: outputDir,
+    FileMode: defaultFileMode,
+    ExportDir: ".",
+    PhotoDownload: DefaultPhotoDownloadOptions(),
+    ImagesDir: imagesDir,
+    Layout: LayoutFlat,
+    Aliases: AliasSymlink,
+    Charsets: DefaultCharsetPolicy(),
+  }
+}
+
+func (c *Converter) emit(kind ProgressKind, r Recipe, message string) {
+  if c.Progress == nil {
+    return
+  }
+  c.Progress <- ProgressEvent{
+    Kind: kind,
+    UUID: r.Metadata.UUID,
+    Title: r.Title,
+    Message: message,
+  }
+}
+
+func (c *Converter) Convert(reader io.Reader) error {
+  return c.ConvertContext(context.Background(), reader)
+}
+
+// ConvertContext is Convert, stopping between recipes once ctx is done.
+func (c *Converter) ConvertContext(ctx context.Context, reader io.Reader) error {
+  if err := CheckWritable(c.OutputDir); err != nil {
+    return err
+  }
+
+  recipes, err := c.extract(reader)
+  if err != nil {
+    return err
+  }
+  return c.writeAll(ctx, recipes)
+}
+
+// extract scrapes an export and cleans up its recipes, everything that
+// happens before the recipes are looked at together.
+func (c *Converter) extract(reader io.Reader) ([]Recipe, error) {
+  recipes, version, err := ScrapeExport(reader)
+  if err != nil {
+    return nil, err
+  }
+  c.emit(ExportDetected, Recipe{}, version.String())
+  return c.prepare(recipes)
+}
+
+// prepare is the part of extract after scraping, which edits the recipes in
+// place.
+func (c *Converter) prepare(recipes []Recipe) ([]Recipe, error) {
+  // every recipe needs an id of its own, or its file is named ".md" or
+  // overwritten by the next one
+  owners := map[string]string{}
+  for i, recipe := range recipes {
+    uuid := safeUUID(recipe.Metadata.UUID)
+    warning := ""
+    switch {
+    case recipe.Metadata.UUID == "":
+      uuid = fallbackUUID(recipe, owners)
+      warning = fmt.Sprintf("no recipe id, using %q", uuid)
+    case uuid == "":
+      uuid = fallbackUUID(recipe, owners)
+      fallthrough
+    case uuid != recipe.Metadata.UUID:
+      warning = fmt.Sprintf("unsafe recipe id %q replaced by %q", recipe.Metadata.UUID, uuid)
+    }
+    if owner, taken := owners[uuid]; taken {
+      duplicate := uuid
+      uuid = fallbackUUID(recipe, owners)
+      warning = fmt.Sprintf("recipe id %q is also used by %q, using %q", duplicate, owner, uuid)
+    }
+    owners[uuid] = recipe.Title
+    recipes[i].Metadata.UUID = uuid
+    if warning != "" {
+      c.emit(ConversionWarning, recipes[i], warning)
+    }
+  }
+  if c.DumpIR != "" {
+    if err := DumpRecipes(c.DumpIR, recipes); err != nil {
+      return nil, err
+    }
+  }
+
+  for i, recipe := range recipes {
+    c.emit(RecipeDiscovered, recipe, "")
+    for _, value := range recipe.Metadata.Unparsed {
+      c.emit(ConversionWarning, recipe, "couldn't read "+value)
+    }
+    if c.Normalizer != nil {
+      recipe = c.Normalizer.NormalizeRecipe(recipe)
+    }
+    recipe = ApplyTransforms(recipe, c.Transforms)
+    recipe = StripBrands(recipe, c.Brands)
+    if c.Charsets.Content == CharsetASCII {
+      editTextFields(&recipe, func(field string, text string) string {
+        return ToASCII(text)
+      })
+    }
+    if c.Classifier != nil {
+      var inferences []Inference
+      recipe, inferences = InferTags(recipe, c.Classifier)
+      for _, inference := range inferences {
+        c.emit(LintWarning, recipe, inference.String())
+      }
+    }
+    recipe.Metadata.Yield = DefaultYield(recipe, c.DefaultYields)
+    recipe = c.scale(recipe)
+    if c.DividedSplits {
+      recipe = AnnotateDivided(recipe)
+    }
+    if c.MakeAhead {
+      recipe = AnnotateMakeAhead(recipe)
+    }
+    if c.PreferUnits != UnitsAsIs {
+      for j, line := range recipe.IngredientLines {
+        recipe.IngredientLines[j] = PreferIngredientUnits(line, c.PreferUnits)
+      }
+    }
+    recipe = ConvertUnits(recipe, c.Units, c.Densities, c.Weigh)
+    if c.AnnotateTemperatures {
+      for j, line := range recipe.InstructionLines {
+        recipe.InstructionLines[j] = AnnotateTemperatures(line)
+      }
+    }
+    if recipe.exportDir == "" {
+      recipe.exportDir = c.ExportDir
+    }
+    recipes[i] = recipe
+  }
+
+  return recipes, nil
+}
+
+func (c *Converter) fileMode() os.FileMode {
+  if c.FileMode == 0 {
+    return defaultFileMode
+  }
+  return c.FileMode
+}
+
+func (c *Converter) scale(recipe Recipe) Recipe {
+  factor := c.Scale
+  if c.Servings > 0 {
+    var err error
+    if factor, err = ServingsFactor(recipe, c.Servings); err != nil {
+      c.emit(ConversionWarning, recipe, "not scaled: "+err.Error())
+      return recipe
+    }
+  }
+  if factor == 0 || factor == 1 {
+    return recipe
+  }
+  return ScaleRecipe(recipe, factor)
+}
+
+// writeAll writes the output for a set of extracted recipes.
+func (c *Converter) writeAll(ctx context.Context, recipes []Recipe) error {
+  selected := make([]Recipe, 0, len(recipes))
+  for _, recipe := range recipes {
+    if c.selected(recipe) {
+      selected = append(selected, recipe)
+    }
+  }
+  recipes, err := c.handleIncomplete(selected)
+  if err != nil {
+    return err
+  }
+  recipes = c.resolveDuplicates(recipes)
+  for i := range recipes {
+    recipes[i].Ingredients = ParseIngredients(recipes[i].IngredientLines)
+  }
+
+  if c.Collection != nil {
+    return c.writeCollection(recipes)
+  }
+
+  LinkRecipes(recipes)
+  c.resolvePaths(recipes)
+
+  manifest := NewManifest(c.Snapshot)
+  c.previous, err = ReadManifest(c.OutputDir)
+  if err != nil && !os.IsNotExist(err) {
+    return err
+  }
+  // fetched recipes aren't in any export, so they stay until deleted by hand
+  if c.previous != nil {
+    for uuid, entry := range c.previous.Recipes {
+      if c.Adding || entry.Fetched != "" {
+        manifest.Recipes[uuid] = entry
+      }
+    }
+  }
+
+  var watchdog *memoryWatchdog
+  if c.MemoryLimit > 0 {
+    watchdog = startMemoryWatchdog(c.MemoryLimit, time.Second)
+    defer watchdog.Stop()
+  }
+  degraded := false
+
+  index := make([]IndexEntry, 0, len(recipes))
+  for i := range recipes {
+    if err := ctx.Err(); err != nil {
+      return err
+    }
+    // let each recipe go once written, only the index keeps a copy
+    recipe := recipes[i]
+    recipes[i] = Recipe{}
+
+    if watchdog.Exceeded() && !degraded {
+      degraded = true
+      defer c.degrade()()
+      index = nil
+      c.emit(ConversionWarning, recipe, "memory use is over the limit, no longer resizing photos, verifying or building the index")
+    }
+
+    if reason := c.skipReason(recipe); reason != "" {
+      c.emit(RecipeSkipped, recipe, reason)
+      continue
+    }
+
+    switch c.Renderer.(type) {
+    case PDFRenderer:
+      // PDFs embed the photos from the export instead
+    case TextRenderer:
+      // and plain text has none
+    default:
+      recipe = c.processPhotos(recipe)
+    }
+
+    if err := c.write(recipe, manifest); err != nil {
+      c.emit(RecipeFailed, recipe, err.Error())
+      // the file from the last good run isn't removed, so don't prune it
+      if c.previous != nil {
+        if entry, exists := c.previous.Recipes[recipe.Metadata.UUID]; exists {
+          manifest.Recipes[recipe.Metadata.UUID] = entry
+        }
+      }
+      continue
+    }
+    path := filepath.Join(c.recipeDir(recipe), c.fileName(recipe))
+    c.emit(RecipeConverted, recipe, path)
+    if !degraded {
+      index = append(index, IndexEntry{recipe, path})
+    }
+
+    if c.Lint != nil {
+      for _, finding := range LintRecipe(recipe, *c.Lint) {
+        c.emit(LintWarning, recipe, finding)
+      }
+    }
+
+    if c.MinConfidence > 0 {
+      for _, ingredient := range recipe.Ingredients {
+        if ingredient.Confidence < c.MinConfidence {
+          c.emit(UncertainIngredient, recipe, fmt.Sprintf("%q (%.1f)", ingredient.Raw, ingredient.Confidence))
+        }
+      }
+    }
+
+    if c.Verify && c.Renderer.Ext() == "md" {
+      c.verify(recipe)
+    }
+
+    if c.ReferenceValidator != "" && c.Renderer.Ext() == "md" {
+      c.referenceValidate(recipe)
+    }
+  }
+
+  if c.Index && !degraded {
+    if err := WriteIndex(c.OutputDir, index, c.formatOptions()); err != nil {
+      return err
+    }
+  }
+  if indexer, ok := c.Renderer.(IndexRenderer); ok && !degraded {
+    content, err := indexer.RenderIndex(index)
+    if err != nil {
+      return err
+    }
+    if err := writeFile(filepath.Join(c.OutputDir, indexer.IndexFileName()), content, c.fileMode()); err != nil {
+      return err
+    }
+  }
+
+  if c.Prune {
+    c.pruneRemoved(manifest)
+  }
+  if err := manifest.Write(c.OutputDir); err != nil {
+    return err
+  }
+  return PruneBases(c.OutputDir, manifest)
+}
+
+// pruneRemoved deletes the files of recipes that were converted before but
+// not this time, because they were deleted in Recipe Keeper or filtered out.
+// Files edited by hand since are kept, and stay in the manifest.
+func (c *Converter) pruneRemoved(manifest *Manifest) {
+  if c.previous == nil {
+    return
+  }
+  for uuid, entry := range c.previous.Recipes {
+    if _, exists := manifest.Recipes[uuid]; exists {
+      continue
+    }
+    recipe := Recipe{Title: entry.Title, Metadata: RecipeMetadata{UUID: uuid}}
+    content, err := os.ReadFile(entry.Path)
+    if os.IsNotExist(err) {
+      continue
+    } else if err != nil {
+      c.emit(ConversionWarning, recipe, err.Error())
+      continue
+    }
+    if ContentHash(content) != entry.Hash {
+      c.emit(ConversionWarning, recipe, "no longer converted, but "+entry.Path+" was edited by hand so it is kept")
+      manifest.Recipes[uuid] = entry
+      continue
+    }
+    if err := os.Remove(entry.Path); err != nil {
+      c.emit(ConversionWarning, recipe, err.Error())
+      continue
+    }
+    c.emit(RecipeRemoved, recipe, entry.Path)
+  }
+}
+
+// selected tells whether a recipe passes the FavoritesOnly and OnlyTags
+// filters. Recipes that don't are left out quietly, unlike skipped ones.
+func (c *Converter) selected(recipe Recipe) bool {
+  if c.FavoritesOnly && !recipe.Metadata.Favorited {
+    return false
+  }
+  if len(c.OnlyTags) == 0 {
+    return true
+  }
+  for _, tag := range recipe.tags(CharsetUnicode) {
+    for _, wanted := range c.OnlyTags {
+      if tag == tagName(wanted) {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+func (c *Converter) skipReason(recipe Recipe) string {
+  if c.Review != nil && c.Review.Excluded(recipe) {
+    return "excluded in review"
+  }
+  if c.SkipJunk {
+    return JunkReason(recipe)
+  }
+  return ""
+}
+
+func (c *Converter) writeCollection(recipes []Recipe) error {
+  kept := make([]Recipe, 0, len(recipes))
+  for _, recipe := range recipes {
+    if reason := c.skipReason(recipe); reason != "" {
+      c.emit(RecipeSkipped, recipe, reason)
+      continue
+    }
+    kept = append(kept, recipe)
+  }
+
+  content, err := c.Collection.RenderAll(kept)
+  if err != nil {
+    return err
+  }
+  if err := os.MkdirAll(c.OutputDir, 0755); err != nil {
+    return err
+  }
+  if err := writeFile(filepath.Join(c.OutputDir, c.Collection.FileName()), content, c.fileMode()); err != nil {
+    return err
+  }
+
+  for _, recipe := range kept {
+    c.emit(RecipeConverted, recipe, c.Collection.FileName())
+  }
+  return nil
+}
+
+func (c *Converter) write(recipe Recipe, manifest *Manifest) error {
+  content, err := c.Renderer.Render(recipe)
+  if err != nil {
+    return err
+  }
+  var changes []ChangeEntry
+  if c.Changelog && c.Renderer.Ext() == "md" {
+    changes = c.changelog(recipe, content, manifest)
+    content = append(content, formatChangelog(changes, c.formatOptions())...)
+  }
+  if c.Stamp && c.Renderer.Ext() == "md" {
+    content = append(content, c.Snapshot.Comment()...)
+  }
+
+  generated := content
+  path := c.outputPath(recipe)
+  if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+    return err
+  }
+  // hand edits are only kept in RecipeMD, merging text into a PDF breaks it
+  mergeable := c.Renderer.Ext() == "md"
+  if len(c.UpdateFields) > 0 && mergeable {
+    existing, err := os.ReadFile(path)
+    if err == nil {
+      patched, kept := PatchFields(string(existing), string(content), c.UpdateFields)
+      for _, field := range kept {
+        c.emit(ConversionWarning, recipe, "kept hand edited "+field)
+      }
+      content = []byte(patched)
+    } else if !os.IsNotExist(err) {
+      return err
+    }
+  } else if c.previous != nil && mergeable {
+    if entry, exists := c.previous.Recipes[recipe.Metadata.UUID]; exists {
+      content, err = c.reconcile(recipe, path, generated, entry)
+      if err != nil {
+        return err
+      }
+    }
+  }
+
+  if err := writeFile(path, content, c.fileMode()); err != nil {
+    return err
+  }
+  // hand edits were made later than the export says, so they keep their time
+  if changed := recipe.Metadata.LastChanged(); c.FileTimes && !changed.IsZero() && bytes.Equal(content, generated) {
+    if err := os.Chtimes(path, changed, changed); err != nil {
+      return err
+    }
+  }
+  if err := StoreBase(c.OutputDir, generated); err != nil {
+    return err
+  }
+
+  manifest.Record(recipe, path, generated, changes)
+  return c.writeAliases(recipe)
+}
+
+// reconcile decides what to write over a file converted on a previous run:
+// untouched files are simply replaced, hand edits are kept when the export did
+// not change, and when both changed the two sets of changes are merged.
+func (c *Converter) reconcile(recipe Recipe, path string, theirs []byte, entry ManifestEntry) ([]byte, error) {
+  mine, err := os.ReadFile(path)
+  if os.IsNotExist(err) {
+    return theirs, nil
+  } else if err != nil {
+    return nil, err
+  }
+
+  switch {
+  case ContentHash(mine) == entry.Hash, bytes.Equal(mine, theirs):
+    return theirs, nil
+  case ContentHash(theirs) == entry.Hash:
+    return mine, nil
+  }
+
+  base, err := LoadBase(c.OutputDir, entry.Hash)
+  if err != nil && !os.IsNotExist(err) {
+    return nil, err
+  }
+
+  merged, conflicted := Merge3(string(base), string(mine), string(theirs))
+  if conflicted {
+    c.emit(ConversionWarning, recipe, "both the export and "+path+" changed, resolve the conflict markers by hand")
+  }
+  return []byte(merged), nil
+}
+
+func (c *Converter) verify(recipe Recipe) {
+  if c.Renderer.Ext() != "md" {
+    return
+  }
+
+  written, err := ParseRecipeMDFile(c.outputPath(recipe), c.formatOptions().Labels)
+  if err != nil {
+    c.emit(ConversionWarning, recipe, "verify: "+err.Error())
+    return
+  }
+
+  for _, problem := range CompareRecipes(recipe, written) {
+    c.emit(ConversionWarning, recipe, "verify: "+problem)
+  }
+}
+
+// CompareRecipes lists the key fields that differ between a source recipe and
+// the one read back from its output.
+func CompareRecipes(source Recipe, written Recipe) []string {
+  problems := make([]string, 0)
+
+  if strings.TrimSpace(source.Title) != written.Title {
+    problems = append(problems, fmt.Sprintf("title %q was written as %q", source.Title, written.Title))
+  }
+  if len(source.IngredientLines) != len(written.IngredientLines) {
+    problems = append(problems, fmt.Sprintf("%d ingredients were written as %d", len(source.IngredientLines), len(written.IngredientLines)))
+  }
+  if strings.TrimSpace(source.Metadata.Yield) != written.Metadata.Yield {
+    problems = append(problems, fmt.Sprintf("yield %q was written as %q", source.Metadata.Yield, written.Metadata.Yield))
+  }
+
+  return problems
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+  "bytes"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "testing/iotest"
+)
+
+func TestReconvertEditedPDF(t *testing.T) {
+  dir := t.TempDir()
+  convert := func(export string) {
+    converter := NewConverter()
+    converter.OutputDir = dir
+    converter.Renderer = PDFRenderer{}
+    if err := converter.Convert(strings.NewReader(export)); err != nil {
+      t.Fatal(err)
+    }
+  }
+  convert(servedExport)
+
+  matches, _ := filepath.Glob(filepath.Join(dir, "*.pdf"))
+  if len(matches) != 1 {
+    t.Fatalf("converted to %q, want one PDF", matches)
+  }
+  path := matches[0]
+  edited, _ := os.ReadFile(path)
+  edited = append(edited, "\n% annotated by hand\n"...)
+  if err := os.WriteFile(path, edited, 0644); err != nil {
+    t.Fatal(err)
+  }
+
+  convert(strings.Replace(servedExport, "Mix and fry.", "Mix and fry in butter.", 1))
+  content, _ := os.ReadFile(path)
+  if !bytes.HasPrefix(content, []byte("%PDF")) || bytes.Contains(content, []byte("<<<<<<<")) || bytes.Contains(content, []byte("annotated by hand")) {
+    t.Errorf("the reconverted PDF was merged with the edited one")
+  }
+}
+
+func recipeKeeperExport(titles ...string) string {
+  var export strings.Builder
+  export.WriteString("<html><head><title>Recipe Keeper</title></head><body>\n")
+  for i, title := range titles {
+    export.WriteString(`<div class="recipe-details">
+<meta itemprop="recipeId" content="id-` + string(rune('a'+i)) + `">
+<h2 itemprop="name">` + title + `</h2>
+<div class="recipe-details-extra">recipe-details are below</div>
+<div itemprop="recipeIngredients"><p>1 egg</p></div>
+<div itemprop="recipeDirections"><p>Cook it.</p></div>
+</div>
+`)
+  }
+  export.WriteString("</body></html>\n")
+  return export.String()
+}
+
+func TestParseRecipeKeeperHTMLARecipeAtATime(t *testing.T) {
+  export := recipeKeeperExport("Pancakes", "Omelette", "Frittata")
+  var titles []string
+  version, err := RecipeKeeperHTML{}.ParseEach(iotest.OneByteReader(strings.NewReader(export)), func(recipe Recipe) error {
+    titles = append(titles, recipe.Title+" "+recipe.Metadata.UUID)
+    return nil
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  if version != ExportCurrent {
+    t.Errorf("version = %v, want the current export", version)
+  }
+  if got := strings.Join(titles, ", "); got != "Pancakes id-a, Omelette id-b, Frittata id-c" {
+    t.Errorf("read %q", got)
+  }
+
+  if _, err := (RecipeKeeperHTML{}).ParseEach(strings.NewReader("<html><head><title>Recipe Keeper</title></head></html>"), func(Recipe) error { return nil }); err == nil || !strings.Contains(err.Error(), "empty") {
+    t.Errorf("an empty export gave %v", err)
+  }
+}
+
+func TestScrapeExportRereadsFiles(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "recipes.html")
+  if err := os.WriteFile(path, []byte(recipeKeeperExport("Pancakes", "Omelette")), 0644); err != nil {
+    t.Fatal(err)
+  }
+  file, err := os.Open(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer file.Close()
+  recipes, _, err := ScrapeExport(file)
+  if err != nil || len(recipes) != 2 {
+    t.Errorf("read %d recipes, %v", len(recipes), err)
+  }
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// CronSchedule is a standard five field cron expression: minute, hour, day of
+// month, month and day of week. Fields take *, numbers, ranges (1-5), lists
+// (1,15) and steps (*/15, 8-18/2); weekdays run from 0 (Sunday) to 7 (Sunday
+// again). @hourly, @daily, @weekly and @monthly are understood too.
+type CronSchedule struct {
+  minutes, hours, days, months, weekdays uint64
+  // as in cron, when both days and weekdays are restricted either may match
+  anyDay, anyWeekday bool
+}
+
+var cronShortcuts = map[string]string{
+  "@hourly": "0 * * * *",
+  "@daily": "0 0 * * *",
+  "@midnight": "0 0 * * *",
+  "@weekly": "0 0 * * 0",
+  "@monthly": "0 0 1 * *",
+}
+
+func ParseCronSchedule(expression string) (*CronSchedule, error) {
+  if shortcut, exists := cronShortcuts[strings.TrimSpace(expression)]; exists {
+    expression = shortcut
+  }
+  fields := strings.Fields(expression)
+  if len(fields) != 5 {
+    return nil, fmt.Errorf("cron schedule %q must have 5 fields: minute hour day month weekday", expression)
+  }
+
+  schedule := &CronSchedule{anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+  for _, field := range []struct {
+    bits *uint64
+    text string
+    name string
+    min, max int
+  }{
+    {&schedule.minutes, fields[0], "minute", 0, 59},
+    {&schedule.hours, fields[1], "hour", 0, 23},
+    {&schedule.days, fields[2], "day", 1, 31},
+    {&schedule.months, fields[3], "month", 1, 12},
+    {&schedule.weekdays, fields[4], "weekday", 0, 7},
+  } {
+    bits, err := parseCronField(field.text, field.min, field.max)
+    if err != nil {
+      return nil, fmt.Errorf("cron schedule %q: %s: %w", expression, field.name, err)
+    }
+    *field.bits = bits
+  }
+  // 7 is another name for Sunday
+  if schedule.weekdays&(1<<7) != 0 {
+    schedule.weekdays |= 1
+  }
+  return schedule, nil
+}
+
+func parseCronField(text string, min int, max int) (uint64, error) {
+  var bits uint64
+  for _, part := range strings.Split(text, ",") {
+    step := 1
+    if rangePart, stepText, found := strings.Cut(part, "/"); found {
+      var err error
+      if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+        return 0, fmt.Errorf("invalid step %q", stepText)
+      }
+      part = rangePart
+    }
+
+    from, to := min, max
+    if part != "*" {
+      fromText, toText, isRange := strings.Cut(part, "-")
+      var err error
+      if from, err = strconv.Atoi(fromText); err != nil {
+        return 0, fmt.Errorf("invalid value %q", part)
+      }
+      to = from
+      if isRange {
+        if to, err = strconv.Atoi(toText); err != nil {
+          return 0, fmt.Errorf("invalid value %q", part)
+        }
+      } else if step > 1 {
+        // "5/15" is every 15 from 5 on
+        to = max
+      }
+    }
+    if from < min || to > max || from > to {
+      return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+    }
+    for value := from; value <= to; value += step {
+      bits |= 1 << uint(value)
+    }
+  }
+  return bits, nil
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+  day := s.days&(1<<uint(t.Day())) != 0
+  weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+  switch {
+  case s.anyDay && s.anyWeekday:
+    return true
+  case s.anyDay:
+    return weekday
+  case s.anyWeekday:
+    return day
+  }
+  return day || weekday
+}
+
+// Next is the first time after t the schedule fires, in t's time zone. It is
+// the zero time for schedules that never fire, like the 31st of February.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+  t = t.Truncate(time.Minute).Add(time.Minute)
+  limit := t.AddDate(5, 0, 0)
+  for t.Before(limit) {
+    switch {
+    case s.months&(1<<uint(t.Month())) == 0:
+      t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+    case !s.matchesDay(t):
+      t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+    case s.hours&(1<<uint(t.Hour())) == 0:
+      t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+    case s.minutes&(1<<uint(t.Minute())) == 0:
+      t = t.Add(time.Minute)
+    default:
+      return t
+    }
+  }
+  return time.Time{}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+  "bytes"
+  "encoding/csv"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// CSVRenderer writes the metadata of the whole collection as one spreadsheet
+// friendly file. Times are in minutes and lists are separated by semicolons.
+type CSVRenderer struct{}
+
+func minutes(d time.Duration) string {
+  if d <= 0 {
+    return ""
+  }
+  return strconv.Itoa(int(d.Round(time.Minute) / time.Minute))
+}
+
+func (CSVRenderer) RenderAll(recipes []Recipe) ([]byte, error) {
+  var buffer bytes.Buffer
+  writer := csv.NewWriter(&buffer)
+
+  header := []string{
+    "UUID", "Title", "Rating", "Favorited", "Categories", "Courses", "Collections",
+    "Source", "Yield", "Prep Time", "Cook Time", "Rest Time", "Total Time",
+  }
+  for _, value := range (RecipeNutrition{}).Values() {
+    header = append(header, value.Label)
+  }
+  if err := writer.Write(header); err != nil {
+    return nil, err
+  }
+
+  for _, r := range recipes {
+    rating := ""
+    if r.Metadata.Rating > 0 {
+      rating = strconv.Itoa(r.Metadata.Rating)
+    }
+
+    row := []string{
+      r.Metadata.UUID,
+      r.Title,
+      rating,
+      strconv.FormatBool(r.Metadata.Favorited),
+      strings.Join(r.Metadata.CategoryList, "; "),
+      strings.Join(r.Metadata.CourseList, "; "),
+      strings.Join(r.Metadata.CollectionList, "; "),
+      r.Metadata.Source,
+      r.Metadata.Yield,
+      minutes(r.Metadata.PrepTime),
+      minutes(r.Metadata.CookTime),
+      minutes(r.Metadata.RestTime),
+      minutes(r.Metadata.TotalTime),
+    }
+    for _, value := range r.Nutrition.Values() {
+      row = append(row, value.Value)
+    }
+    if err := writer.Write(row); err != nil {
+      return nil, err
+    }
+  }
+
+  writer.Flush()
+  return buffer.Bytes(), writer.Error()
+}
+
+func (CSVRenderer) FileName() string {
+  return "recipes.csv"
+}
+
+func init() {
+  RegisterFormat(OutputFormat{Name: "csv", Exts: []string{"csv"}, New: func(RenderSettings) (Renderer, CollectionRenderer, error) {
+    return nil, CSVRenderer{}, nil
+  }})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "encoding/csv"
+  "fmt"
+  "io"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Recipe Keeper's CSV export has the text of the recipes but not their
+// photos. Columns are matched by name, ignoring case, spaces and
+// punctuation, so the itemprop names of the HTML export, the headers of our
+// own csv format and the spreadsheet-style ones all work.
+
+var csvColumns = map[string]string{
+  "recipeid": "id", "id": "id", "uuid": "id",
+  "name": "title", "title": "title", "recipename": "title", "recipe": "title",
+  "description": "description", "recipedescription": "description",
+  "source": "source", "recipesource": "source", "url": "source",
+  "yield": "yield", "recipeyield": "yield", "servings": "yield", "serves": "yield",
+  "categories": "categories", "category": "categories", "recipecategory": "categories",
+  "courses": "courses", "course": "courses", "recipecourse": "courses",
+  "collections": "collections", "collection": "collections", "recipecollection": "collections",
+  "ingredients": "ingredients", "recipeingredients": "ingredients",
+  "directions": "instructions", "instructions": "instructions", "recipedirections": "instructions", "method": "instructions",
+  "notes": "notes", "recipenotes": "notes",
+  "rating": "rating", "reciperating": "rating",
+  "favorited": "favorite", "favorite": "favorite", "favourite": "favorite", "recipeisfavourite": "favorite", "isfavourite": "favorite",
+  "dateadded": "created", "datecreated": "created", "created": "created",
+  "datemodified": "modified", "modified": "modified", "lastmodified": "modified",
+  "preptime": "prep", "cooktime": "cook", "resttime": "rest", "totaltime": "total",
+}
+
+var csvHeaderRe = regexp.MustCompile(`[^a-z]`)
+
+func csvColumn(header string) string {
+  key := csvHeaderRe.ReplaceAllString(strings.ToLower(header), "")
+  if column, exists := csvColumns[key]; exists {
+    return column
+  }
+  for _, value := range (RecipeNutrition{}).Values() {
+    label := csvHeaderRe.ReplaceAllString(strings.ToLower(value.Label), "")
+    if key == label || key == "recipenut"+label {
+      return "nutrition:" + value.Label
+    }
+  }
+  return ""
+}
+
+// parseCSVDuration reads times as minutes, ISO 8601 ("PT1H5M") or Go
+// durations ("1h5m").
+func parseCSVDuration(text string) time.Duration {
+  text = strings.TrimSpace(text)
+  if minutes, err := strconv.Atoi(text); err == nil {
+    return time.Duration(minutes) * time.Minute
+  }
+  if strings.HasPrefix(strings.ToUpper(text), "P") {
+    duration, _ := ParseISODuration(strings.ToUpper(text))
+    return duration
+  }
+  duration, _ := time.ParseDuration(text)
+  return duration
+}
+
+// csvLines splits a multi line cell into its non-empty lines.
+func csvLines(text string) []string {
+  lines := make([]string, 0)
+  for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+    if line = strings.TrimSpace(line); line != "" {
+      lines = append(lines, line)
+    }
+  }
+  return lines
+}
+
+// csvList splits a list cell on semicolons, or commas when there are none.
+func csvList(text string) []string {
+  separator := ";"
+  if !strings.Contains(text, ";") {
+    separator = ","
+  }
+  list := make([]string, 0)
+  for _, item := range strings.Split(text, separator) {
+    if item = strings.TrimSpace(item); item != "" {
+      list = append(list, item)
+    }
+  }
+  return list
+}
+
+// CSVSource reads CSV exports. It recognizes them by a header row with a
+// title column, as ScrapeCSVExport needs one.
+type CSVSource struct{}
+
+func (CSVSource) Detect(r io.Reader) bool {
+  head := bytes.TrimSpace(bytes.TrimPrefix(sniff(r), []byte("\xef\xbb\xbf")))
+  if len(head) == 0 || head[0] == '<' || head[0] == '{' || head[0] == '[' {
+    return false
+  }
+  records := csv.NewReader(bytes.NewReader(head))
+  records.LazyQuotes = true
+  header, err := records.Read()
+  if err != nil || len(header) < 2 {
+    return false
+  }
+  for _, name := range header {
+    if csvColumn(name) == "title" {
+      return true
+    }
+  }
+  return false
+}
+
+func (CSVSource) Parse(r io.Reader) ([]Recipe, error) {
+  return ScrapeCSVExport(r)
+}
+
+// ScrapeCSVExport reads the recipes of a CSV export. Recipes without an id
+// column get one made from their title, so they keep their file names from
+// one conversion to the next.
+func ScrapeCSVExport(reader io.Reader) ([]Recipe, error) {
+  records := csv.NewReader(reader)
+  records.FieldsPerRecord = -1
+  header, err := records.Read()
+  if err == io.EOF {
+    return nil, fmt.Errorf("%w: the CSV file is empty", ErrNoRecipes)
+  } else if err != nil {
+    return nil, err
+  }
+
+  columns := make([]string, len(header))
+  hasTitle := false
+  for i, name := range header {
+    columns[i] = csvColumn(name)
+    hasTitle = hasTitle || columns[i] == "title"
+  }
+  if !hasTitle {
+    return nil, fmt.Errorf("%w: the CSV file has no title or name column", ErrNoRecipes)
+  }
+
+  recipes := make([]Recipe, 0)
+  ids := map[string]int{}
+  for {
+    record, err := records.Read()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return nil, err
+    }
+
+    recipe := Recipe{}
+    for i, value := range record {
+      if i >= len(columns) {
+        break
+      }
+      value = strings.TrimSpace(value)
+      switch column := columns[i]; column {
+      case "id":
+        recipe.Metadata.UUID = value
+      case "title":
+        recipe.Title = value
+      case "description":
+        recipe.Description = value
+      case "source":
+        recipe.Metadata.Source = value
+      case "yield":
+        recipe.Metadata.Yield = value
+      case "categories":
+        recipe.Metadata.CategoryList = csvList(value)
+      case "courses":
+        recipe.Metadata.CourseList = csvList(value)
+      case "collections":
+        recipe.Metadata.CollectionList = csvList(value)
+      case "ingredients":
+        recipe.IngredientLines = csvLines(value)
+      case "instructions":
+        recipe.InstructionLines = csvLines(value)
+      case "notes":
+        recipe.NotesLines = csvLines(value)
+      case "rating":
+        recipe.Metadata.Rating, _ = strconv.Atoi(value)
+      case "favorite":
+        switch strings.ToLower(value) {
+        case "true", "yes", "1", "x":
+          recipe.Metadata.Favorited = true
+        }
+      case "created":
+        recipe.Metadata.Created, _ = ParseExportDate(value)
+      case "modified":
+        recipe.Metadata.Modified, _ = ParseExportDate(value)
+      case "prep":
+        recipe.Metadata.PrepTime = parseCSVDuration(value)
+      case "cook":
+        recipe.Metadata.CookTime = parseCSVDuration(value)
+      case "rest":
+        recipe.Metadata.RestTime = parseCSVDuration(value)
+      case "total":
+        recipe.Metadata.TotalTime = parseCSVDuration(value)
+      default:
+        if strings.HasPrefix(column, "nutrition:") {
+          recipe.Nutrition.Set(strings.TrimPrefix(column, "nutrition:"), value)
+        }
+      }
+    }
+    if recipe.Title == "" {
+      continue
+    }
+    if recipe.Metadata.UUID == "" {
+      id := fmt.Sprintf("csv-%x", sha256.Sum256([]byte(recipe.Title)))[:20]
+      // recipes of the same name are told apart by their order
+      if ids[id]++; ids[id] > 1 {
+        id = fmt.Sprintf("%s-%d", id, ids[id])
+      }
+      recipe.Metadata.UUID = id
+    }
+    if recipe.Metadata.TotalTime == 0 {
+      recipe.Metadata.TotalTime = recipe.Metadata.PrepTime + recipe.Metadata.CookTime
+    }
+    recipe.Ingredients = ParseIngredients(recipe.IngredientLines)
+    recipes = append(recipes, recipe)
+  }
+
+  if len(recipes) == 0 {
+    return nil, fmt.Errorf("%w: the CSV file has no rows with a title", ErrNoRecipes)
+  }
+  return recipes, nil
+}
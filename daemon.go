@@ -0,0 +1,188 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "flag"
+  "fmt"
+  "log"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+)
+
+// The daemon runs the whole sync on a schedule: it picks the newest export
+// from the folder Recipe Keeper saves them to, converts it with --prune so
+// deleted recipes go away too, and commits the result when the output folder
+// is in a git repository, as --git-commit does.
+// Exports that didn't change since the last run are not converted again.
+
+// NewestExport finds the most recently saved export in dir: a zip, an HTML
+// or CSV file, or an unpacked export folder. A dir that is an unpacked export
+// itself is returned as is.
+func NewestExport(dir string) (string, time.Time, error) {
+  if info, err := os.Stat(filepath.Join(dir, "recipes.html")); err == nil {
+    return dir, info.ModTime(), nil
+  }
+
+  entries, err := os.ReadDir(dir)
+  if err != nil {
+    return "", time.Time{}, err
+  }
+  newest, newestTime := "", time.Time{}
+  for _, entry := range entries {
+    path := filepath.Join(dir, entry.Name())
+    info, err := os.Stat(path)
+    if err != nil {
+      continue
+    }
+    if info.IsDir() {
+      // the folder's own time changes with what's added to it, not the export
+      if info, err = os.Stat(filepath.Join(path, "recipes.html")); err != nil {
+        continue
+      }
+    } else {
+      switch strings.ToLower(filepath.Ext(path)) {
+      case ".zip", ".html", ".htm", ".csv":
+      default:
+        continue
+      }
+    }
+    if info.ModTime().After(newestTime) {
+      newest, newestTime = path, info.ModTime()
+    }
+  }
+  if newest == "" {
+    return "", time.Time{}, fmt.Errorf("%w: no export in %s", ErrNoRecipes, dir)
+  }
+  return newest, newestTime, nil
+}
+
+// daemonStatus is what /healthz reports.
+type daemonStatus struct {
+  mu sync.Mutex
+
+  Started time.Time `json:"started"`
+  NextRun time.Time `json:"next_run"`
+  LastRun time.Time `json:"last_run"`
+  LastSuccess time.Time `json:"last_success"`
+  LastError string `json:"last_error,omitempty"`
+  Export string `json:"export,omitempty"`
+}
+
+func (s *daemonStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  w.Header().Set("Content-Type", "application/json")
+  if s.LastError != "" {
+    w.WriteHeader(http.StatusServiceUnavailable)
+  }
+  json.NewEncoder(w).Encode(s)
+}
+
+func (s *daemonStatus) update(edit func(s *daemonStatus)) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  edit(s)
+}
+
+func runDaemon(args []string) error {
+  flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+  schedule := flags.String("schedule", "0 3 * * *", "when to look for a new export, as a cron expression")
+  exports := flags.String("exports", filepath.Dir(filepath.Dir(defaultExportPath)), "folder the exports are saved to, the newest one is converted")
+  health := flags.String("health", "", "address to serve the status on at /healthz, e.g. 127.0.0.1:8081")
+  now := flags.Bool("now", false, "also convert once right away")
+  commit := flags.Bool("commit", true, "commit the converted files when the output directory is in a git repository")
+  flags.Usage = func() {
+    fmt.Fprintf(flags.Output(), "usage: recipekeeper2recipemd daemon [options] [-- convert options]\n")
+    flags.PrintDefaults()
+  }
+  flags.Parse(args)
+  convertArgs := flags.Args()
+
+  cron, err := ParseCronSchedule(*schedule)
+  if err != nil {
+    return err
+  }
+  if cron.Next(time.Now()).IsZero() {
+    return fmt.Errorf("cron schedule %q never fires", *schedule)
+  }
+  // check the convert options before waiting hours for the first run
+  if base, _, err := newConvertRuns(append(convertArgs[:len(convertArgs):len(convertArgs)], os.DevNull)); err != nil {
+    return err
+  } else if len(base.paths) != 1 {
+    return errors.New("the export is found in the -exports folder, don't pass one to the convert options")
+  } else if base.watch {
+    return errors.New("--watch can't be combined with the daemon, it has its own schedule")
+  }
+
+  status := &daemonStatus{Started: time.Now()}
+  if *health != "" {
+    mux := http.NewServeMux()
+    mux.Handle("/healthz", status)
+    go func() {
+      log.Fatal(http.ListenAndServe(*health, mux))
+    }()
+    log.Printf("status on http://%s/healthz", *health)
+  }
+
+  var lastExport string
+  var lastTime time.Time
+  convertNewest := func() error {
+    path, modTime, err := NewestExport(*exports)
+    if err != nil {
+      return err
+    }
+    if path == lastExport && modTime.Equal(lastTime) {
+      log.Printf("%s hasn't changed since the last run", path)
+      return nil
+    }
+
+    log.Printf("converting %s", path)
+    base, runs, err := newConvertRuns(append(convertArgs[:len(convertArgs):len(convertArgs)], path))
+    if err != nil {
+      return err
+    }
+    for _, run := range runs {
+      run.converter.Prune = true
+      if *commit && IsGitRepo(run.converter.OutputDir) {
+        run.gitCommit = true
+      }
+    }
+    if err := convertAndCommit(runs, base.paths); err != nil {
+      return err
+    }
+    lastExport, lastTime = path, modTime
+    status.update(func(s *daemonStatus) { s.Export = path })
+    return nil
+  }
+  run := func() {
+    err := convertNewest()
+    status.update(func(s *daemonStatus) {
+      s.LastRun = time.Now()
+      s.LastError = ""
+      if err != nil {
+        s.LastError = err.Error()
+      } else {
+        s.LastSuccess = s.LastRun
+      }
+    })
+    if err != nil {
+      log.Printf("error: %s", err)
+    }
+  }
+
+  if *now {
+    run()
+  }
+  for {
+    next := cron.Next(time.Now())
+    status.update(func(s *daemonStatus) { s.NextRun = next })
+    log.Printf("next run at %s", next.Format(time.RFC1123))
+    time.Sleep(time.Until(next))
+    run()
+  }
+}
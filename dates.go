@@ -0,0 +1,55 @@
+package main
+
+import (
+  "strings"
+  "time"
+)
+
+// Not every export has them, but when a recipe carries the date it was added
+// and last changed they end up in RecipeMetadata.Created and Modified.
+
+var exportDateLayouts = []string{
+  time.RFC3339,
+  "2006-01-02T15:04:05",
+  "2006-01-02 15:04:05",
+  "2006-01-02",
+}
+
+// ParseExportDate reads the dates of the export. Those without a time zone
+// are taken as UTC.
+func ParseExportDate(text string) (time.Time, bool) {
+  text = strings.TrimSpace(text)
+  for _, layout := range exportDateLayouts {
+    if date, err := time.Parse(layout, text); err == nil {
+      return date, true
+    }
+  }
+  return time.Time{}, false
+}
+
+// ItemPropDate is the first of the itemprops holding a date, either as the
+// content of a meta tag or the datetime of a time tag.
+func (s RecipeNode) ItemPropDate(propNames ...string) time.Time {
+  for _, propName := range propNames {
+    text := s.ItemPropContentOr(propName, "")
+    if text == "" {
+      text = s.ItemPropAttrOr("time", propName, "datetime", "")
+    }
+    if date, ok := ParseExportDate(text); ok {
+      return date
+    }
+  }
+  return time.Time{}
+}
+
+// LastChanged is when the recipe was last modified, or else added.
+func (m RecipeMetadata) LastChanged() time.Time {
+  if !m.Modified.IsZero() {
+    return m.Modified
+  }
+  return m.Created
+}
+
+func formatExportDate(date time.Time) string {
+  return date.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "strings"
+)
+
+// Densities are grams per millilitre of ingredients, to turn a volume of
+// them into a weight. Names are matched against the whole ingredient name,
+// so "brown sugar" doesn't count as sugar.
+type Densities map[string]float64
+
+// Mostly from the usual baking charts, rounded. Liquids like milk, oil or
+// honey are left out, they are measured by volume in metric kitchens too.
+var defaultDensities = Densities{
+  "flour": 0.53,
+  "all-purpose flour": 0.53,
+  "all purpose flour": 0.53,
+  "plain flour": 0.53,
+  "bread flour": 0.51,
+  "cake flour": 0.5,
+  "self-raising flour": 0.48,
+  "self-rising flour": 0.48,
+  "whole wheat flour": 0.48,
+  "wholemeal flour": 0.48,
+  "almond flour": 0.41,
+  "ground almonds": 0.41,
+  "cornstarch": 0.47,
+  "cornflour": 0.47,
+  "sugar": 0.85,
+  "granulated sugar": 0.85,
+  "white sugar": 0.85,
+  "caster sugar": 0.85,
+  "brown sugar": 0.93,
+  "light brown sugar": 0.93,
+  "dark brown sugar": 0.93,
+  "powdered sugar": 0.51,
+  "icing sugar": 0.51,
+  "confectioners sugar": 0.51,
+  "confectioners' sugar": 0.51,
+  "butter": 0.96,
+  "peanut butter": 1.14,
+  "rice": 0.78,
+  "rolled oats": 0.38,
+  "oats": 0.38,
+  "cocoa powder": 0.42,
+  "chocolate chips": 0.72,
+  "raisins": 0.63,
+  "grated parmesan": 0.42,
+  "salt": 1.22,
+  "table salt": 1.22,
+  "kosher salt": 0.57,
+  "baking powder": 0.81,
+  "baking soda": 1.22,
+}
+
+// DefaultDensities is a copy of the built in densities.
+func DefaultDensities() Densities {
+  densities := Densities{}
+  for name, density := range defaultDensities {
+    densities[name] = density
+  }
+  return densities
+}
+
+// LoadDensities reads a JSON object of {"ingredient": grams per ml} adding
+// to, or overriding, the built in densities.
+func LoadDensities(path string) (Densities, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  custom := map[string]float64{}
+  if err := json.Unmarshal(content, &custom); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+
+  densities := DefaultDensities()
+  for name, density := range custom {
+    if density <= 0 {
+      return nil, fmt.Errorf("%s: density of %q must be positive, got %g", path, name, density)
+    }
+    densities[strings.ToLower(strings.TrimSpace(name))] = density
+  }
+  return densities, nil
+}
+
+// Lookup finds the density of an ingredient by its name, leaving out what
+// comes after a comma ("flour, sifted") and trying it without a plural s.
+// Without any densities the built in ones are used.
+func (d Densities) Lookup(name string) (float64, bool) {
+  if d == nil {
+    d = defaultDensities
+  }
+  name, _, _ = strings.Cut(strings.ToLower(name), ",")
+  name = strings.Join(strings.Fields(name), " ")
+  if density, exists := d[name]; exists {
+    return density, true
+  }
+  if strings.HasSuffix(name, "s") {
+    density, exists := d[strings.TrimSuffix(name, "s")]
+    return density, exists
+  }
+  return 0, false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+  "regexp"
+  "sort"
+  "strings"
+)
+
+// Divided ingredients, like "2 cups sugar, divided", are used in parts across
+// the steps ("stir in 1 cup of the sugar"). The parts are found by looking for
+// an amount shortly before the ingredient's name in the instructions.
+
+var dividedRe = regexp.MustCompile(`(?i)\bdivided\b`)
+
+// dividedUnitPattern matches the units of the ingredients table, longest
+// first. t and T, tsp and tbsp only by their case, are left out.
+var dividedUnitPattern = func() string {
+  aliases := make([]string, 0, len(unitsByAlias))
+  for alias := range unitsByAlias {
+    if alias != "t" && alias != "T" {
+      aliases = append(aliases, regexp.QuoteMeta(alias))
+    }
+  }
+  sort.Slice(aliases, func(i, j int) bool {
+    if len(aliases[i]) != len(aliases[j]) {
+      return len(aliases[i]) > len(aliases[j])
+    }
+    return aliases[i] < aliases[j]
+  })
+  return strings.Join(aliases, "|")
+}()
+
+// dividedMentionRe matches an amount in the steps right before the
+// ingredient's name: "1 cup sugar", "1 cup of the brown sugar", "2 eggs".
+// Only a unit and "of the" may come in between, so the 5 of "cook 5 minutes,
+// then add the sugar" isn't taken for a part. The amount is group 1.
+func dividedMentionRe(ingredient Ingredient) *regexp.Regexp {
+  words := strings.Fields(ingredient.Name)
+  if len(words) == 0 {
+    return nil
+  }
+  name := regexp.QuoteMeta(words[len(words)-1])
+  leading := ""
+  if len(words) > 1 {
+    quoted := make([]string, len(words)-1)
+    for i, word := range words[:len(words)-1] {
+      quoted[i] = regexp.QuoteMeta(word)
+    }
+    leading = `(?:(?:` + strings.Join(quoted, "|") + `)\s+)*`
+  }
+  return regexp.MustCompile(`(?i)(?:^|\s)(\d+\s+\d+/\d+|\d+/\d+|\d+(?:[.,]\d+)?)\s+(?:(?:` + dividedUnitPattern + `)\.?\s+)?(?:of\s+(?:the\s+)?)?` + leading + name + `\b`)
+}
+
+func dividedIngredients(lines []string) []Ingredient {
+  divided := make([]Ingredient, 0)
+  for _, ingredient := range ParseIngredients(lines) {
+    if ingredient.Divided && ingredient.Confidence >= minRewriteConfidence {
+      divided = append(divided, ingredient)
+    }
+  }
+  return divided
+}
+
+// DividedSplits lists the parts of a divided ingredient used in the steps,
+// e.g. "1 cup" and "1 cup" for "2 cups sugar, divided".
+func DividedSplits(ingredient Ingredient, steps []string) []string {
+  re := dividedMentionRe(ingredient)
+  if re == nil {
+    return nil
+  }
+
+  splits := make([]string, 0)
+  for _, step := range steps {
+    for _, match := range re.FindAllString(step, -1) {
+      mention := ParseIngredient(strings.TrimSpace(match))
+      split := mention.AmountText
+      if mention.UnitText != "" {
+        split += " " + mention.UnitText
+      }
+      splits = append(splits, split)
+    }
+  }
+  return splits
+}
+
+// AnnotateDivided adds the parts found in the steps after each divided
+// ingredient, so "2 cups sugar, divided" becomes "2 cups sugar, divided
+// (1 cup + 1 cup)".
+func AnnotateDivided(r Recipe) Recipe {
+  lines := make([]string, len(r.IngredientLines))
+  copy(lines, r.IngredientLines)
+  for _, ingredient := range dividedIngredients(r.IngredientLines) {
+    splits := DividedSplits(ingredient, r.InstructionLines)
+    if len(splits) == 0 {
+      continue
+    }
+    for i, line := range lines {
+      if line == ingredient.Raw {
+        lines[i] = line + " (" + strings.Join(splits, " + ") + ")"
+      }
+    }
+  }
+  r.IngredientLines = lines
+  return r
+}
+
+// scaleDividedSteps scales the parts of divided ingredients mentioned in the
+// steps along with the ingredients, or they would no longer add up.
+func scaleDividedSteps(r Recipe, factor float64) []string {
+  steps := make([]string, len(r.InstructionLines))
+  copy(steps, r.InstructionLines)
+  scaled := map[string]bool{}
+  for _, ingredient := range dividedIngredients(r.IngredientLines) {
+    re := dividedMentionRe(ingredient)
+    if re == nil || scaled[re.String()] {
+      continue
+    }
+    scaled[re.String()] = true
+    for i, step := range steps {
+      steps[i] = re.ReplaceAllStringFunc(step, func(match string) string {
+        groups := re.FindStringSubmatchIndex(match)
+        amount := match[groups[2]:groups[3]]
+        value, ok := ParseAmount(amount)
+        if !ok {
+          return match
+        }
+        return match[:groups[2]] + formatAmount(value*factor, amount) + match[groups[3]:]
+      })
+    }
+  }
+  return steps
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestScaleDividedSteps(t *testing.T) {
+  recipe := Recipe{
+    IngredientLines: []string{"4 tbsp butter, divided", "2 cups brown sugar, divided"},
+    InstructionLines: []string{
+      "Melt 2 tbsp butter.",
+      "Cook 5 minutes then add butter.",
+      "Stir in 1 cup of the brown sugar and bake at 350 F for 20 minutes.",
+      "Top with the remaining 1 cup sugar and 2 tbsp of the butter.",
+    },
+  }
+  want := []string{
+    "Melt 4 tbsp butter.",
+    "Cook 5 minutes then add butter.",
+    "Stir in 2 cup of the brown sugar and bake at 350 F for 20 minutes.",
+    "Top with the remaining 2 cup sugar and 4 tbsp of the butter.",
+  }
+  if steps := scaleDividedSteps(recipe, 2); !reflect.DeepEqual(steps, want) {
+    t.Errorf("scaled steps\n%q\nwant\n%q", steps, want)
+  }
+
+  butter := ParseIngredient("4 tbsp butter, divided")
+  if splits := DividedSplits(butter, recipe.InstructionLines); !reflect.DeepEqual(splits, []string{"2 tbsp", "2 tbsp"}) {
+    t.Errorf("splits of the butter %q, want 2 tbsp twice", splits)
+  }
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+  "archive/zip"
+  "bytes"
+  "context"
+  "crypto/rand"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "html"
+  "io"
+  "net/http"
+  "net/url"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+)
+
+// When serve can't write its temporary folder (a read-only mount, a full
+// disk) uploads are converted in memory instead, and the files are kept for a
+// while to be downloaded from a page of links. Photos are left out, they
+// would need the export unpacked first.
+
+// downloadTTL is how long converted files stay downloadable.
+const downloadTTL = time.Hour
+
+type download struct {
+  created time.Time
+  files map[string][]byte
+}
+
+// downloadStore keeps the files converted in memory under a random token.
+type downloadStore struct {
+  mu sync.Mutex
+  downloads map[string]download
+}
+
+func newDownloadStore() *downloadStore {
+  return &downloadStore{downloads: map[string]download{}}
+}
+
+// add keeps files and returns the token they are downloaded by, dropping
+// the downloads that expired.
+func (s *downloadStore) add(files map[string][]byte) (string, error) {
+  random := make([]byte, 16)
+  if _, err := rand.Read(random); err != nil {
+    return "", err
+  }
+  token := hex.EncodeToString(random)
+
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  for old, download := range s.downloads {
+    if time.Since(download.created) > downloadTTL {
+      delete(s.downloads, old)
+    }
+  }
+  s.downloads[token] = download{time.Now(), files}
+  return token, nil
+}
+
+func (s *downloadStore) get(token string) (map[string][]byte, bool) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  download, exists := s.downloads[token]
+  if !exists || time.Since(download.created) > downloadTTL {
+    return nil, false
+  }
+  return download.files, true
+}
+
+// ServeHTTP sends /download/<token>/<file>, or all of the files as a zip for
+// /download/<token>/recipes.zip.
+func (s *downloadStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  token, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+  files, exists := s.get(token)
+  if !exists {
+    http.Error(w, "this download expired, convert the export again", http.StatusNotFound)
+    return
+  }
+
+  if name == "recipes.zip" {
+    var archive bytes.Buffer
+    if err := zipFiles(&archive, files); err != nil {
+      http.Error(w, err.Error(), http.StatusInternalServerError)
+      return
+    }
+    w.Header().Set("Content-Type", "application/zip")
+    w.Header().Set("Content-Disposition", `attachment; filename="recipes.zip"`)
+    w.Write(archive.Bytes())
+    return
+  }
+  content, exists := files[name]
+  if !exists {
+    http.NotFound(w, r)
+    return
+  }
+  w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+  w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(name)))
+  w.Write(content)
+}
+
+func zipFiles(w io.Writer, files map[string][]byte) error {
+  archive := zip.NewWriter(w)
+  for _, name := range sortedNames(files) {
+    entry, err := archive.Create(name)
+    if err != nil {
+      return err
+    }
+    if _, err := entry.Write(files[name]); err != nil {
+      return err
+    }
+  }
+  return archive.Close()
+}
+
+func sortedNames(files map[string][]byte) []string {
+  names := make([]string, 0, len(files))
+  for name := range files {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// downloadPage links the files kept under token.
+func downloadPage(token string, files map[string][]byte) string {
+  var links strings.Builder
+  for _, name := range sortedNames(files) {
+    link := (&url.URL{Path: "/download/" + token + "/" + name}).String()
+    links.WriteString(fmt.Sprintf("<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(link), html.EscapeString(name)))
+  }
+  return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Converted recipes</title></head>
+<body>
+<h1>Converted recipes</h1>
+<p>The server can't write files right now, so the recipes were converted without their photos. The links work for an hour.</p>
+<p><a href="/download/%s/recipes.zip">Download all as a zip</a></p>
+<ul>
+%s</ul>
+</body>
+</html>
+`, token, links.String())
+}
+
+// exportFromZip reads the recipes.html of a zipped export held in memory,
+// the one nearest the top when there are several.
+func exportFromZip(content []byte, limit int64) ([]byte, error) {
+  reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+  if err != nil {
+    return nil, err
+  }
+  var export *zip.File
+  for _, entry := range reader.File {
+    if strings.EqualFold(filepath.Base(entry.Name), "recipes.html") && (export == nil || len(entry.Name) < len(export.Name)) {
+      export = entry
+    }
+  }
+  if export == nil {
+    return nil, errors.New("the zip has no recipes.html")
+  }
+
+  source, err := export.Open()
+  if err != nil {
+    return nil, err
+  }
+  defer source.Close()
+  html, err := io.ReadAll(io.LimitReader(source, limit+1))
+  if err != nil {
+    return nil, err
+  }
+  if int64(len(html)) > limit {
+    return nil, fmt.Errorf("the zip unpacks to more than %d bytes", limit)
+  }
+  return html, nil
+}
+
+// convertInMemory renders the recipes of an export into files named by their
+// paths in the output folder.
+func (c *Converter) convertInMemory(ctx context.Context, reader io.Reader) (map[string][]byte, error) {
+  recipes, err := c.extract(reader)
+  if err != nil {
+    return nil, err
+  }
+  selected := make([]Recipe, 0, len(recipes))
+  for _, recipe := range recipes {
+    if c.selected(recipe) {
+      selected = append(selected, recipe)
+    }
+  }
+  if recipes, err = c.handleIncomplete(selected); err != nil {
+    return nil, err
+  }
+  recipes = c.resolveDuplicates(recipes)
+  for i := range recipes {
+    recipes[i].Ingredients = ParseIngredients(recipes[i].IngredientLines)
+  }
+  LinkRecipes(recipes)
+  c.resolvePaths(recipes)
+
+  files := map[string][]byte{}
+  for _, recipe := range recipes {
+    if err := ctx.Err(); err != nil {
+      return nil, err
+    }
+    if reason := c.skipReason(recipe); reason != "" {
+      c.emit(RecipeSkipped, recipe, reason)
+      continue
+    }
+    recipe.PhotoPaths = nil
+    content, err := c.Renderer.Render(recipe)
+    if err != nil {
+      c.emit(RecipeFailed, recipe, err.Error())
+      continue
+    }
+    files[filepath.ToSlash(c.outputPath(recipe))] = content
+  }
+  return files, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+  "encoding/json"
+  "os"
+  "path/filepath"
+)
+
+// DumpRecipes writes each recipe as it came out of the extraction, before
+// any cleanup or formatting, to dir/<uuid>.json. Comparing these with the
+// output shows whether a problem is in the extraction or later on.
+func DumpRecipes(dir string, recipes []Recipe) error {
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return err
+  }
+
+  for _, recipe := range recipes {
+    content, err := json.MarshalIndent(recipe, "", "  ")
+    if err != nil {
+      return err
+    }
+    path := filepath.Join(dir, recipe.Metadata.UUID+".json")
+    if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+      return err
+    }
+  }
+  return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Libraries that have been around for years tend to have the same recipe
+// imported more than once. Recipes count as duplicates when their titles
+// match and their ingredient lists are (nearly) the same.
+
+const (
+  DuplicatesReport = "report"
+  DuplicatesSkip = "skip"
+  DuplicatesMerge = "merge"
+  DuplicatesSuffix = "suffix"
+)
+
+// share of ingredient lines two recipes need in common to be duplicates
+const duplicateSimilarity = 0.8
+
+func duplicateKey(title string) string {
+  return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+func ingredientSimilarity(a []string, b []string) float64 {
+  if len(a) == 0 && len(b) == 0 {
+    return 1
+  }
+
+  set := map[string]bool{}
+  for _, line := range a {
+    set[duplicateKey(line)] = true
+  }
+  union := len(set)
+  common := 0
+  for _, line := range b {
+    key := duplicateKey(line)
+    if set[key] {
+      common++
+      delete(set, key)
+    } else {
+      union++
+    }
+  }
+  return float64(common) / float64(union)
+}
+
+// FindDuplicates groups duplicate recipes by index. Each group is in export
+// order, so the first recipe is the one the others duplicate.
+func FindDuplicates(recipes []Recipe) [][]int {
+  byTitle := map[string][]int{}
+  titles := make([]string, 0)
+  for i, recipe := range recipes {
+    key := duplicateKey(recipe.Title)
+    if _, exists := byTitle[key]; !exists {
+      titles = append(titles, key)
+    }
+    byTitle[key] = append(byTitle[key], i)
+  }
+
+  groups := make([][]int, 0)
+  for _, title := range titles {
+    remaining := byTitle[title]
+    for len(remaining) > 1 {
+      first := remaining[0]
+      group := []int{first}
+      rest := make([]int, 0, len(remaining))
+      for _, other := range remaining[1:] {
+        if ingredientSimilarity(recipes[first].IngredientLines, recipes[other].IngredientLines) >= duplicateSimilarity {
+          group = append(group, other)
+        } else {
+          rest = append(rest, other)
+        }
+      }
+      if len(group) > 1 {
+        groups = append(groups, group)
+      }
+      remaining = rest
+    }
+  }
+  return groups
+}
+
+func mergeList(into []string, from []string) []string {
+  for _, item := range from {
+    if !contains(into, item) {
+      into = append(into, item)
+    }
+  }
+  return into
+}
+
+// MergeRecipes folds a duplicate into the recipe it duplicates, keeping
+// anything the duplicate adds: tags, photos, notes and missing details.
+func MergeRecipes(r Recipe, duplicate Recipe) Recipe {
+  m, d := &r.Metadata, duplicate.Metadata
+  m.Favorited = m.Favorited || d.Favorited
+  if d.Rating > m.Rating {
+    m.Rating = d.Rating
+  }
+  m.CategoryList = mergeList(m.CategoryList, d.CategoryList)
+  m.CourseList = mergeList(m.CourseList, d.CourseList)
+  m.CollectionList = mergeList(m.CollectionList, d.CollectionList)
+  if m.Source == "" {
+    m.Source = d.Source
+  }
+  if m.Yield == "" {
+    m.Yield = d.Yield
+  }
+  if r.Description == "" {
+    r.Description = duplicate.Description
+  }
+  if len(r.InstructionLines) == 0 {
+    r.InstructionLines = duplicate.InstructionLines
+  }
+  r.PhotoPaths = mergeList(r.PhotoPaths, duplicate.PhotoPaths)
+  r.NotesLines = mergeList(r.NotesLines, duplicate.NotesLines)
+  return r
+}
+
+// resolveDuplicates reports the duplicates and deals with them as configured,
+// returning the recipes that are left to convert.
+func (c *Converter) resolveDuplicates(recipes []Recipe) []Recipe {
+  dropped := map[int]bool{}
+  for _, group := range FindDuplicates(recipes) {
+    first := group[0]
+    for n, i := range group[1:] {
+      original := recipes[first]
+      message := fmt.Sprintf("duplicate of %s", original.Metadata.UUID)
+
+      switch c.Duplicates {
+      case DuplicatesSkip:
+        c.emit(RecipeSkipped, recipes[i], message)
+        dropped[i] = true
+      case DuplicatesMerge:
+        recipes[first] = MergeRecipes(recipes[first], recipes[i])
+        c.emit(RecipeSkipped, recipes[i], message+", merged into it")
+        dropped[i] = true
+      case DuplicatesSuffix:
+        recipes[i].Title = fmt.Sprintf("%s (%d)", recipes[i].Title, n+2)
+        c.emit(DuplicateRecipe, recipes[i], message+", renamed")
+      default:
+        c.emit(DuplicateRecipe, recipes[i], message)
+      }
+    }
+  }
+
+  kept := make([]Recipe, 0, len(recipes)-len(dropped))
+  for i, recipe := range recipes {
+    if !dropped[i] {
+      kept = append(kept, recipe)
+    }
+  }
+  return kept
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "strings"
+
+  "github.com/PuerkitoBio/goquery"
+)
+
+var ErrNoRecipes = errors.New("no recipes found in the export")
+
+// diagnoseEmptyExport explains why a document had no div.recipe-details in
+// it, for the most common mix ups.
+func diagnoseEmptyExport(doc *goquery.Document) error {
+  ids := doc.Find(`[itemprop="recipeId"]`).Length()
+  names := doc.Find(`[itemprop="name"]`).Length()
+  title := strings.TrimSpace(doc.Find("title").First().Text())
+
+  switch {
+  case ids > 0:
+    // the microdata is there, just not in the containers we look for
+    return fmt.Errorf("%w: found %d recipe id(s) but no div.recipe-details, the export's markup may have changed or been localized, please report this along with the Recipe Keeper version", ErrNoRecipes, ids)
+  case strings.Contains(strings.ToLower(title), "recipe keeper") || doc.Find(".recipe").Length() > 0:
+    return fmt.Errorf("%w: the export is empty, export some recipes from Recipe Keeper first", ErrNoRecipes)
+  case names > 0:
+    return fmt.Errorf("%w: the file has recipe-like markup but isn't a Recipe Keeper export", ErrNoRecipes)
+  case title != "":
+    return fmt.Errorf("%w: %q doesn't look like a Recipe Keeper export, use the recipes.html from the exported zip", ErrNoRecipes, title)
+  }
+  return fmt.Errorf("%w: this doesn't look like a Recipe Keeper export, use the recipes.html from the exported zip (or the zip itself)", ErrNoRecipes)
+}
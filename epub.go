@@ -0,0 +1,239 @@
+package main
+
+import (
+  "archive/zip"
+  "bytes"
+  "crypto/sha256"
+  "fmt"
+  "html"
+  "sort"
+  "strings"
+  "time"
+)
+
+// An EPUB cookbook for e-readers: a chapter per collection, listing its
+// recipes, with the photos in the book. Recipes in several collections are
+// listed in each, but only in the book once, after the first. With
+// --credits the sources are credited on a last page.
+
+// EPUBRenderer writes all recipes into cookbook.epub.
+type EPUBRenderer struct {
+  Options FormatOptions
+  // Language is the book's language, "en" if unset.
+  Language string
+  // Date is when the book was last changed, the date of the export.
+  Date time.Time
+}
+
+type epubChapter struct {
+  title string
+  // indexes into the recipes
+  recipes []int
+}
+
+// epubChapters groups the recipes by collection, in order of title. Recipes
+// without one go into a last chapter of their own.
+func epubChapters(recipes []Recipe, options FormatOptions) []epubChapter {
+  byCollection := map[string][]int{}
+  loose := make([]int, 0)
+  for i, recipe := range recipes {
+    if len(recipe.Metadata.CollectionList) == 0 {
+      loose = append(loose, i)
+    }
+    for _, collection := range recipe.Metadata.CollectionList {
+      byCollection[collection] = append(byCollection[collection], i)
+    }
+  }
+
+  chapters := make([]epubChapter, 0, len(byCollection)+1)
+  for title, indexes := range byCollection {
+    chapters = append(chapters, epubChapter{title, indexes})
+  }
+  sort.Slice(chapters, func(i, j int) bool {
+    return strings.ToLower(chapters[i].title) < strings.ToLower(chapters[j].title)
+  })
+  if len(loose) > 0 {
+    chapters = append(chapters, epubChapter{options.Labels.get("Other recipes"), loose})
+  }
+  return chapters
+}
+
+func xhtmlPage(title string, body string) string {
+  return `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><meta charset="utf-8"/><title>` + html.EscapeString(title) + `</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body>
+` + body + `</body>
+</html>
+`
+}
+
+func (e EPUBRenderer) RenderAll(recipes []Recipe) ([]byte, error) {
+  sorted := append([]Recipe(nil), recipes...)
+  sort.SliceStable(sorted, func(i, j int) bool {
+    return strings.ToLower(sorted[i].Title) < strings.ToLower(sorted[j].Title)
+  })
+  language := e.Language
+  if language == "" {
+    language = "en"
+  }
+  title := e.Options.Labels.get("Recipes")
+
+  var buffer bytes.Buffer
+  archive := zip.NewWriter(&buffer)
+  add := func(name string, content []byte, method uint16) error {
+    writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: e.Date})
+    if err != nil {
+      return err
+    }
+    _, err = writer.Write(content)
+    return err
+  }
+  // the mimetype has to come first, uncompressed, for readers to recognize
+  // the book
+  if err := add("mimetype", []byte("application/epub+zip"), zip.Store); err != nil {
+    return nil, err
+  }
+  if err := add("META-INF/container.xml", []byte(`<?xml version="1.0" encoding="utf-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>
+`), zip.Deflate); err != nil {
+    return nil, err
+  }
+
+  var manifest, spine strings.Builder
+  item := func(id string, href string, mediaType string, properties string) {
+    if properties != "" {
+      properties = ` properties="` + properties + `"`
+    }
+    manifest.WriteString(fmt.Sprintf("<item id=\"%s\" href=\"%s\" media-type=\"%s\"%s/>\n", id, href, mediaType, properties))
+  }
+  item("nav", "nav.xhtml", "application/xhtml+xml", "nav")
+  item("style", "style.css", "text/css", "")
+  if err := add("OEBPS/style.css", []byte(htmlStyle), zip.Deflate); err != nil {
+    return nil, err
+  }
+
+  // the book's id stays the same as long as the same recipes are in it
+  ids := sha256.New()
+  for _, recipe := range sorted {
+    ids.Write([]byte(recipe.Metadata.UUID + "\x00"))
+  }
+  id := fmt.Sprintf("%x", ids.Sum(nil))
+
+  var citations *Citations
+  if e.Options.Credits {
+    citations = NewCitations(sorted)
+  }
+
+  var toc strings.Builder
+  written := make([]bool, len(sorted))
+  for c, chapter := range epubChapters(sorted, e.Options) {
+    chapterFile := fmt.Sprintf("chapter-%d.xhtml", c+1)
+    var list strings.Builder
+    toc.WriteString(fmt.Sprintf("<li><a href=\"%s\">%s</a>\n<ol>\n", chapterFile, html.EscapeString(chapter.title)))
+    for _, n := range chapter.recipes {
+      recipeFile := fmt.Sprintf("recipe-%d.xhtml", n+1)
+      link := fmt.Sprintf("<li><a href=\"%s\">%s</a></li>\n", recipeFile, html.EscapeString(sorted[n].Title))
+      list.WriteString(link)
+      toc.WriteString(link)
+    }
+    toc.WriteString("</ol></li>\n")
+
+    chapterID := fmt.Sprintf("chapter-%d", c+1)
+    item(chapterID, chapterFile, "application/xhtml+xml", "")
+    spine.WriteString(fmt.Sprintf("<itemref idref=\"%s\"/>\n", chapterID))
+    body := fmt.Sprintf("<h1>%s</h1>\n<ul>\n%s</ul>\n", html.EscapeString(chapter.title), list.String())
+    if err := add("OEBPS/"+chapterFile, []byte(xhtmlPage(chapter.title, body)), zip.Deflate); err != nil {
+      return nil, err
+    }
+
+    for _, n := range chapter.recipes {
+      if written[n] {
+        continue
+      }
+      written[n] = true
+      recipe := sorted[n]
+
+      photos := make([]string, 0, len(recipe.PhotoPaths))
+      for i, src := range recipe.PhotoPaths {
+        content, err := readExportPhoto(recipe, src)
+        if err != nil {
+          continue
+        }
+        mediaType, ext, ok := photoMediaType(content)
+        if !ok {
+          continue
+        }
+        name := photoFileName(n+1, i, ext)
+        item(fmt.Sprintf("photo-%d-%d", n+1, i+1), name, mediaType, "")
+        if err := add("OEBPS/"+name, content, zip.Store); err != nil {
+          return nil, err
+        }
+        photos = append(photos, name)
+      }
+
+      recipeID := fmt.Sprintf("recipe-%d", n+1)
+      item(recipeID, recipeID+".xhtml", "application/xhtml+xml", "")
+      spine.WriteString(fmt.Sprintf("<itemref idref=\"%s\"/>\n", recipeID))
+      body := recipe.formatHTMLBody(e.Options, photos, false)
+      if n := citations.Marker(recipe); n > 0 {
+        body += fmt.Sprintf("<p class=\"credit\">%s%s</p>\n", html.EscapeString(e.Options.Labels.get("Source")), creditMarkerHTML("credits.xhtml", n))
+      }
+      page := xhtmlPage(recipe.Title, body)
+      if err := add("OEBPS/"+recipeID+".xhtml", []byte(page), zip.Deflate); err != nil {
+        return nil, err
+      }
+    }
+  }
+
+  if citations != nil && citations.Len() > 0 {
+    credits := e.Options.Labels.get("Credits")
+    item("credits", "credits.xhtml", "application/xhtml+xml", "")
+    spine.WriteString("<itemref idref=\"credits\"/>\n")
+    toc.WriteString(fmt.Sprintf("<li><a href=\"credits.xhtml\">%s</a></li>\n", html.EscapeString(credits)))
+    if err := add("OEBPS/credits.xhtml", []byte(xhtmlPage(credits, formatCreditsHTML(citations, e.Options, 1))), zip.Deflate); err != nil {
+      return nil, err
+    }
+  }
+
+  nav := fmt.Sprintf("<nav epub:type=\"toc\" id=\"toc\">\n<h1>%s</h1>\n<ol>\n%s</ol>\n</nav>\n", html.EscapeString(title), toc.String())
+  if err := add("OEBPS/nav.xhtml", []byte(xhtmlPage(title, nav)), zip.Deflate); err != nil {
+    return nil, err
+  }
+
+  opf := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="%s">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">urn:recipekeeper2recipemd:%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>%s</dc:language>
+<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+%s</manifest>
+<spine>
+%s</spine>
+</package>
+`, html.EscapeString(language), id[:32], html.EscapeString(title), html.EscapeString(language), e.Date.UTC().Format("2006-01-02T15:04:05Z"), manifest.String(), spine.String())
+  if err := add("OEBPS/content.opf", []byte(opf), zip.Deflate); err != nil {
+    return nil, err
+  }
+
+  if err := archive.Close(); err != nil {
+    return nil, err
+  }
+  return buffer.Bytes(), nil
+}
+
+func (EPUBRenderer) FileName() string {
+  return "cookbook.epub"
+}
+
+func init() {
+  RegisterFormat(OutputFormat{Name: "epub", Exts: []string{"epub"}, New: func(s RenderSettings) (Renderer, CollectionRenderer, error) {
+    return nil, EPUBRenderer{Options: s.Options, Language: s.Language, Date: s.Date}, nil
+  }})
+}
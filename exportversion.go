@@ -0,0 +1,63 @@
+package main
+
+import (
+  "github.com/PuerkitoBio/goquery"
+)
+
+// Recipe Keeper's export markup has changed over the years. Older exports
+// stick closer to the plain schema.org names, so for those the extraction
+// falls back to the older itemprops instead of losing the fields.
+
+type ExportVersion string
+
+const (
+  ExportCurrent ExportVersion = "current"
+  ExportSchemaOrg ExportVersion = "schema.org"
+  ExportCSV ExportVersion = "csv"
+  // ExportMicrodata is any page with schema.org/Recipe microdata, see
+  // ScrapeMicrodata.
+  ExportMicrodata ExportVersion = "microdata"
+  // ExportJSONLD is a page with a schema.org/Recipe in a JSON-LD script, see
+  // ScrapeJSONLD.
+  ExportJSONLD ExportVersion = "json-ld"
+  ExportUnknown ExportVersion = "unknown"
+)
+
+// exportShims are the itemprops to also look for, by the one we read.
+var exportShims = map[ExportVersion]map[string]string{
+  ExportSchemaOrg: {
+    "recipeDirections": "recipeInstructions",
+    "recipeIsFavourite": "recipeIsFavorite",
+    "recipeRating": "ratingValue",
+    "recipeCollection": "keywords",
+    "recipeCourse": "recipeCuisine",
+  },
+}
+
+// DetectExportVersion tells the export generations apart by the itemprop of
+// the instructions, the one field every recipe has.
+func DetectExportVersion(doc *goquery.Document) ExportVersion {
+  switch {
+  case doc.Find(`[itemprop="recipeDirections"]`).Length() > 0:
+    return ExportCurrent
+  case doc.Find(`[itemprop="recipeInstructions"]`).Length() > 0:
+    return ExportSchemaOrg
+  }
+  return ExportUnknown
+}
+
+func (v ExportVersion) String() string {
+  switch v {
+  case ExportCurrent:
+    return "current Recipe Keeper export"
+  case ExportSchemaOrg:
+    return "older Recipe Keeper export (schema.org itemprops), reading it with fallbacks"
+  case ExportCSV:
+    return "Recipe Keeper CSV export, it has no photos"
+  case ExportMicrodata:
+    return "schema.org recipe microdata, not from Recipe Keeper"
+  case ExportJSONLD:
+    return "schema.org recipe in JSON-LD, not from Recipe Keeper"
+  }
+  return "unrecognized Recipe Keeper export, some fields may be missing"
+}
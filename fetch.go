@@ -0,0 +1,294 @@
+package main
+
+import (
+  "bufio"
+  "bytes"
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "os"
+  "strings"
+  "sync"
+  "time"
+)
+
+// fetch adds recipes from the web to the converted ones: it downloads pages,
+// reads their JSON-LD or microdata recipes and writes them next to the
+// others. A whole bookmark list can be imported at once, a few pages at a
+// time and never hammering one site.
+
+// fetchMaxBytes is more than any recipe page needs.
+const fetchMaxBytes = 10 << 20
+
+type FetchOptions struct {
+  Timeout time.Duration
+  Retries int
+  // HostInterval is the least time between two requests to the same site.
+  HostInterval time.Duration
+
+  hosts *hostLimiter
+}
+
+func DefaultFetchOptions() FetchOptions {
+  return FetchOptions{
+    Timeout: 30 * time.Second,
+    Retries: 3,
+    HostInterval: time.Second,
+  }
+}
+
+// hostLimiter spaces out the requests to each host, however many run at
+// once.
+type hostLimiter struct {
+  mu sync.Mutex
+  interval time.Duration
+  next map[string]time.Time
+}
+
+func (l *hostLimiter) wait(host string) {
+  if l == nil || l.interval <= 0 {
+    return
+  }
+  l.mu.Lock()
+  at := time.Now()
+  if next := l.next[host]; next.After(at) {
+    at = next
+  }
+  l.next[host] = at.Add(l.interval)
+  l.mu.Unlock()
+  time.Sleep(time.Until(at))
+}
+
+func fetchPage(page *url.URL, options FetchOptions) ([]byte, error) {
+  client := http.Client{Timeout: options.Timeout}
+  var content []byte
+  err := withRetry(options.Retries, func() error {
+    request, err := http.NewRequest(http.MethodGet, page.String(), nil)
+    if err != nil {
+      return permanentError{err}
+    }
+    // some sites turn away clients that don't say what they are
+    request.Header.Set("User-Agent", "recipekeeper2recipemd/"+ConverterVersion())
+    request.Header.Set("Accept", "text/html,application/xhtml+xml")
+    options.hosts.wait(page.Host)
+    response, err := client.Do(request)
+    if err != nil {
+      return err
+    }
+    defer response.Body.Close()
+
+    switch {
+    case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusGone:
+      return permanentError{fmt.Errorf("unexpected response %s", response.Status)}
+    case response.StatusCode != http.StatusOK:
+      return fmt.Errorf("unexpected response %s", response.Status)
+    }
+    content, err = io.ReadAll(io.LimitReader(response.Body, fetchMaxBytes+1))
+    if err == nil && len(content) > fetchMaxBytes {
+      return permanentError{errors.New("page is too large")}
+    }
+    return err
+  })
+  return content, err
+}
+
+// FetchRecipes downloads a page and reads the recipes in it. Their photos
+// are resolved against the page, and the page is their source unless they
+// name another.
+func FetchRecipes(pageURL string, options FetchOptions) ([]Recipe, error) {
+  page, err := url.Parse(pageURL)
+  if err != nil || (page.Scheme != "http" && page.Scheme != "https") || page.Host == "" {
+    return nil, fmt.Errorf("%q is not a web address", pageURL)
+  }
+  content, err := fetchPage(page, options)
+  if err != nil {
+    return nil, fmt.Errorf("%s: %w", pageURL, err)
+  }
+  recipes, _, err := ScrapeExport(bytes.NewReader(content))
+  if err != nil {
+    return nil, fmt.Errorf("%s: %w", pageURL, err)
+  }
+
+  for i := range recipes {
+    recipe := &recipes[i]
+    for j, src := range recipe.PhotoPaths {
+      if ref, err := url.Parse(src); err == nil && !isDataURI(src) {
+        recipe.PhotoPaths[j] = page.ResolveReference(ref).String()
+      }
+    }
+    if recipe.Metadata.Source == "" {
+      recipe.Metadata.Source = pageURL
+    }
+    recipe.fetched = pageURL
+  }
+  return recipes, nil
+}
+
+// FetchResult is the outcome of fetching one page.
+type FetchResult struct {
+  URL string
+  Recipes []Recipe
+  Err error
+}
+
+// FetchAll fetches the pages, concurrency of them at a time, and returns
+// the results in their order. progress, when set, is called as each page is
+// done.
+func FetchAll(pageURLs []string, options FetchOptions, concurrency int, progress func(done int, result FetchResult)) []FetchResult {
+  if concurrency < 1 {
+    concurrency = 1
+  }
+  if options.hosts == nil {
+    options.hosts = &hostLimiter{interval: options.HostInterval, next: map[string]time.Time{}}
+  }
+
+  results := make([]FetchResult, len(pageURLs))
+  jobs := make(chan int)
+  var wg sync.WaitGroup
+  var mu sync.Mutex
+  done := 0
+  for worker := 0; worker < concurrency; worker++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range jobs {
+        recipes, err := FetchRecipes(pageURLs[i], options)
+        results[i] = FetchResult{pageURLs[i], recipes, err}
+        mu.Lock()
+        done++
+        if progress != nil {
+          progress(done, results[i])
+        }
+        mu.Unlock()
+      }
+    }()
+  }
+  for i := range pageURLs {
+    jobs <- i
+  }
+  close(jobs)
+  wg.Wait()
+  return results
+}
+
+// readURLList reads a file of page addresses, one per line. Blank lines and
+// lines starting with # are skipped.
+func readURLList(path string) ([]string, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+
+  urls := make([]string, 0)
+  scanner := bufio.NewScanner(file)
+  for scanner.Scan() {
+    line := strings.TrimSpace(scanner.Text())
+    if line != "" && !strings.HasPrefix(line, "#") {
+      urls = append(urls, line)
+    }
+  }
+  return urls, scanner.Err()
+}
+
+func runFetch(args []string) error {
+  flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+  output := flags.String("output", outputDir, "folder of converted recipes to add the recipes to")
+  urlList := flags.String("urls", "", "file of page addresses to fetch, one per line, along with those given")
+  failedList := flags.String("failed", "", "write the addresses that couldn't be fetched to this file, to try them again with -urls")
+  frontmatter := flags.Bool("frontmatter", false, "add a YAML front matter block with the recipe metadata")
+  downloadPhotos := flags.Bool("download-photos", true, "download the recipes' photos into the images folder")
+  concurrency := flags.Int("concurrency", 4, "how many pages to fetch at once")
+  options := DefaultFetchOptions()
+  flags.DurationVar(&options.Timeout, "timeout", options.Timeout, "timeout for downloading each page and photo")
+  flags.IntVar(&options.Retries, "retries", options.Retries, "how often to retry a failed download")
+  flags.DurationVar(&options.HostInterval, "host-interval", options.HostInterval, "least time between two requests to the same site")
+  flags.Parse(args)
+
+  pageURLs := flags.Args()
+  if *urlList != "" {
+    listed, err := readURLList(*urlList)
+    if err != nil {
+      return err
+    }
+    pageURLs = append(pageURLs, listed...)
+  }
+  if len(pageURLs) == 0 {
+    return errors.New("usage: recipekeeper2recipemd fetch [-output dir] [-urls file] <url>...")
+  }
+
+  converter := NewConverter()
+  converter.OutputDir = *output
+  converter.Renderer = RecipeMDRenderer{Options: FormatOptions{Frontmatter: *frontmatter}}
+  converter.ExportDir = "."
+  converter.DownloadPhotos = *downloadPhotos
+  converter.PhotoDownload = DefaultPhotoDownloadOptions()
+  converter.PhotoDownload.Timeout = options.Timeout
+  converter.PhotoDownload.Retries = options.Retries
+  converter.Adding = true
+  if err := CheckWritable(converter.OutputDir); err != nil {
+    return err
+  }
+
+  recipes := make([]Recipe, 0)
+  failed := make([]FetchResult, 0)
+  results := FetchAll(pageURLs, options, *concurrency, func(done int, result FetchResult) {
+    if len(pageURLs) == 1 {
+      return
+    }
+    status := fmt.Sprintf("%d recipe(s)", len(result.Recipes))
+    if result.Err != nil {
+      status = "failed"
+    }
+    fmt.Fprintf(os.Stderr, "[%d/%d] %s: %s\n", done, len(pageURLs), result.URL, status)
+  })
+  for _, result := range results {
+    if result.Err != nil {
+      failed = append(failed, result)
+      continue
+    }
+    recipes = append(recipes, result.Recipes...)
+  }
+  if len(pageURLs) == 1 && len(failed) == 1 {
+    return failed[0].Err
+  }
+
+  if len(recipes) > 0 {
+    report := startRunReport("")
+    report.json = NewConversionReport("", converter.OutputDir)
+    converter.Progress = report.progress
+    recipes, err := converter.prepare(recipes)
+    if err == nil {
+      err = converter.writeAll(context.Background(), recipes)
+    }
+    report.finish(err)
+    if err != nil {
+      return err
+    }
+    for _, recipe := range report.json.Recipes {
+      if recipe.Status == "converted" {
+        fmt.Printf("%s\t%s\n", recipe.Title, recipe.Path)
+      }
+    }
+  }
+
+  if len(failed) == 0 {
+    return nil
+  }
+  fmt.Fprintf(os.Stderr, "%d of %d page(s) failed:\n", len(failed), len(pageURLs))
+  var list strings.Builder
+  for _, result := range failed {
+    fmt.Fprintf(os.Stderr, "  %s\n", result.Err)
+    list.WriteString(result.URL + "\n")
+  }
+  if *failedList != "" {
+    if err := os.WriteFile(*failedList, []byte(list.String()), 0644); err != nil {
+      return err
+    }
+  }
+  return fmt.Errorf("%d page(s) couldn't be fetched", len(failed))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+  "fmt"
+  "regexp"
+  "strings"
+)
+
+// Some fields are written inside comment markers so they can later be
+// refreshed in place (see --update-fields) without regenerating whole files:
+//
+//   <!-- field:nutrition -->
+//   ...
+//   <!-- /field:nutrition -->
+//
+// Adding "keep" to the opening marker (<!-- field:nutrition keep -->) protects
+// a hand edited block, and a <!-- keep --> line protects the whole file.
+
+var updatableFields = []string{"nutrition", "photos"}
+
+const keepFileMarker = "<!-- keep -->"
+
+func fieldBlock(name string, body string) string {
+  return fmt.Sprintf("<!-- field:%s -->\n%s<!-- /field:%s -->\n", name, body, name)
+}
+
+func fieldBlockRe(name string) *regexp.Regexp {
+  return regexp.MustCompile(`(?s)<!-- field:` + regexp.QuoteMeta(name) + `( keep)? -->\n.*?<!-- /field:` + regexp.QuoteMeta(name) + ` -->\n?`)
+}
+
+type NutritionValue struct {
+  Label string
+  Value string
+  // Amount is the Value parsed, when Parsed, see ParseNutritionAmount.
+  Amount NutritionAmount
+  Parsed bool
+}
+
+func (n RecipeNutrition) Values() []NutritionValue {
+  values := []NutritionValue{
+    {Label: "Serving size", Value: n.Serving},
+    {Label: "Calories", Value: n.Calories},
+    {Label: "Total fat", Value: n.TotalFat},
+    {Label: "Saturated fat", Value: n.SaturatedFat},
+    {Label: "Sodium", Value: n.Sodium},
+    {Label: "Total carbohydrate", Value: n.TotalCarbohydrate},
+    {Label: "Dietary fiber", Value: n.DietaryFiber},
+    {Label: "Sugars", Value: n.Sugars},
+    {Label: "Protein", Value: n.Protein},
+  }
+  for i := range values {
+    values[i].Amount, values[i].Parsed = ParseNutritionAmount(values[i].Label, values[i].Value)
+  }
+  return values
+}
+
+func (n *RecipeNutrition) Set(label string, value string) bool {
+  fields := map[string]*string{
+    "Serving size": &n.Serving,
+    "Calories": &n.Calories,
+    "Total fat": &n.TotalFat,
+    "Saturated fat": &n.SaturatedFat,
+    "Sodium": &n.Sodium,
+    "Total carbohydrate": &n.TotalCarbohydrate,
+    "Dietary fiber": &n.DietaryFiber,
+    "Sugars": &n.Sugars,
+    "Protein": &n.Protein,
+  }
+  field, exists := fields[label]
+  if exists {
+    *field = value
+  }
+  return exists
+}
+
+func (r Recipe) formatNutritionBlock(options FormatOptions) string {
+  var body strings.Builder
+  for _, value := range r.Nutrition.Values() {
+    if value.Value == "" {
+      continue
+    }
+    text := value.Value
+    if options.NormalizeNutrition && value.Parsed {
+      text = value.Amount.String()
+    }
+    body.WriteString(fmt.Sprintf("- %s: %s\n", options.Labels.get(value.Label), text))
+  }
+  if body.Len() == 0 {
+    return ""
+  }
+  return fieldBlock("nutrition", options.heading("Nutrition")+"\n\n"+body.String())
+}
+
+func (r Recipe) formatPhotosBlock(options FormatOptions) string {
+  if len(r.PhotoPaths) == 0 {
+    return ""
+  }
+
+  var body strings.Builder
+  body.WriteString(options.heading("Photos") + "\n\n")
+  for i, path := range r.PhotoPaths {
+    // gzipped photos can't be shown inline, so link to them instead
+    if strings.HasSuffix(path, ".gz") {
+      body.WriteString(fmt.Sprintf("[%s, %s %d](%s)\n", r.Title, options.Labels.get("photo"), i+1, path))
+    } else {
+      body.WriteString(fmt.Sprintf("![%s](%s)\n", r.Title, path))
+    }
+  }
+  return fieldBlock("photos", body.String())
+}
+
+// PatchFields copies the named field blocks from a freshly rendered file into
+// an existing one. Blocks missing from the existing file are appended, and the
+// names of blocks left alone because of keep markers are returned.
+func PatchFields(existing string, rendered string, fields []string) (string, []string) {
+  kept := make([]string, 0)
+  if strings.Contains(existing, keepFileMarker) {
+    return existing, fields
+  }
+
+  for _, name := range fields {
+    re := fieldBlockRe(name)
+    replacement := re.FindString(rendered)
+    if replacement != "" && !strings.HasSuffix(replacement, "\n") {
+      replacement += "\n"
+    }
+
+    current := re.FindStringSubmatchIndex(existing)
+    switch {
+    case current == nil && replacement != "":
+      if !strings.HasSuffix(existing, "\n\n") {
+        existing = strings.TrimRight(existing, "\n") + "\n\n"
+      }
+      existing += replacement
+    case current == nil:
+      continue
+    case current[2] != -1:
+      kept = append(kept, name)
+    default:
+      existing = existing[:current[0]] + replacement + existing[current[1]:]
+    }
+  }
+
+  return existing, kept
+}
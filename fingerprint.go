@@ -0,0 +1,229 @@
+package main
+
+import (
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "flag"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+// A library fingerprint lets two households find the recipes they both have
+// without sharing the recipes themselves. Every recipe is reduced to keyed
+// hashes of its id, its title and its ingredients and steps. Nothing else is
+// in the file, and it is only written when asked for; nothing is sent
+// anywhere. The hashes are keyed with a secret shared by the two households,
+// so they can't be checked against guessed titles by anyone who doesn't know
+// it; without one a random secret is made up and printed to pass on.
+
+const fingerprintVersion = 1
+
+type LibraryFingerprint struct {
+  Version int `json:"version"`
+  Recipes []RecipeFingerprint `json:"recipes"`
+}
+
+type RecipeFingerprint struct {
+  ID string `json:"id"`
+  Title string `json:"title"`
+  Content string `json:"content"`
+
+  // the title, only known for our own library and never written
+  name string
+}
+
+func fingerprintHash(secret string, kind string, value string) string {
+  mac := hmac.New(sha256.New, []byte("recipekeeper2recipemd fingerprint\x00"+secret))
+  mac.Write([]byte(kind + "\x00" + value))
+  return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// fingerprintText ignores case and spacing, which differ between devices
+// more often than the recipes do.
+func fingerprintText(lines ...string) string {
+  return strings.ToLower(strings.Join(strings.Fields(strings.Join(lines, "\n")), " "))
+}
+
+// FingerprintLibrary fingerprints the recipes, sorted by id so the file says
+// nothing about the order of the export either.
+func FingerprintLibrary(recipes []Recipe, secret string) LibraryFingerprint {
+  library := LibraryFingerprint{Version: fingerprintVersion, Recipes: make([]RecipeFingerprint, 0, len(recipes))}
+  normalizer := DefaultTextNormalizer()
+  for _, recipe := range recipes {
+    recipe = normalizer.NormalizeRecipe(recipe)
+    content := fingerprintText(recipe.IngredientLines...) + "\x00" + fingerprintText(recipe.InstructionLines...)
+    library.Recipes = append(library.Recipes, RecipeFingerprint{
+      ID: fingerprintHash(secret, "id", recipe.Metadata.UUID),
+      Title: fingerprintHash(secret, "title", fingerprintText(recipe.Title)),
+      Content: fingerprintHash(secret, "content", content),
+      name: recipe.Title,
+    })
+  }
+  sort.Slice(library.Recipes, func(i, j int) bool {
+    return library.Recipes[i].ID < library.Recipes[j].ID
+  })
+  return library
+}
+
+// LibraryComparison pairs up the recipes of two libraries, by id or else by
+// title, since recipes imported on each device separately get their own ids.
+type LibraryComparison struct {
+  Same []RecipeFingerprint
+  Different []RecipeFingerprint
+  OnlyMine []RecipeFingerprint
+  OnlyTheirs int
+}
+
+func CompareLibraries(mine LibraryFingerprint, theirs LibraryFingerprint) LibraryComparison {
+  comparison := LibraryComparison{}
+  byID := map[string]int{}
+  byTitle := map[string]int{}
+  matched := make([]bool, len(theirs.Recipes))
+  for i, recipe := range theirs.Recipes {
+    byID[recipe.ID] = i
+    if _, exists := byTitle[recipe.Title]; !exists {
+      byTitle[recipe.Title] = i
+    }
+  }
+
+  for _, recipe := range mine.Recipes {
+    i, exists := byID[recipe.ID]
+    if !exists || matched[i] {
+      if i, exists = byTitle[recipe.Title]; exists && matched[i] {
+        exists = false
+      }
+    }
+    switch {
+    case !exists:
+      comparison.OnlyMine = append(comparison.OnlyMine, recipe)
+    case theirs.Recipes[i].Content == recipe.Content:
+      matched[i] = true
+      comparison.Same = append(comparison.Same, recipe)
+    default:
+      matched[i] = true
+      comparison.Different = append(comparison.Different, recipe)
+    }
+  }
+  for _, found := range matched {
+    if !found {
+      comparison.OnlyTheirs++
+    }
+  }
+  return comparison
+}
+
+func (c LibraryComparison) String() string {
+  var output strings.Builder
+  for _, group := range []struct {
+    header string
+    recipes []RecipeFingerprint
+  }{
+    {"in both libraries", c.Same},
+    {"in both, but different", c.Different},
+    {"only in mine", c.OnlyMine},
+  } {
+    output.WriteString(fmt.Sprintf("%d recipe(s) %s\n", len(group.recipes), group.header))
+    for _, recipe := range group.recipes {
+      name := recipe.name
+      if name == "" {
+        name = recipe.ID
+      }
+      output.WriteString("  " + name + "\n")
+    }
+  }
+  output.WriteString(fmt.Sprintf("%d recipe(s) only in theirs\n", c.OnlyTheirs))
+  return output.String()
+}
+
+// loadFingerprint reads a fingerprint file, or fingerprints an export.
+func loadFingerprint(path string, secret string) (LibraryFingerprint, error) {
+  if strings.EqualFold(filepath.Ext(path), ".json") {
+    content, err := os.ReadFile(path)
+    if err != nil {
+      return LibraryFingerprint{}, err
+    }
+    library := LibraryFingerprint{}
+    if err := json.Unmarshal(content, &library); err != nil {
+      return library, fmt.Errorf("%s: %w", path, err)
+    }
+    if library.Version != fingerprintVersion {
+      return library, fmt.Errorf("%s: unsupported fingerprint version %d", path, library.Version)
+    }
+    return library, nil
+  }
+
+  // unkeyed hashes of titles are easily reversed by guessing
+  if secret == "" {
+    return LibraryFingerprint{}, errors.New("-secret is needed to fingerprint an export, the one the other fingerprint was made with")
+  }
+  sources, cleanup, err := OpenExports([]string{path})
+  if err != nil {
+    return LibraryFingerprint{}, err
+  }
+  defer cleanup()
+  recipes, _, err := sources[0].scrape()
+  if err != nil {
+    return LibraryFingerprint{}, fmt.Errorf("%s: %w", path, err)
+  }
+  return FingerprintLibrary(recipes, secret), nil
+}
+
+func runFingerprint(args []string) error {
+  flags := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+  output := flags.String("o", "library-fingerprint.json", "file to write the fingerprint to")
+  secret := flags.String("secret", "", "passphrase agreed with the other household, so only the two of you can read anything from the hashes; made up if not given")
+  flags.Parse(args)
+
+  path := defaultExportPath
+  if flags.NArg() > 0 {
+    path = flags.Arg(0)
+  }
+  if *secret == "" {
+    random := make([]byte, 12)
+    if _, err := rand.Read(random); err != nil {
+      return err
+    }
+    *secret = hex.EncodeToString(random)
+    fmt.Fprintf(os.Stderr, "warning: no -secret given, using %s; the other household needs it for their fingerprint, and to compare\n", *secret)
+  }
+  library, err := loadFingerprint(path, *secret)
+  if err != nil {
+    return err
+  }
+  content, err := json.MarshalIndent(library, "", "  ")
+  if err != nil {
+    return err
+  }
+  if err := os.WriteFile(*output, append(content, '\n'), 0644); err != nil {
+    return err
+  }
+  fmt.Fprintf(os.Stderr, "fingerprinted %d recipe(s) into %s\n", len(library.Recipes), *output)
+  return nil
+}
+
+func runCompareLibraries(args []string) error {
+  flags := flag.NewFlagSet("compare-libraries", flag.ExitOnError)
+  secret := flags.String("secret", "", "passphrase both fingerprints were made with")
+  flags.Parse(args)
+
+  if flags.NArg() != 2 {
+    return errors.New("usage: recipekeeper2recipemd compare-libraries -secret passphrase <my export or fingerprint> <their fingerprint>")
+  }
+  mine, err := loadFingerprint(flags.Arg(0), *secret)
+  if err != nil {
+    return err
+  }
+  theirs, err := loadFingerprint(flags.Arg(1), *secret)
+  if err != nil {
+    return err
+  }
+  fmt.Print(CompareLibraries(mine, theirs))
+  return nil
+}
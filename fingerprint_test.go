@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestFingerprintSecret(t *testing.T) {
+  recipes := []Recipe{{Title: "Pancakes", IngredientLines: []string{"1 cup milk"}}}
+  ours := FingerprintLibrary(recipes, "ours")
+  theirs := FingerprintLibrary(recipes, "theirs")
+  if ours.Recipes[0].Title == theirs.Recipes[0].Title {
+    t.Error("the title hash doesn't depend on the secret")
+  }
+
+  if _, err := loadFingerprint("recipes.html", ""); err == nil {
+    t.Error("fingerprinted an export without a secret")
+  }
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+// FormatOptions are the knobs of the RecipeMD formatter, so programs using the
+// converter as a library get the same output choices as the command line.
+// The zero value gives the default output.
+type FormatOptions struct {
+  // Frontmatter adds a YAML block with the recipe metadata above the title.
+  Frontmatter bool
+  // HeroImage shows the first photo right below the title.
+  HeroImage bool
+  // HashTags adds the categories and collections as #tags (also listed in
+  // the front matter), WikiLinks links recipes with [[...]] rather than
+  // Markdown links. Obsidian understands both.
+  HashTags bool
+  WikiLinks bool
+  // EmphasizeAmounts wraps the amount and unit of each ingredient in *, as
+  // RecipeMD expects, for lines parsed with at least MinConfidence.
+  EmphasizeAmounts bool
+  MinConfidence float64
+  // AmountMarkup wraps the amounts in a <span> with the parsed amount and
+  // unit as data attributes, for scripts that scale recipes on a web page.
+  AmountMarkup bool
+  // NormalizeNutrition writes the nutrition values the same way throughout,
+  // "245 kcal" and "12 g" rather than "245 calories" and "12g".
+  NormalizeNutrition bool
+  // HighlightIngredients puts the ingredients in bold where the steps
+  // mention them.
+  HighlightIngredients bool
+  // Credits numbers the sources of the recipes and lists them in a credits
+  // section, in the outputs that have all recipes together: the cookbooks
+  // and the indexes.
+  Credits bool
+  // TagCharset is the charset of #tags and other generated anchors.
+  TagCharset Charset
+  StepsStyle StepsStyle
+  FractionStyle FractionStyle
+  RatingStyle RatingStyle
+  // HeadingLevel is the level of the section headings (Instructions, Notes,
+  // ...), 3 if unset. The title is always a level 1 heading.
+  HeadingLevel int
+  // Labels translate the headings and other fixed words, see LoadLabels.
+  Labels Labels
+  // Strict writes nothing but the structure of the RecipeMD spec, see
+  // formatStrictRecipeMD.
+  Strict bool
+  // TimingSummary starts the instructions with the time each of their
+  // sections takes, see SectionTimingSummary.
+  TimingSummary bool
+}
+
+type StepsStyle string
+
+const (
+  StepsPlain StepsStyle = "plain"
+  StepsNumbered StepsStyle = "numbered"
+  StepsBulleted StepsStyle = "bulleted"
+)
+
+type RatingStyle string
+
+const (
+  // RatingProse is the "Rating: 3-star" line.
+  RatingProse RatingStyle = "prose"
+  RatingStars RatingStyle = "stars"
+  // RatingFrontmatter leaves the rating to the front matter's rating field.
+  RatingFrontmatter RatingStyle = "frontmatter"
+)
+
+type FractionStyle string
+
+const (
+  // FractionsAsIs leaves fractions however the normalizer left them.
+  FractionsAsIs FractionStyle = "as-is"
+  FractionsASCII FractionStyle = "ascii"
+  FractionsUnicode FractionStyle = "unicode"
+)
+
+func (o FormatOptions) validate() error {
+  switch o.StepsStyle {
+  case "", StepsPlain, StepsNumbered, StepsBulleted:
+  default:
+    return fmt.Errorf("unknown steps style %q", o.StepsStyle)
+  }
+  switch o.FractionStyle {
+  case "", FractionsAsIs, FractionsASCII, FractionsUnicode:
+  default:
+    return fmt.Errorf("unknown fraction style %q", o.FractionStyle)
+  }
+  switch o.RatingStyle {
+  case "", RatingProse, RatingStars:
+  case RatingFrontmatter:
+    if !o.Frontmatter {
+      return errors.New("the rating can only be left to the front matter when there is one")
+    }
+  default:
+    return fmt.Errorf("unknown rating style %q", o.RatingStyle)
+  }
+  switch o.TagCharset {
+  case "", CharsetUnicode, CharsetASCII:
+  default:
+    return fmt.Errorf("unknown tag charset %q", o.TagCharset)
+  }
+  if o.MinConfidence < 0 || o.MinConfidence > 1 {
+    return fmt.Errorf("minimum confidence must be between 0 and 1, got %g", o.MinConfidence)
+  }
+  if o.HeadingLevel < 0 || o.HeadingLevel == 1 || o.HeadingLevel > 6 {
+    return fmt.Errorf("heading level must be between 2 and 6, got %d", o.HeadingLevel)
+  }
+  return nil
+}
+
+func (o FormatOptions) heading(title string) string {
+  level := o.HeadingLevel
+  if level == 0 {
+    level = 3
+  }
+  return strings.Repeat("#", level) + " " + o.Labels.get(title)
+}
+
+// rating is the value of the Rating line, "3-star" or "★★★☆☆", and empty
+// when the line is left out.
+func (o FormatOptions) rating(rating int) string {
+  switch {
+  case rating == 0 || o.RatingStyle == RatingFrontmatter:
+    return ""
+  case o.RatingStyle == RatingStars && rating > 0 && rating <= 5:
+    return strings.Repeat("★", rating) + strings.Repeat("☆", 5-rating)
+  }
+  return fmt.Sprintf("%d-star", rating)
+}
+
+// highlighter is a no-op unless HighlightIngredients is set.
+func (o FormatOptions) highlighter(r Recipe) *ingredientHighlighter {
+  if !o.HighlightIngredients {
+    return &ingredientHighlighter{}
+  }
+  return newIngredientHighlighter(ParseIngredients(r.IngredientLines))
+}
+
+// subheading is one level below heading, for the "For the filling:" lines in
+// between the steps. Their titles are the recipe's own, so not translated.
+func (o FormatOptions) subheading(title string) string {
+  level := o.HeadingLevel
+  if level == 0 {
+    level = 3
+  }
+  return strings.Repeat("#", level+1) + " " + title
+}
+
+func (o FormatOptions) fractions(line string) string {
+  switch o.FractionStyle {
+  case FractionsASCII:
+    return ConvertFractions(line)
+  case FractionsUnicode:
+    return unicodeFractions(line)
+  }
+  return line
+}
+
+func (o FormatOptions) ingredient(line string) string {
+  if o.EmphasizeAmounts || o.AmountMarkup {
+    ingredient := ParseIngredient(line)
+    line = ingredient.WrapQuantity(o.MinConfidence, func(quantity string) string {
+      if o.AmountMarkup {
+        quantity = ingredient.Markup(quantity)
+      }
+      if o.EmphasizeAmounts {
+        quantity = "*" + quantity + "*"
+      }
+      return quantity
+    })
+  }
+  return o.fractions(line)
+}
+
+// steps formats the instruction lines, leaving section headings alone so
+// numbering starts over in each section.
+func (o FormatOptions) steps(lines []string) []string {
+  output := make([]string, 0, len(lines))
+  step := 0
+  for _, line := range lines {
+    if IsSectionHeading(line) {
+      step = 0
+      output = append(output, o.subheading(SectionTitle(line)))
+      continue
+    }
+    step++
+    switch o.StepsStyle {
+    case StepsNumbered:
+      line = fmt.Sprintf("%d. %s", step, line)
+    case StepsBulleted:
+      line = "- " + line
+    }
+    output = append(output, line)
+  }
+  return output
+}
+
+// formatSteps joins the steps so each stays its own paragraph, or list item
+// when they are numbered or bulleted. Lines joined by a single newline would
+// render as one run-on paragraph.
+func (o FormatOptions) formatSteps(lines []string) string {
+  var output strings.Builder
+  list := o.StepsStyle == StepsNumbered || o.StepsStyle == StepsBulleted
+  for i, line := range o.steps(lines) {
+    switch {
+    case i == 0:
+    case list && !IsSectionHeading(lines[i]) && !IsSectionHeading(lines[i-1]):
+      output.WriteString("\n")
+    default:
+      output.WriteString("\n\n")
+    }
+    output.WriteString(line)
+  }
+  return output.String()
+}
+
+var asciiFractionRe = regexp.MustCompile(`(\d+ )?\b(\d)/(\d)\b`)
+
+func unicodeFractions(input string) string {
+  glyphs := make(map[string]rune, len(fractions))
+  for r, text := range fractions {
+    glyphs[text] = r
+  }
+
+  return asciiFractionRe.ReplaceAllStringFunc(input, func(match string) string {
+    parts := asciiFractionRe.FindStringSubmatch(match)
+    glyph, exists := glyphs[parts[2]+"/"+parts[3]]
+    if !exists {
+      return match
+    }
+    return strings.TrimSpace(parts[1]) + string(glyph)
+  })
+}
+
+var tagUnsafeRe = regexp.MustCompile(`[^\p{L}\p{N}_/-]+`)
+
+// tagName makes a category usable as a #tag: "Weeknight/Quick" becomes the
+// nested tag "weeknight/quick", "Main Dish" becomes "main-dish".
+func tagName(name string) string {
+  return strings.Trim(tagUnsafeRe.ReplaceAllString(strings.ToLower(name), "-"), "-/")
+}
+
+const favoriteTag = "favorite"
+
+func (r Recipe) tags(charset Charset) []string {
+  tags := make([]string, 0)
+  seen := map[string]bool{}
+  for _, list := range [][]string{r.Metadata.CategoryList, r.Metadata.CollectionList} {
+    for _, name := range list {
+      if tag := tagName(charset.Apply(name)); tag != "" && !seen[tag] {
+        seen[tag] = true
+        tags = append(tags, tag)
+      }
+    }
+  }
+  // so favourites can be found by tag too, and picked with --only-tags
+  if r.Metadata.Favorited && !seen[favoriteTag] {
+    tags = append(tags, favoriteTag)
+  }
+  return tags
+}
+
+func (r Recipe) formatHashTags(charset Charset) string {
+  tags := r.tags(charset)
+  for i, tag := range tags {
+    tags[i] = "#" + tag
+  }
+  return strings.Join(tags, " ")
+}
+
+func yamlString(value string) string {
+  return strconv.Quote(value)
+}
+
+func yamlList(values []string) string {
+  quoted := make([]string, 0, len(values))
+  for _, value := range values {
+    quoted = append(quoted, yamlString(value))
+  }
+  return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func (r Recipe) formatFrontmatter(options FormatOptions) string {
+  var output strings.Builder
+  output.WriteString("---\n")
+  output.WriteString("title: " + yamlString(r.Title) + "\n")
+  if r.Metadata.UUID != "" {
+    output.WriteString("uuid: " + yamlString(r.Metadata.UUID) + "\n")
+  }
+  if r.Metadata.Source != "" {
+    output.WriteString("source: " + yamlString(r.Metadata.Source) + "\n")
+  }
+  if r.Metadata.Rating != 0 {
+    output.WriteString(fmt.Sprintf("rating: %d\n", r.Metadata.Rating))
+  }
+  if r.Metadata.Favorited {
+    output.WriteString("favorite: true\n")
+  }
+  if r.Metadata.Yield != "" {
+    output.WriteString("yield: " + yamlString(r.Metadata.Yield) + "\n")
+  }
+  if !r.Metadata.Created.IsZero() {
+    output.WriteString("created: " + formatExportDate(r.Metadata.Created) + "\n")
+  }
+  if !r.Metadata.Modified.IsZero() {
+    output.WriteString("modified: " + formatExportDate(r.Metadata.Modified) + "\n")
+  }
+  for _, list := range []struct {
+    key string
+    values []string
+  }{
+    {"collections", r.Metadata.CollectionList},
+    {"courses", r.Metadata.CourseList},
+    {"categories", r.Metadata.CategoryList},
+  } {
+    if len(list.values) > 0 {
+      output.WriteString(list.key + ": " + yamlList(list.values) + "\n")
+    }
+  }
+  if tags := r.tags(options.TagCharset); options.HashTags && len(tags) > 0 {
+    output.WriteString("tags: " + yamlList(tags) + "\n")
+  }
+  output.WriteString("---\n\n")
+  return output.String()
+}
+
+// sourceComment records the recipe's Recipe Keeper id, and whether it is a
+// favourite, for files without front matter. Sync and round trip tools find
+// the recipe by it even after the file was renamed.
+func (r Recipe) sourceComment() string {
+  if r.Metadata.UUID == "" {
+    return ""
+  }
+  comment := "<!-- recipekeeper uuid=" + r.Metadata.UUID
+  if r.Metadata.Favorited {
+    comment += " favorite=true"
+  }
+  return comment + " -->\n"
+}
+
+var sourceCommentRe = regexp.MustCompile(`^<!-- recipekeeper uuid=(\S+)( favorite=true)? -->$`)
+
+// FormatRecipeMD renders a recipe as RecipeMD. It only fails on invalid options.
+func FormatRecipeMD(r Recipe, opts FormatOptions) (string, error) {
+  if err := opts.validate(); err != nil {
+    return "", err
+  }
+  return r.formatAsRecipeMD(opts), nil
+}
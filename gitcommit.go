@@ -0,0 +1,104 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+)
+
+func git(dir string, args ...string) (string, error) {
+  var stderr bytes.Buffer
+  cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+  cmd.Stderr = &stderr
+  output, err := cmd.Output()
+  if err != nil {
+    return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+  }
+  return string(output), nil
+}
+
+// IsGitRepo reports whether dir, or the closest folder above it that exists
+// yet, is inside a git work tree.
+func IsGitRepo(dir string) bool {
+  for {
+    if _, err := os.Stat(dir); err == nil {
+      break
+    }
+    parent := filepath.Dir(dir)
+    if parent == dir {
+      return false
+    }
+    dir = parent
+  }
+  output, err := git(dir, "rev-parse", "--is-inside-work-tree")
+  return err == nil && strings.TrimSpace(output) == "true"
+}
+
+// commitMessage summarizes the staged recipe files, e.g. "Imported 12
+// recipes, updated 3".
+func commitMessage(nameStatus string) string {
+  counts := map[string]int{}
+  for _, line := range strings.Split(nameStatus, "\n") {
+    status, path, found := strings.Cut(line, "\t")
+    if !found || filepath.Ext(path) != ".md" || filepath.Base(path) == indexFile {
+      continue
+    }
+    counts[status[:1]]++
+  }
+
+  parts := make([]string, 0, 3)
+  for _, change := range []struct {
+    status string
+    verb string
+  }{{"A", "imported"}, {"M", "updated"}, {"D", "removed"}} {
+    if n := counts[change.status]; n > 0 {
+      parts = append(parts, fmt.Sprintf("%s %d", change.verb, n))
+    }
+  }
+  if len(parts) == 0 {
+    return "Update converted recipes"
+  }
+
+  message := strings.Join(parts, ", ")
+  if counts["A"]+counts["M"]+counts["D"] == 1 {
+    message += " recipe"
+  } else if len(parts) == 1 {
+    message += " recipes"
+  } else {
+    // "Imported 12 recipes, updated 3"
+    first, rest, _ := strings.Cut(message, ", ")
+    message = first + " recipes, " + rest
+  }
+  return strings.ToUpper(message[:1]) + message[1:]
+}
+
+// GitCommitOutput stages everything in dir and commits it with a summary of
+// the recipe changes. It returns the message, or "" when nothing changed.
+func GitCommitOutput(dir string) (string, error) {
+  if _, err := git(dir, "add", "-A", "--", "."); err != nil {
+    return "", err
+  }
+  staged, err := git(dir, "diff", "--cached", "--name-status", "--no-renames", "--relative", "--", ".")
+  if err != nil {
+    return "", err
+  }
+  // the manifest's timestamp changes on every run, that alone isn't worth a commit
+  changed := false
+  for _, line := range strings.Split(strings.TrimSpace(staged), "\n") {
+    if _, path, _ := strings.Cut(line, "\t"); path != "" && path != manifestName {
+      changed = true
+    }
+  }
+  if !changed {
+    return "", nil
+  }
+
+  message := commitMessage(staged)
+  if _, err := git(dir, "commit", "--quiet", "-m", message, "--", "."); err != nil {
+    return "", err
+  }
+  return message, nil
+}
@@ -2,8 +2,9 @@ module github.com/kalebo/recipekeeper2recipemd
 
 go 1.19
 
+require github.com/PuerkitoBio/goquery v1.8.1
+
 require (
-	github.com/PuerkitoBio/goquery v1.8.1 // indirect
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	golang.org/x/net v0.7.0 // indirect
 )
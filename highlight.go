@@ -0,0 +1,85 @@
+package main
+
+import (
+  "regexp"
+  "sort"
+  "strings"
+  "unicode"
+)
+
+// Words in front of an ingredient's name that the steps usually leave out:
+// "2 ripe bananas" are just "the bananas" further down.
+var ingredientDescriptors = map[string]bool{
+  "fresh": true, "freshly": true, "dried": true, "frozen": true, "ripe": true,
+  "large": true, "medium": true, "small": true, "whole": true, "unsalted": true,
+  "salted": true, "raw": true, "cooked": true, "chopped": true, "ground": true,
+  "minced": true, "grated": true, "sliced": true, "diced": true, "softened": true,
+  "melted": true, "boneless": true, "skinless": true, "organic": true,
+}
+
+// Markdown the highlighting must not reach into: links, wiki links, code,
+// existing emphasis and HTML tags.
+var highlightSkipRe = regexp.MustCompile("\\[\\[[^\\]]*\\]\\]|\\[[^\\]]*\\]\\([^)]*\\)|`[^`]*`|\\*\\*[^*]*\\*\\*|<[^>]*>")
+
+var ingredientAlternativesRe = regexp.MustCompile(` (?:and|or|&) `)
+
+// ingredientHighlighter puts the recipe's ingredients in bold wherever the
+// steps mention them, so they stand out in long recipes.
+type ingredientHighlighter struct {
+  re *regexp.Regexp
+}
+
+func ingredientNames(ingredient Ingredient) []string {
+  names := make([]string, 0, 2)
+  // "salt and pepper" is mentioned as either just as often
+  for _, name := range ingredientAlternativesRe.Split(ingredient.Name, -1) {
+    words := strings.Fields(strings.ToLower(name))
+    // and what's left of amounts the parser couldn't make out, "-3 tbsp"
+    for len(words) > 0 && (ingredientDescriptors[strings.Trim(words[0], ",")] || len(words[0]) < 2 ||
+      !unicode.IsLetter([]rune(words[0])[0]) || LookupUnit(words[0]) != nil) {
+      words = words[1:]
+    }
+    if name := strings.Join(words, " "); len(name) >= 3 {
+      names = append(names, name)
+    }
+  }
+  return names
+}
+
+func newIngredientHighlighter(ingredients []Ingredient) *ingredientHighlighter {
+  seen := map[string]bool{}
+  names := make([]string, 0, len(ingredients))
+  for _, ingredient := range ingredients {
+    for _, name := range ingredientNames(ingredient) {
+      // plurals are matched by the pattern, "eggs" would miss "egg"
+      name = strings.TrimSuffix(name, "s")
+      if !seen[name] {
+        seen[name] = true
+        names = append(names, regexp.QuoteMeta(name))
+      }
+    }
+  }
+  if len(names) == 0 {
+    return &ingredientHighlighter{}
+  }
+  // the longest first, so "brown sugar" is found as a whole before "sugar"
+  sort.Slice(names, func(i, j int) bool {
+    return len(names[i]) > len(names[j])
+  })
+  return &ingredientHighlighter{re: regexp.MustCompile(`(?i)\b(?:` + strings.Join(names, "|") + `)(?:e?s)?\b`)}
+}
+
+func (h *ingredientHighlighter) Highlight(line string) string {
+  if h.re == nil || IsSectionHeading(line) {
+    return line
+  }
+  var output strings.Builder
+  last := 0
+  for _, skip := range highlightSkipRe.FindAllStringIndex(line, -1) {
+    output.WriteString(h.re.ReplaceAllString(line[last:skip[0]], "**$0**"))
+    output.WriteString(line[skip[0]:skip[1]])
+    last = skip[1]
+  }
+  output.WriteString(h.re.ReplaceAllString(line[last:], "**$0**"))
+  return output.String()
+}
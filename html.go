@@ -0,0 +1,326 @@
+package main
+
+import (
+  "fmt"
+  "html"
+  "net/http"
+  "net/url"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// schemaNutrition are the schema.org NutritionInformation properties of the
+// nutrition values.
+var schemaNutrition = map[string]string{
+  "Serving size": "servingSize",
+  "Calories": "calories",
+  "Total fat": "fatContent",
+  "Saturated fat": "saturatedFatContent",
+  "Sodium": "sodiumContent",
+  "Total carbohydrate": "carbohydrateContent",
+  "Dietary fiber": "fiberContent",
+  "Sugars": "sugarContent",
+  "Protein": "proteinContent",
+}
+
+// formatISODuration writes a duration the way schema.org wants it, "PT1H5M".
+func formatISODuration(d time.Duration) string {
+  d = d.Round(time.Minute)
+  duration := "PT"
+  if hours := int(d / time.Hour); hours > 0 {
+    duration += strconv.Itoa(hours) + "H"
+  }
+  if minutes := int((d % time.Hour) / time.Minute); minutes > 0 || d < time.Hour {
+    duration += strconv.Itoa(minutes) + "M"
+  }
+  return duration
+}
+
+// formatHTMLBody writes a recipe as (X)HTML for the outputs that are read
+// rather than edited, like the EPUB. photos are the src of the photos to
+// show, already resolved by the caller. With microdata the recipe is marked
+// up as a schema.org Recipe, for search engines and recipe apps to import.
+func (r Recipe) formatHTMLBody(options FormatOptions, photos []string, microdata bool) string {
+  var output strings.Builder
+  text := func(value string) string {
+    return html.EscapeString(options.fractions(value))
+  }
+  prop := func(name string) string {
+    if !microdata || name == "" {
+      return ""
+    }
+    return ` itemprop="` + name + `"`
+  }
+
+  if microdata {
+    output.WriteString("<article itemscope=\"itemscope\" itemtype=\"https://schema.org/Recipe\">\n")
+    if r.Metadata.UUID != "" {
+      output.WriteString(fmt.Sprintf("<meta itemprop=\"identifier\" content=\"%s\"/>\n", html.EscapeString(r.Metadata.UUID)))
+    }
+    for _, duration := range []struct {
+      name string
+      value time.Duration
+    }{
+      {"prepTime", r.Metadata.PrepTime},
+      {"cookTime", r.Metadata.CookTime},
+      {"totalTime", r.Metadata.TotalTime},
+    } {
+      if duration.value > 0 {
+        output.WriteString(fmt.Sprintf("<meta itemprop=\"%s\" content=\"%s\"/>\n", duration.name, formatISODuration(duration.value)))
+      }
+    }
+    for _, keyword := range append(append([]string{}, r.Metadata.CategoryList...), r.Metadata.CollectionList...) {
+      output.WriteString(fmt.Sprintf("<meta itemprop=\"keywords\" content=\"%s\"/>\n", html.EscapeString(keyword)))
+    }
+  }
+
+  output.WriteString(fmt.Sprintf("<h1%s>%s</h1>\n", prop("name"), html.EscapeString(r.Title)))
+  for _, src := range photos {
+    output.WriteString(fmt.Sprintf("<p class=\"photo\"><img%s src=\"%s\" alt=\"%s\"/></p>\n", prop("image"), html.EscapeString(src), html.EscapeString(r.Title)))
+  }
+  if r.Description != "" {
+    for _, paragraph := range strings.Split(r.Description, "\n\n") {
+      output.WriteString(fmt.Sprintf("<p class=\"description\"%s>%s</p>\n", prop("description"), strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br/>")))
+    }
+  }
+
+  metadata := make([]string, 0)
+  add := func(label string, name string, value string) {
+    if value != "" {
+      metadata = append(metadata, fmt.Sprintf("<li><b>%s:</b> <span%s>%s</span></li>", html.EscapeString(options.Labels.get(label)), prop(name), html.EscapeString(value)))
+    }
+  }
+  if r.Metadata.Rating > 0 && r.Metadata.Rating <= 5 {
+    add("Rating", "", strings.Repeat("★", r.Metadata.Rating)+strings.Repeat("☆", 5-r.Metadata.Rating))
+  }
+  add("Course", "recipeCategory", strings.Join(r.Metadata.CourseList, ", "))
+  add("Collections", "", strings.Join(r.Metadata.CollectionList, ", "))
+  add("Yield", "recipeYield", r.Metadata.Yield)
+  for _, duration := range []struct {
+    label string
+    value time.Duration
+  }{
+    {"Prep Time", r.Metadata.PrepTime},
+    {"Cook Time", r.Metadata.CookTime},
+    {"Rest Time", r.Metadata.RestTime},
+    {"Total Time", r.Metadata.TotalTime},
+  } {
+    if duration.value > 0 {
+      add(duration.label, "", FormatShortDuration(duration.value))
+    }
+  }
+  add("Source", "isBasedOn", r.Metadata.Source)
+  if len(metadata) > 0 {
+    output.WriteString("<ul class=\"metadata\">\n" + strings.Join(metadata, "\n") + "\n</ul>\n")
+  }
+
+  if len(r.IngredientLines) > 0 {
+    output.WriteString(fmt.Sprintf("<h2>%s</h2>\n", html.EscapeString(options.Labels.get("Ingredients"))))
+    list := false
+    for _, line := range r.IngredientLines {
+      if IsSectionHeading(line) {
+        if list {
+          output.WriteString("</ul>\n")
+          list = false
+        }
+        output.WriteString(fmt.Sprintf("<h3>%s</h3>\n", html.EscapeString(SectionTitle(line))))
+        continue
+      }
+      if !list {
+        output.WriteString("<ul class=\"ingredients\">\n")
+        list = true
+      }
+      output.WriteString(fmt.Sprintf("<li%s>%s</li>\n", prop("recipeIngredient"), text(line)))
+    }
+    if list {
+      output.WriteString("</ul>\n")
+    }
+  }
+
+  if len(r.InstructionLines) > 0 {
+    output.WriteString(fmt.Sprintf("<h2>%s</h2>\n", html.EscapeString(options.Labels.get("Instructions"))))
+    tag := map[StepsStyle]string{StepsNumbered: "ol", StepsBulleted: "ul"}[options.StepsStyle]
+    list := false
+    for _, line := range r.InstructionLines {
+      if IsSectionHeading(line) {
+        if list {
+          output.WriteString("</" + tag + ">\n")
+          list = false
+        }
+        output.WriteString(fmt.Sprintf("<h3>%s</h3>\n", html.EscapeString(SectionTitle(line))))
+        continue
+      }
+      if tag == "" {
+        output.WriteString(fmt.Sprintf("<p%s>%s</p>\n", prop("recipeInstructions"), text(line)))
+        continue
+      }
+      if !list {
+        output.WriteString("<" + tag + " class=\"steps\">\n")
+        list = true
+      }
+      output.WriteString(fmt.Sprintf("<li%s>%s</li>\n", prop("recipeInstructions"), text(line)))
+    }
+    if list {
+      output.WriteString("</" + tag + ">\n")
+    }
+  }
+
+  if len(r.NotesLines) > 0 {
+    output.WriteString(fmt.Sprintf("<h2>%s</h2>\n", html.EscapeString(options.Labels.get("Notes"))))
+    for _, line := range r.NotesLines {
+      output.WriteString(fmt.Sprintf("<p>%s</p>\n", text(line)))
+    }
+  }
+
+  nutrition := make([]string, 0)
+  for _, value := range r.Nutrition.Values() {
+    if value.Value != "" {
+      nutrition = append(nutrition, fmt.Sprintf("<li>%s: <span%s>%s</span></li>", html.EscapeString(options.Labels.get(value.Label)), prop(schemaNutrition[value.Label]), html.EscapeString(value.Value)))
+    }
+  }
+  if len(nutrition) > 0 {
+    output.WriteString(fmt.Sprintf("<h2>%s</h2>\n", html.EscapeString(options.Labels.get("Nutrition"))))
+    scope := ""
+    if microdata {
+      scope = ` itemprop="nutrition" itemscope="itemscope" itemtype="https://schema.org/NutritionInformation"`
+    }
+    output.WriteString("<ul class=\"nutrition\"" + scope + ">\n" + strings.Join(nutrition, "\n") + "\n</ul>\n")
+  }
+  if microdata {
+    output.WriteString("</article>\n")
+  }
+  return output.String()
+}
+
+// HTMLRenderer writes each recipe as a web page of its own, with the style
+// inside so it needs nothing but its photos, and an index.html linking them
+// all: the library as a small static site.
+type HTMLRenderer struct {
+  Options FormatOptions
+  // Language is the lang of the pages, "en" if unset.
+  Language string
+}
+
+func (h HTMLRenderer) page(title string, body string) []byte {
+  language := h.Language
+  if language == "" {
+    language = "en"
+  }
+  return []byte(`<!DOCTYPE html>
+<html lang="` + html.EscapeString(language) + `">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>` + html.EscapeString(title) + `</title>
+<style>
+` + htmlStyle + `</style>
+</head>
+<body>
+` + body + `</body>
+</html>
+`)
+}
+
+func (h HTMLRenderer) Render(r Recipe) ([]byte, error) {
+  return h.page(r.Title, r.formatHTMLBody(h.Options, r.PhotoPaths, true)), nil
+}
+
+func (HTMLRenderer) Ext() string {
+  return "html"
+}
+
+func init() {
+  RegisterFormat(OutputFormat{Name: "html", Exts: []string{"html", "htm"}, New: func(s RenderSettings) (Renderer, CollectionRenderer, error) {
+    return HTMLRenderer{Options: s.Options, Language: s.Language}, nil, nil
+  }})
+}
+
+func (h HTMLRenderer) RenderIndex(entries []IndexEntry) ([]byte, error) {
+  sorted := append([]IndexEntry(nil), entries...)
+  sort.SliceStable(sorted, func(i, j int) bool {
+    return strings.ToLower(sorted[i].Recipe.Title) < strings.ToLower(sorted[j].Recipe.Title)
+  })
+
+  var citations *Citations
+  if h.Options.Credits {
+    recipes := make([]Recipe, len(sorted))
+    for i, entry := range sorted {
+      recipes[i] = entry.Recipe
+    }
+    citations = NewCitations(recipes)
+  }
+
+  title := h.Options.Labels.get("Recipes")
+  var body strings.Builder
+  body.WriteString(fmt.Sprintf("<h1>%s</h1>\n<ul class=\"index\">\n", html.EscapeString(title)))
+  for _, entry := range sorted {
+    link := (&url.URL{Path: filepath.ToSlash(entry.Path)}).String()
+    details := strings.Join(entry.Recipe.Metadata.CourseList, ", ")
+    if details != "" {
+      details = " <small>" + html.EscapeString(details) + "</small>"
+    }
+    if n := citations.Marker(entry.Recipe); n > 0 {
+      details = creditMarkerHTML("", n) + details
+    }
+    body.WriteString(fmt.Sprintf("<li><a href=\"%s\">%s</a>%s</li>\n", html.EscapeString(link), html.EscapeString(entry.Recipe.Title), details))
+  }
+  body.WriteString("</ul>\n")
+  if citations != nil && citations.Len() > 0 {
+    body.WriteString(formatCreditsHTML(citations, h.Options, 2))
+  }
+  return h.page(title, body.String()), nil
+}
+
+// creditMarkerHTML links a recipe's citation number to its credit, which is
+// on page, or this page if that is empty.
+func creditMarkerHTML(page string, marker int) string {
+  return fmt.Sprintf(" <sup><a href=\"%s#credit-%d\">[%d]</a></sup>", page, marker, marker)
+}
+
+// formatCreditsHTML lists the sources under a heading of the given level.
+func formatCreditsHTML(citations *Citations, options FormatOptions, level int) string {
+  var output strings.Builder
+  output.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n<ol class=\"credits\">\n", level, html.EscapeString(options.Labels.get("Credits")), level))
+  for _, credit := range citations.Credits() {
+    output.WriteString(fmt.Sprintf("<li id=\"credit-%d\">%s — %s</li>\n", credit.Marker, credit.Citation.HTML(), html.EscapeString(strings.Join(credit.Titles, ", "))))
+  }
+  output.WriteString("</ol>\n")
+  return output.String()
+}
+
+func (HTMLRenderer) IndexFileName() string {
+  return "index.html"
+}
+
+// htmlStyle is the little styling the HTML outputs get, readable on paper,
+// screens and e-readers alike.
+const htmlStyle = `body { font-family: Georgia, serif; line-height: 1.5; max-width: 40em; margin: 0 auto; padding: 0 1em; }
+h1, h2, h3 { font-family: Helvetica, Arial, sans-serif; }
+img { max-width: 100%; height: auto; }
+.description { font-style: italic; }
+.metadata { list-style: none; padding: 0; font-size: 0.9em; }
+.steps li, .ingredients li { margin-bottom: 0.3em; }
+`
+
+// photoMediaType tells the type of a photo by its content, for the formats
+// that only take the ones every reader shows.
+func photoMediaType(content []byte) (string, string, bool) {
+  switch mediaType := http.DetectContentType(content); mediaType {
+  case "image/jpeg":
+    return mediaType, ".jpg", true
+  case "image/png":
+    return mediaType, ".png", true
+  case "image/gif":
+    return mediaType, ".gif", true
+  }
+  return "", "", false
+}
+
+// photoFileName names the i-th photo of the n-th recipe in a single file
+// output.
+func photoFileName(n int, i int, ext string) string {
+  return "images/recipe-" + strconv.Itoa(n) + "-" + strconv.Itoa(i+1) + ext
+}
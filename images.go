@@ -0,0 +1,230 @@
+package main
+
+import (
+  "encoding/base64"
+  "errors"
+  "fmt"
+  "io"
+  "mime"
+  "net/http"
+  "net/url"
+  "os"
+  "path"
+  "path/filepath"
+  "strings"
+  "time"
+)
+
+const imagesDir = "images"
+
+type PhotoDownloadOptions struct {
+  Timeout time.Duration
+  Retries int
+  MaxBytes int64
+}
+
+func DefaultPhotoDownloadOptions() PhotoDownloadOptions {
+  return PhotoDownloadOptions{
+    Timeout: 30 * time.Second,
+    Retries: 3,
+    MaxBytes: 20 << 20,
+  }
+}
+
+func isRemotePhoto(src string) bool {
+  u, err := url.Parse(src)
+  return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// permanentError marks failures that retrying won't fix.
+type permanentError struct {
+  error
+}
+
+func (e permanentError) Unwrap() error {
+  return e.error
+}
+
+// withRetry runs fn until it succeeds, doubling the wait between attempts.
+func withRetry(attempts int, fn func() error) error {
+  wait := 500 * time.Millisecond
+  var err error
+  for attempt := 0; attempt <= attempts; attempt++ {
+    if attempt > 0 {
+      time.Sleep(wait)
+      wait *= 2
+    }
+    err = fn()
+    var permanent permanentError
+    if err == nil || errors.As(err, &permanent) {
+      return err
+    }
+  }
+  return err
+}
+
+var errPhotoTooLarge = errors.New("photo exceeds the size limit")
+
+// DownloadPhoto fetches a remote photo and returns its content along with a
+// file extension guessed from the URL or the response's content type.
+func DownloadPhoto(src string, options PhotoDownloadOptions) ([]byte, string, error) {
+  client := http.Client{Timeout: options.Timeout}
+  var content []byte
+  var ext string
+
+  err := withRetry(options.Retries, func() error {
+    response, err := client.Get(src)
+    if err != nil {
+      return err
+    }
+    defer response.Body.Close()
+
+    if response.StatusCode != http.StatusOK {
+      return fmt.Errorf("unexpected response %s", response.Status)
+    }
+    if options.MaxBytes > 0 && response.ContentLength > options.MaxBytes {
+      return permanentError{errPhotoTooLarge}
+    }
+
+    reader := io.Reader(response.Body)
+    if options.MaxBytes > 0 {
+      reader = io.LimitReader(response.Body, options.MaxBytes+1)
+    }
+    content, err = io.ReadAll(reader)
+    if err != nil {
+      return err
+    }
+    if options.MaxBytes > 0 && int64(len(content)) > options.MaxBytes {
+      return permanentError{errPhotoTooLarge}
+    }
+
+    ext = photoExt(src, response.Header.Get("Content-Type"))
+    return nil
+  })
+
+  return content, ext, err
+}
+
+func isDataURI(src string) bool {
+  return strings.HasPrefix(src, "data:")
+}
+
+// DecodeDataURI decodes an inline "data:image/png;base64,..." photo.
+func DecodeDataURI(src string) ([]byte, string, error) {
+  header, data, found := strings.Cut(strings.TrimPrefix(src, "data:"), ",")
+  if !found {
+    return nil, "", errors.New("malformed data URI")
+  }
+
+  params := strings.Split(header, ";")
+  mediaType := strings.TrimSpace(params[0])
+  if mediaType == "" {
+    mediaType = "text/plain"
+  }
+
+  if params[len(params)-1] == "base64" {
+    // exports sometimes wrap the base64 text over several lines
+    content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+    return content, mediaType, err
+  }
+
+  content, err := url.PathUnescape(data)
+  return []byte(content), mediaType, err
+}
+
+// photoLabel shortens a photo reference for messages, data URIs can be huge.
+func photoLabel(src string) string {
+  if isDataURI(src) {
+    header, _, _ := strings.Cut(src, ",")
+    return header + ",..."
+  }
+  return src
+}
+
+func photoExt(src string, contentType string) string {
+  if u, err := url.Parse(src); err == nil && !isDataURI(src) {
+    if ext := strings.ToLower(path.Ext(u.Path)); ext != "" && len(ext) <= 5 {
+      return ext
+    }
+  }
+  if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+    switch mediaType {
+    case "image/jpeg":
+      return ".jpg"
+    case "image/png":
+      return ".png"
+    case "image/gif":
+      return ".gif"
+    case "image/webp":
+      return ".webp"
+    }
+  }
+  return ".jpg"
+}
+
+func photoName(r Recipe, index int, ext string) string {
+  return fmt.Sprintf("%s-%d%s", r.Metadata.UUID, index+1, ext)
+}
+
+// processPhotos copies the recipe's photos into the images folder next to the
+// output and points PhotoPaths at the copies. Photos that can't be copied keep
+// their original reference.
+func (c *Converter) processPhotos(r Recipe) Recipe {
+  if len(r.PhotoPaths) == 0 {
+    return r
+  }
+
+  if err := os.MkdirAll(filepath.Join(c.OutputDir, c.ImagesDir), 0755); err != nil {
+    c.emit(ConversionWarning, r, err.Error())
+    return r
+  }
+
+  photos := make([]string, 0, len(r.PhotoPaths))
+  for i, src := range r.PhotoPaths {
+    var content []byte
+    var ext string
+    var err error
+
+    if isRemotePhoto(src) {
+      if !c.DownloadPhotos {
+        photos = append(photos, src)
+        continue
+      }
+      content, ext, err = DownloadPhoto(src, c.PhotoDownload)
+    } else if isDataURI(src) {
+      var mediaType string
+      content, mediaType, err = DecodeDataURI(src)
+      ext = photoExt(src, mediaType)
+    } else {
+      exportDir := r.exportDir
+      if exportDir == "" {
+        exportDir = c.ExportDir
+      }
+      var path string
+      if path, err = confinedPath(exportDir, src); err == nil {
+        content, err = os.ReadFile(path)
+      }
+      ext = strings.ToLower(filepath.Ext(src))
+    }
+
+    if err == nil {
+      var stored string
+      stored, err = c.storePhoto(r, i, content, ext)
+      if err == nil {
+        if isSiteLayout(c.Layout) {
+          photos = append(photos, siteURL(c.Layout, stored))
+        } else {
+          photos = append(photos, c.relativeTo(r, stored))
+        }
+        c.emit(ImageProcessed, r, photoLabel(src))
+        continue
+      }
+    }
+
+    c.emit(ConversionWarning, r, "photo "+photoLabel(src)+": "+err.Error())
+    photos = append(photos, src)
+  }
+
+  r.PhotoPaths = photos
+  return r
+}
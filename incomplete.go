@@ -0,0 +1,79 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Recipes missing their title or ingredients make for broken files: a bare
+// "#" heading, or nothing to cook with. What happens to them is up to
+// Converter.Incomplete, and they are always reported.
+
+const (
+  // IncompletePlaceholder writes them anyway, titling untitled ones after
+  // their id.
+  IncompletePlaceholder = "placeholder"
+  IncompleteSkip = "skip"
+  // IncompleteFail stops the conversion before anything is written.
+  IncompleteFail = "fail"
+)
+
+// IncompleteReason tells what a recipe is missing, or "" if nothing.
+func IncompleteReason(r Recipe) string {
+  missing := make([]string, 0, 2)
+  if strings.TrimSpace(r.Title) == "" {
+    missing = append(missing, "title")
+  }
+  if len(r.IngredientLines) == 0 {
+    missing = append(missing, "ingredients")
+  }
+  if len(missing) == 0 {
+    return ""
+  }
+  return "no " + strings.Join(missing, " or ")
+}
+
+// placeholderTitle names an untitled recipe by the start of its id, which is
+// enough to find it in the app.
+func placeholderTitle(r Recipe) string {
+  id := r.Metadata.UUID
+  if len(id) > 8 {
+    id = id[:8]
+  }
+  return fmt.Sprintf("Untitled recipe %s", id)
+}
+
+// handleIncomplete applies the Incomplete policy, returning the recipes to
+// write.
+func (c *Converter) handleIncomplete(recipes []Recipe) ([]Recipe, error) {
+  kept := make([]Recipe, 0, len(recipes))
+  failed := make([]string, 0)
+  for _, recipe := range recipes {
+    reason := IncompleteReason(recipe)
+    if reason == "" {
+      kept = append(kept, recipe)
+      continue
+    }
+
+    switch c.Incomplete {
+    case IncompleteSkip:
+      c.emit(RecipeSkipped, recipe, "incomplete, "+reason)
+      continue
+    case IncompleteFail:
+      c.emit(IncompleteRecipe, recipe, reason)
+      failed = append(failed, recipe.Metadata.UUID)
+      continue
+    }
+    if strings.TrimSpace(recipe.Title) == "" {
+      recipe.Title = placeholderTitle(recipe)
+      reason += fmt.Sprintf(", titled %q", recipe.Title)
+    }
+    c.emit(IncompleteRecipe, recipe, reason)
+    kept = append(kept, recipe)
+  }
+
+  if len(failed) > 0 {
+    return nil, fmt.Errorf("%d incomplete recipe(s): %s", len(failed), strings.Join(failed, ", "))
+  }
+  return kept, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+  "fmt"
+  "net/url"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+// indexFile is the table of contents written next to the recipes so the output
+// folder can be browsed as is (on GitHub, in Obsidian, ...).
+const indexFile = "index.md"
+
+type IndexEntry struct {
+  Recipe Recipe
+  // Path of the recipe file relative to the output directory.
+  Path string
+}
+
+func escapeTableCell(text string) string {
+  return strings.ReplaceAll(text, "|", "\\|")
+}
+
+// FormatIndex lists the recipes in a table, with their sources numbered and
+// credited below it if options.Credits is set.
+func FormatIndex(entries []IndexEntry, options FormatOptions) string {
+  sorted := append([]IndexEntry(nil), entries...)
+  sort.SliceStable(sorted, func(i, j int) bool {
+    return strings.ToLower(sorted[i].Recipe.Title) < strings.ToLower(sorted[j].Recipe.Title)
+  })
+  var citations *Citations
+  if options.Credits {
+    recipes := make([]Recipe, len(sorted))
+    for i, entry := range sorted {
+      recipes[i] = entry.Recipe
+    }
+    citations = NewCitations(recipes)
+  }
+
+  var output strings.Builder
+  output.WriteString("# Recipes\n\n")
+  output.WriteString(fmt.Sprintf("%d recipes.\n\n", len(sorted)))
+  // the date added is only a column when the export had the dates
+  dated := false
+  for _, entry := range sorted {
+    dated = dated || !entry.Recipe.Metadata.Created.IsZero()
+  }
+  if dated {
+    output.WriteString("| Recipe | Rating | Course | Collections | Cook Time | Added |\n")
+    output.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+  } else {
+    output.WriteString("| Recipe | Rating | Course | Collections | Cook Time |\n")
+    output.WriteString("| --- | --- | --- | --- | --- |\n")
+  }
+
+  for _, entry := range sorted {
+    r := entry.Recipe
+    link := (&url.URL{Path: filepath.ToSlash(entry.Path)}).String()
+
+    rating := ""
+    if r.Metadata.Rating > 0 {
+      rating = strings.Repeat("★", r.Metadata.Rating)
+    }
+    cookTime := ""
+    if r.Metadata.CookTime > 0 {
+      cookTime = FormatShortDuration(r.Metadata.CookTime)
+    }
+
+    marker := ""
+    if n := citations.Marker(r); n > 0 {
+      marker = fmt.Sprintf(" [%d]", n)
+    }
+    output.WriteString(fmt.Sprintf("| [%s](%s)%s | %s | %s | %s | %s |",
+      escapeTableCell(r.Title),
+      link,
+      marker,
+      rating,
+      escapeTableCell(strings.Join(r.Metadata.CourseList, ", ")),
+      escapeTableCell(strings.Join(r.Metadata.CollectionList, ", ")),
+      cookTime,
+    ))
+    if dated {
+      added := ""
+      if !r.Metadata.Created.IsZero() {
+        added = r.Metadata.Created.Format("2006-01-02")
+      }
+      output.WriteString(" " + added + " |")
+    }
+    output.WriteString("\n")
+  }
+
+  if citations != nil && citations.Len() > 0 {
+    output.WriteString("\n" + citations.FormatCredits(options))
+  }
+  return output.String()
+}
+
+func WriteIndex(dir string, entries []IndexEntry, options FormatOptions) error {
+  return os.WriteFile(filepath.Join(dir, indexFile), []byte(FormatIndex(entries, options)), 0644)
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+  "fmt"
+  "html"
+  "regexp"
+  "strconv"
+  "strings"
+  "unicode"
+)
+
+type UnitKind int
+
+const (
+  UnitCount UnitKind = iota
+  UnitVolume
+  UnitWeight
+)
+
+// Factor converts one of the unit to millilitres (volume) or grams (weight).
+type Unit struct {
+  Name string
+  Kind UnitKind
+  Factor float64
+  Aliases []string
+}
+
+var units = []Unit{
+  {"tsp", UnitVolume, 4.92892, []string{"tsp", "tsps", "teaspoon", "teaspoons", "t"}},
+  {"tbsp", UnitVolume, 14.7868, []string{"tbsp", "tbsps", "tbs", "tbl", "tablespoon", "tablespoons", "T"}},
+  {"cup", UnitVolume, 236.588, []string{"cup", "cups", "c"}},
+  {"fl oz", UnitVolume, 29.5735, []string{"fl oz", "fl. oz.", "fluid ounce", "fluid ounces"}},
+  {"pint", UnitVolume, 473.176, []string{"pint", "pints", "pt"}},
+  {"quart", UnitVolume, 946.353, []string{"quart", "quarts", "qt"}},
+  {"gallon", UnitVolume, 3785.41, []string{"gallon", "gallons", "gal"}},
+  {"ml", UnitVolume, 1, []string{"ml", "mL", "millilitre", "millilitres", "milliliter", "milliliters"}},
+  {"cl", UnitVolume, 10, []string{"cl", "centilitre", "centilitres", "centiliter", "centiliters"}},
+  {"dl", UnitVolume, 100, []string{"dl", "decilitre", "decilitres", "deciliter", "deciliters"}},
+  {"l", UnitVolume, 1000, []string{"l", "L", "litre", "litres", "liter", "liters"}},
+  {"oz", UnitWeight, 28.3495, []string{"oz", "ounce", "ounces"}},
+  {"lb", UnitWeight, 453.592, []string{"lb", "lbs", "pound", "pounds"}},
+  {"mg", UnitWeight, 0.001, []string{"mg", "milligram", "milligrams"}},
+  {"g", UnitWeight, 1, []string{"g", "gr", "gram", "grams", "gramme", "grammes"}},
+  {"kg", UnitWeight, 1000, []string{"kg", "kilo", "kilos", "kilogram", "kilograms"}},
+  {"pinch", UnitCount, 0, []string{"pinch", "pinches"}},
+  {"dash", UnitCount, 0, []string{"dash", "dashes"}},
+  {"clove", UnitCount, 0, []string{"clove", "cloves"}},
+  {"can", UnitCount, 0, []string{"can", "cans", "tin", "tins"}},
+  {"package", UnitCount, 0, []string{"package", "packages", "pkg", "packet", "packets"}},
+  {"slice", UnitCount, 0, []string{"slice", "slices"}},
+  {"stick", UnitCount, 0, []string{"stick", "sticks"}},
+  {"bunch", UnitCount, 0, []string{"bunch", "bunches"}},
+  {"handful", UnitCount, 0, []string{"handful", "handfuls"}},
+  {"sprig", UnitCount, 0, []string{"sprig", "sprigs"}},
+}
+
+// Single letter abbreviations are case sensitive (t = tsp, T = tbsp), the rest
+// are matched case insensitively.
+var unitsByAlias = func() map[string]*Unit {
+  byAlias := map[string]*Unit{}
+  for i := range units {
+    for _, alias := range units[i].Aliases {
+      if len(alias) == 1 {
+        byAlias[alias] = &units[i]
+      } else {
+        byAlias[strings.ToLower(alias)] = &units[i]
+      }
+    }
+  }
+  return byAlias
+}()
+
+func LookupUnit(name string) *Unit {
+  name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+  if len(name) == 1 {
+    return unitsByAlias[name]
+  }
+  return unitsByAlias[strings.ToLower(name)]
+}
+
+// An ingredient line split into its parts, e.g. "1 1/2 cups flour".
+type Ingredient struct {
+  Raw string
+  // AmountStart is where the amount starts in Raw, after any qualifier like
+  // "Optional:" in front of it.
+  AmountStart int
+  AmountText string
+  // Amount is the low end of a range like "2-3" or "1 to 2", and AmountMax
+  // its high end. AmountMax is 0 for a single amount.
+  Amount float64
+  AmountMax float64
+  Unit string
+  UnitText string
+  // AltText is the same quantity in other units, given in parentheses after
+  // the first, "240 ml" in "1 cup (240 ml) milk". AltAmountText, AltAmount
+  // and AltUnit are its parts.
+  AltText string
+  AltAmountText string
+  AltAmount float64
+  AltUnit string
+  // PackageText is the size of what is counted, "14 oz" in "1 can (14 oz)
+  // tomatoes" or "2 (14 oz) cans tomatoes". Unlike the amount it isn't
+  // scaled.
+  PackageText string
+  // packageFirst is set when the size comes before the unit, as in "2 (14
+  // oz) cans".
+  packageFirst bool
+  Name string
+  // Note is what follows the name after a comma or in parentheses, e.g.
+  // "chopped" in "1 onion, chopped".
+  Note string
+  // Group is the heading the ingredient is listed under ("For the sauce:").
+  Group string
+  // Divided is set when the note says the ingredient is used in parts
+  // across the steps, as in "2 cups sugar, divided". See DividedSplits.
+  Divided bool
+  // ToTaste, Optional and Garnish are set by the qualifiers "to taste" (or
+  // "as needed"), "optional" and "for garnish" (or "to serve"), which are
+  // moved into the Note.
+  ToTaste bool
+  Optional bool
+  Garnish bool
+  // Confidence, between 0 and 1, is how sure we are that the line was split
+  // into amount, unit and name correctly.
+  Confidence float64
+}
+
+const amountPattern = `\d+\s+\d+/\d+|\d+/\d+|\d+(?:[.,]\d+)?`
+
+var amountRe = regexp.MustCompile(`^(` + amountPattern + `)`)
+
+// amount ranges, "2-3", "1 1/2 – 2" or "1 to 2"
+var amountRangeRe = regexp.MustCompile(`^(` + amountPattern + `)(\s*[-–]\s*|\s+to\s+)(` + amountPattern + `)`)
+
+// splitRange splits an amount range into its ends and what's between them.
+func splitRange(text string) (string, string, string, bool) {
+  match := amountRangeRe.FindStringSubmatch(strings.TrimSpace(text))
+  if match == nil || len(match[0]) != len(strings.TrimSpace(text)) {
+    return "", "", "", false
+  }
+  return match[1], match[2], match[3], true
+}
+
+// parseAmountRange reads an amount or a range of them at the start of text,
+// returning what it read. high is 0 unless it is a range.
+func parseAmountRange(text string) (string, float64, float64, bool) {
+  if match := amountRangeRe.FindStringSubmatch(text); match != nil {
+    low, lowOK := ParseAmount(match[1])
+    high, highOK := ParseAmount(match[3])
+    if lowOK && highOK && high > low {
+      return match[0], low, high, true
+    }
+  }
+  amount := amountRe.FindString(text)
+  if amount == "" {
+    return "", 0, 0, false
+  }
+  value, ok := ParseAmount(amount)
+  return amount, value, 0, ok
+}
+
+func ParseAmount(text string) (float64, bool) {
+  text = strings.TrimSpace(text)
+  whole := 0.0
+  if fields := strings.Fields(text); len(fields) == 2 {
+    w, err := strconv.ParseFloat(fields[0], 64)
+    if err != nil {
+      return 0, false
+    }
+    whole = w
+    text = fields[1]
+  }
+
+  if numerator, denominator, found := strings.Cut(text, "/"); found {
+    n, err := strconv.ParseFloat(numerator, 64)
+    if err != nil {
+      return 0, false
+    }
+    d, err := strconv.ParseFloat(denominator, 64)
+    if err != nil || d == 0 {
+      return 0, false
+    }
+    return whole + n/d, true
+  }
+
+  value, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", "."), 64)
+  if err != nil {
+    return 0, false
+  }
+  return whole + value, true
+}
+
+var digitRe = regexp.MustCompile(`\d`)
+
+var (
+  qualifierRe = regexp.MustCompile(`(?i)\b(?:to taste|as needed|optional|(?:for|to) (?:garnish(?:ing)?|serv(?:e|ing)|decorat(?:e|ion)))\b`)
+  // "Optional: 1 tsp chili flakes", "(to taste) salt"
+  qualifierPrefixRe = regexp.MustCompile(`(?i)^(?:\((optional|to taste)\)|(optional|to taste)\s*:)\s*`)
+  toTasteRe = regexp.MustCompile(`(?i)\b(?:to taste|as needed)\b`)
+  optionalRe = regexp.MustCompile(`(?i)\boptional\b`)
+  garnishRe = regexp.MustCompile(`(?i)\b(?:for|to) (?:garnish|serv|decorat)`)
+)
+
+func ParseIngredient(line string) Ingredient {
+  rest := strings.TrimSpace(line)
+  start := strings.Index(line, rest)
+  prefix := ""
+  if match := qualifierPrefixRe.FindStringSubmatch(rest); match != nil {
+    prefix = strings.ToLower(match[1] + match[2])
+    rest = rest[len(match[0]):]
+    start += len(match[0])
+  }
+  ingredient := Ingredient{Raw: line, AmountStart: start, Name: rest, Confidence: 1}
+
+  amount, value, high, ok := parseAmountRange(rest)
+  if amount == "" {
+    // "salt to taste" is fine, "juice of 2 lemons" has an amount we missed
+    if digitRe.MatchString(rest) {
+      ingredient.Confidence = 0.3
+    }
+    ingredient.readNote(prefix)
+    return ingredient
+  }
+  if !ok {
+    ingredient.Confidence = 0
+    return ingredient
+  }
+  ingredient.AmountText = amount
+  ingredient.Amount = value
+  ingredient.AmountMax = high
+  rest = strings.TrimSpace(rest[len(amount):])
+
+  // the size of what is counted, before the unit, "2 (14 oz) cans tomatoes"
+  if size, after, ok := measurementInParentheses(rest); ok {
+    ingredient.PackageText = size.text
+    ingredient.packageFirst = true
+    rest = after
+  }
+
+  // Try the longer multi word units ("fl oz") before single words
+  words := strings.Fields(rest)
+  for n := 2; n >= 1; n-- {
+    if len(words) < n {
+      continue
+    }
+    candidate := strings.Join(words[:n], " ")
+    if unit := LookupUnit(candidate); unit != nil {
+      ingredient.Unit = unit.Name
+      ingredient.UnitText = candidate
+      rest = strings.TrimSpace(strings.Join(words[n:], " "))
+      break
+    }
+  }
+
+  // the same in other units, "1 cup (240 ml) milk", or the size of what is
+  // counted, "1 can (14 oz) tomatoes"
+  if unit := LookupUnit(ingredient.Unit); unit != nil && ingredient.PackageText == "" {
+    if measurement, after, ok := measurementInParentheses(rest); ok {
+      if unit.Kind == UnitCount {
+        ingredient.PackageText = measurement.text
+      } else {
+        ingredient.AltText = measurement.text
+        ingredient.AltAmountText = measurement.amount
+        ingredient.AltAmount = measurement.value
+        ingredient.AltUnit = measurement.unit
+      }
+      rest = after
+    }
+  }
+
+  ingredient.Name = strings.TrimPrefix(rest, "of ")
+
+  switch {
+  case ingredient.Name == "":
+    ingredient.Confidence = 0.2
+  case strings.ContainsAny(ingredient.Name[:1], "-–/x(0123456789"):
+    // multiples ("2 x 400g"), ranges we didn't read ("3-2") and the like
+    ingredient.Confidence = 0.4
+  case ingredient.Unit == "":
+    ingredient.Confidence = 0.8
+  }
+  ingredient.readNote(prefix)
+  return ingredient
+}
+
+type measurement struct {
+  text string
+  amount string
+  value float64
+  unit string
+}
+
+// measurementInParentheses reads a volume or weight in parentheses at the
+// start of text, "(240 ml)", returning it and the text after it.
+func measurementInParentheses(text string) (measurement, string, bool) {
+  end := strings.Index(text, ")")
+  if !strings.HasPrefix(text, "(") || end < 0 {
+    return measurement{}, text, false
+  }
+  inner := strings.TrimSpace(text[1:end])
+  amount, value, _, ok := parseAmountRange(inner)
+  if !ok || amount == "" {
+    return measurement{}, text, false
+  }
+  unit := LookupUnit(strings.TrimSpace(inner[len(amount):]))
+  if unit == nil || unit.Kind == UnitCount {
+    return measurement{}, text, false
+  }
+  return measurement{inner, amount, value, unit.Name}, strings.TrimSpace(text[end+1:]), true
+}
+
+// readNote splits the note off the name, along with a qualifier at the end
+// of the name ("salt and pepper to taste") or given before the line.
+func (i *Ingredient) readNote(prefix string) {
+  i.Name, i.Note = splitNote(i.Name)
+  notes := make([]string, 0, 3)
+  if loc := qualifierRe.FindStringIndex(i.Name); loc != nil && loc[0] > 0 {
+    notes = append(notes, i.Name[loc[0]:])
+    i.Name = strings.TrimRight(i.Name[:loc[0]], " ,;-–")
+  }
+  if i.Note != "" {
+    notes = append(notes, i.Note)
+  }
+  if prefix != "" && !qualifierRe.MatchString(i.Note) {
+    notes = append(notes, prefix)
+  }
+  i.Note = strings.Join(notes, ", ")
+
+  i.Divided = dividedRe.MatchString(i.Note)
+  i.ToTaste = toTasteRe.MatchString(i.Note)
+  i.Optional = optionalRe.MatchString(i.Note)
+  i.Garnish = garnishRe.MatchString(i.Note)
+}
+
+// Qualifier is what sets the ingredient apart from those that have to be
+// measured out: "optional", "to taste" (or "as needed"), "for garnish" or ""
+// for none.
+func (i Ingredient) Qualifier() string {
+  switch {
+  case i.Optional:
+    return "optional"
+  case i.ToTaste:
+    return strings.ToLower(toTasteRe.FindString(i.Note))
+  case i.Garnish:
+    return "for garnish"
+  }
+  return ""
+}
+
+func splitNote(name string) (string, string) {
+  if name, note, found := strings.Cut(name, ", "); found {
+    return strings.TrimSpace(name), strings.TrimSpace(note)
+  }
+  if strings.HasPrefix(name, "(") {
+    if end := strings.Index(name, ")"); end > 0 {
+      return strings.TrimSpace(name[end+1:]), name[1:end]
+    }
+  }
+  if start := strings.LastIndex(name, " ("); start > 0 && strings.HasSuffix(name, ")") {
+    return name[:start], name[start+2 : len(name)-1]
+  }
+  return name, ""
+}
+
+// ParseIngredients parses a recipe's ingredient lines. Lines like "For the
+// sauce:" aren't ingredients themselves but set the Group of those after them.
+func ParseIngredients(lines []string) []Ingredient {
+  ingredients := make([]Ingredient, 0, len(lines))
+  group := ""
+  for _, line := range lines {
+    if IsSectionHeading(line) {
+      group = SectionTitle(line)
+      continue
+    }
+    ingredient := ParseIngredient(line)
+    ingredient.Group = group
+    ingredients = append(ingredients, ingredient)
+  }
+  return ingredients
+}
+
+// Quantity is the amount and unit as written in the line, e.g. "2 cups",
+// "1 cup (240 ml)" or "1 can (14 oz)".
+func (i Ingredient) Quantity() string {
+  quantity := i.AmountText
+  if i.packageFirst {
+    quantity += " (" + i.PackageText + ")"
+  }
+  if i.UnitText != "" {
+    quantity += " " + i.UnitText
+  }
+  if i.AltText != "" {
+    quantity += " (" + i.AltText + ")"
+  } else if i.PackageText != "" && !i.packageFirst {
+    quantity += " (" + i.PackageText + ")"
+  }
+  return quantity
+}
+
+// splitAt finds text, the amount or the whole quantity, where the amount
+// starts in the line, and returns what comes before it ("Optional: ") and
+// after it.
+func (i Ingredient) splitAt(text string) (string, string, bool) {
+  if text == "" || i.AmountStart > len(i.Raw) || !strings.HasPrefix(i.Raw[i.AmountStart:], text) {
+    return "", "", false
+  }
+  return strings.TrimLeft(i.Raw[:i.AmountStart], " \t"), i.Raw[i.AmountStart+len(text):], true
+}
+
+// WrapQuantity replaces the amount and unit of the line with wrap(quantity).
+// Lines we aren't at least minConfidence sure about are returned unchanged.
+func (i Ingredient) WrapQuantity(minConfidence float64, wrap func(string) string) string {
+  quantity := i.Quantity()
+  before, after, ok := i.splitAt(quantity)
+  if !ok || i.Confidence < minConfidence {
+    return i.Raw
+  }
+  return before + wrap(quantity) + strings.TrimRightFunc(after, unicode.IsSpace)
+}
+
+// Emphasized writes the line the way RecipeMD marks amounts, "*2 cups* flour".
+func (i Ingredient) Emphasized(minConfidence float64) string {
+  return i.WrapQuantity(minConfidence, func(quantity string) string {
+    return "*" + quantity + "*"
+  })
+}
+
+// Markup puts text in a span carrying the parsed amount and unit, e.g.
+// <span data-amount="1.5" data-unit="cup">1 1/2 cups</span>.
+func (i Ingredient) Markup(text string) string {
+  attributes := fmt.Sprintf(` data-amount="%s"`, strconv.FormatFloat(i.Amount, 'f', -1, 64))
+  if i.AmountMax > 0 {
+    attributes += fmt.Sprintf(` data-amount-max="%s"`, strconv.FormatFloat(i.AmountMax, 'f', -1, 64))
+  }
+  if i.Unit != "" {
+    attributes += fmt.Sprintf(` data-unit="%s"`, html.EscapeString(i.Unit))
+  }
+  return "<span" + attributes + ">" + text + "</span>"
+}
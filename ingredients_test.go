@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestAlternateMeasurements(t *testing.T) {
+  for _, test := range []struct {
+    line string
+    alt string
+    size string
+    scaled string
+    imperial string
+  }{
+    {"1 cup (240 ml) milk", "240 ml", "", "2 cup (480 ml) milk", "1 cup milk"},
+    {"200 g (7 oz) paneer", "7 oz", "", "400 g (14 oz) paneer", "7 oz paneer"},
+    // package sizes of counted things aren't the same amount in other units,
+    // only the count is scaled
+    {"1 can (14 oz) diced tomatoes", "", "14 oz", "2 can (14 oz) diced tomatoes", "1 can (14 oz) diced tomatoes"},
+    {"1 package (8 oz) cream cheese", "", "8 oz", "2 package (8 oz) cream cheese", "1 package (8 oz) cream cheese"},
+    {"2 (14 oz) cans tomatoes", "", "14 oz", "4 (14 oz) cans tomatoes", "2 (14 oz) cans tomatoes"},
+  } {
+    ingredient := ParseIngredient(test.line)
+    if ingredient.AltText != test.alt || ingredient.PackageText != test.size {
+      t.Errorf("%q: alternate %q and size %q, want %q and %q", test.line, ingredient.AltText, ingredient.PackageText, test.alt, test.size)
+    }
+    if ingredient.Confidence < minRewriteConfidence {
+      t.Errorf("%q: confidence %g", test.line, ingredient.Confidence)
+    }
+    if scaled := ScaleIngredient(test.line, 2); scaled != test.scaled {
+      t.Errorf("%q scaled by 2: got %q, want %q", test.line, scaled, test.scaled)
+    }
+    if imperial := PreferIngredientUnits(test.line, UnitsImperial); imperial != test.imperial {
+      t.Errorf("%q preferring imperial: got %q, want %q", test.line, imperial, test.imperial)
+    }
+  }
+}
+
+func TestQualifierPrefixes(t *testing.T) {
+  for _, test := range []struct {
+    line string
+    scaled string
+    metric string
+    emphasized string
+  }{
+    // spoons stay spoons in metric
+    {"Optional: 1 tsp chili flakes", "Optional: 2 tsp chili flakes", "Optional: 1 tsp chili flakes", "Optional: *1 tsp* chili flakes"},
+    {"Optional: 2 cups stock", "Optional: 4 cups stock", "Optional: 475 ml stock", "Optional: *2 cups* stock"},
+    {"(to taste) 1/2 cup milk", "(to taste) 1 cup milk", "(to taste) 120 ml milk", "(to taste) *1/2 cup* milk"},
+    {"Optional: 1 cup (240 ml) cream", "Optional: 2 cup (480 ml) cream", "Optional: 240 ml cream", "Optional: *1 cup (240 ml)* cream"},
+  } {
+    if scaled := ScaleIngredient(test.line, 2); scaled != test.scaled {
+      t.Errorf("%q scaled by 2: got %q, want %q", test.line, scaled, test.scaled)
+    }
+    if metric := ConvertIngredientUnits(test.line, UnitsMetric, nil, false); metric != test.metric {
+      t.Errorf("%q in metric: got %q, want %q", test.line, metric, test.metric)
+    }
+    if emphasized := ParseIngredient(test.line).Emphasized(0.7); emphasized != test.emphasized {
+      t.Errorf("%q emphasized: got %q, want %q", test.line, emphasized, test.emphasized)
+    }
+  }
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "html"
+  "io"
+  "math"
+  "regexp"
+  "strconv"
+  "strings"
+
+  "github.com/PuerkitoBio/goquery"
+)
+
+// Most recipe sites don't use microdata but a JSON-LD script with the same
+// schema.org/Recipe in it, often inside a @graph next to the page's other
+// things. Its values are loosely typed: a string where one would expect a
+// list, a number where a string, text with HTML in it.
+
+// jsonLDObject is a node of the JSON-LD, as decoded by encoding/json.
+type jsonLDObject map[string]interface{}
+
+var jsonLDTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// tags that end a line of text, for instructions given as one HTML string
+var jsonLDBreakRe = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>`)
+
+// jsonLDString is the text of a value: numbers and strings as they are,
+// with any HTML taken out, and objects by their text, name or @id.
+func jsonLDString(value interface{}) string {
+  switch value := value.(type) {
+  case string:
+    return strings.TrimSpace(html.UnescapeString(jsonLDTagRe.ReplaceAllString(value, " ")))
+  case float64:
+    return strconv.FormatFloat(value, 'f', -1, 64)
+  case map[string]interface{}:
+    for _, key := range []string{"text", "name", "@value", "url", "@id"} {
+      if text := jsonLDString(value[key]); text != "" {
+        return text
+      }
+    }
+  case []interface{}:
+    if len(value) > 0 {
+      return jsonLDString(value[0])
+    }
+  }
+  return ""
+}
+
+// jsonLDList is every value of a property that may or may not be a list.
+func jsonLDList(value interface{}) []interface{} {
+  switch value := value.(type) {
+  case nil:
+    return nil
+  case []interface{}:
+    return value
+  }
+  return []interface{}{value}
+}
+
+func (o jsonLDObject) text(name string) string {
+  return jsonLDString(o[name])
+}
+
+// list is the texts of a property, split at commas as keywords are.
+func (o jsonLDObject) list(name string) []string {
+  values := make([]string, 0)
+  for _, value := range jsonLDList(o[name]) {
+    for _, part := range strings.Split(jsonLDString(value), ",") {
+      if part = strings.TrimSpace(part); part != "" {
+        values = append(values, part)
+      }
+    }
+  }
+  return values
+}
+
+func (o jsonLDObject) object(name string) jsonLDObject {
+  for _, value := range jsonLDList(o[name]) {
+    if object, ok := value.(map[string]interface{}); ok {
+      return object
+    }
+  }
+  return jsonLDObject{}
+}
+
+func (o jsonLDObject) is(schemaType string) bool {
+  for _, value := range jsonLDList(o["@type"]) {
+    if name, ok := value.(string); ok && (name == schemaType || strings.HasSuffix(name, "/"+schemaType)) {
+      return true
+    }
+  }
+  return false
+}
+
+// jsonLDLines splits text into lines, for instructions given as one string.
+func jsonLDLines(text string) []string {
+  lines := make([]string, 0)
+  for _, line := range strings.Split(text, "\n") {
+    if line = strings.Join(strings.Fields(line), " "); line != "" {
+      lines = append(lines, line)
+    }
+  }
+  return lines
+}
+
+// instructions reads recipeInstructions as text, a list of texts, HowToSteps
+// or HowToSections of them. Sections become "Name:" headings.
+func (o jsonLDObject) instructions() []string {
+  lines := make([]string, 0)
+  var add func(interface{})
+  add = func(value interface{}) {
+    switch value := value.(type) {
+    case string:
+      value = jsonLDBreakRe.ReplaceAllString(value, "\n")
+      lines = append(lines, jsonLDLines(jsonLDString(value))...)
+    case []interface{}:
+      for _, step := range value {
+        add(step)
+      }
+    case map[string]interface{}:
+      object := jsonLDObject(value)
+      if object.is("HowToSection") {
+        if name := object.text("name"); name != "" {
+          lines = append(lines, strings.TrimSuffix(name, ":")+":")
+        }
+        add(object["itemListElement"])
+        return
+      }
+      if text, exists := object["text"]; exists {
+        add(text)
+        return
+      }
+      add(object.text("name"))
+    }
+  }
+  add(o["recipeInstructions"])
+  return lines
+}
+
+// images are the photos, given as URLs or ImageObjects.
+func (o jsonLDObject) images() []string {
+  photos := make([]string, 0)
+  for _, value := range jsonLDList(o["image"]) {
+    src := jsonLDString(value)
+    if image, ok := value.(map[string]interface{}); ok {
+      if src = jsonLDString(image["contentUrl"]); src == "" {
+        src = jsonLDString(image["url"])
+      }
+    }
+    if src != "" && (!isDataURI(src) || strings.HasPrefix(src, "data:image/")) {
+      photos = append(photos, src)
+    }
+  }
+  return photos
+}
+
+// ExtractJSONLDRecipe reads a schema.org/Recipe object. Without an
+// identifier the recipe's id is made up, as with microdata.
+func ExtractJSONLDRecipe(o jsonLDObject) Recipe {
+  recipe := Recipe{}
+  recipe.Title = strings.Join(strings.Fields(o.text("name")), " ")
+  recipe.Description = o.text("description")
+  recipe.PhotoPaths = o.images()
+
+  recipe.IngredientLines = make([]string, 0)
+  for _, name := range []string{"recipeIngredient", "ingredients"} {
+    for _, value := range jsonLDList(o[name]) {
+      recipe.IngredientLines = append(recipe.IngredientLines, jsonLDLines(jsonLDString(value))...)
+    }
+  }
+  recipe.Ingredients = ParseIngredients(recipe.IngredientLines)
+  recipe.InstructionLines = o.instructions()
+  recipe.NotesLines = make([]string, 0)
+
+  nutrition := o.object("nutrition")
+  for label, name := range schemaNutrition {
+    if value := nutrition.text(name); value != "" {
+      recipe.Nutrition.Set(label, value)
+    }
+  }
+
+  metadata := &recipe.Metadata
+  metadata.UUID = o.text("identifier")
+  rating := o.object("aggregateRating")
+  if value, err := strconv.ParseFloat(rating.text("ratingValue"), 64); err == nil {
+    if best, err := strconv.ParseFloat(rating.text("bestRating"), 64); err == nil && best > 0 {
+      value = value * 5 / best
+    }
+    metadata.Rating = int(math.Round(value))
+  }
+  if metadata.Source = o.text("url"); metadata.Source == "" {
+    metadata.Source = o.text("isBasedOn")
+  }
+  metadata.CourseList = o.list("recipeCategory")
+  metadata.CategoryList = append(o.list("recipeCuisine"), o.list("keywords")...)
+  metadata.CollectionList = make([]string, 0)
+  metadata.Yield = o.text("recipeYield")
+  metadata.readSchemaTimes(o.text)
+  return recipe
+}
+
+// jsonLDRecipes finds the Recipe objects in a decoded script, at the top,
+// in a list or in a @graph.
+func jsonLDRecipes(value interface{}) []jsonLDObject {
+  found := make([]jsonLDObject, 0)
+  switch value := value.(type) {
+  case []interface{}:
+    for _, item := range value {
+      found = append(found, jsonLDRecipes(item)...)
+    }
+  case map[string]interface{}:
+    object := jsonLDObject(value)
+    if object.is("Recipe") {
+      return append(found, object)
+    }
+    found = append(found, jsonLDRecipes(object["@graph"])...)
+  }
+  return found
+}
+
+// JSONLDSource reads the recipes of a page with JSON-LD scripts.
+type JSONLDSource struct{}
+
+func (JSONLDSource) Detect(r io.Reader) bool {
+  head := bytes.ToLower(sniff(r))
+  return bytes.Contains(head, []byte("application/ld+json")) && bytes.Contains(head, []byte("recipe"))
+}
+
+func (JSONLDSource) Parse(r io.Reader) ([]Recipe, error) {
+  doc, err := goquery.NewDocumentFromReader(r)
+  if err != nil {
+    return nil, err
+  }
+  return ScrapeJSONLD(doc)
+}
+
+// ScrapeJSONLD finds the schema.org/Recipe objects of a page's JSON-LD
+// scripts. Scripts that aren't valid JSON are reported.
+func ScrapeJSONLD(doc *goquery.Document) ([]Recipe, error) {
+  recipes := make([]Recipe, 0)
+  taken := map[string]string{}
+  var err error
+  doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+    var decoded interface{}
+    if jsonErr := json.Unmarshal([]byte(s.Text()), &decoded); jsonErr != nil {
+      if err == nil {
+        err = fmt.Errorf("JSON-LD script %d: %w", i+1, jsonErr)
+      }
+      return
+    }
+    for _, object := range jsonLDRecipes(decoded) {
+      recipe := ExtractJSONLDRecipe(object)
+      if recipe.Metadata.UUID == "" {
+        recipe.Metadata.UUID = fallbackUUID(recipe, taken)
+      }
+      taken[recipe.Metadata.UUID] = recipe.Title
+      recipes = append(recipes, recipe)
+    }
+  })
+  return recipes, err
+}
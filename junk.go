@@ -0,0 +1,33 @@
+package main
+
+import (
+  "strings"
+)
+
+// Placeholder titles the apps hand out to recipes that were never filled in.
+var junkTitles = map[string]bool{
+  "untitled": true,
+  "untitled recipe": true,
+  "new recipe": true,
+  "recipe": true,
+  "test": true,
+}
+
+// JunkReason explains why a recipe looks like an abandoned or empty entry, or
+// returns "" if it seems to be a real recipe.
+func JunkReason(r Recipe) string {
+  title := strings.ToLower(strings.TrimSpace(r.Title))
+
+  switch {
+  case len(r.IngredientLines) == 0 && len(r.InstructionLines) == 0 && len(r.NotesLines) == 0:
+    return "empty body"
+  case len(r.IngredientLines) == 0 && len(r.InstructionLines) == 0:
+    return "no ingredients or instructions"
+  case title == "":
+    return "missing title"
+  case junkTitles[title]:
+    return "placeholder title \"" + strings.TrimSpace(r.Title) + "\""
+  }
+
+  return ""
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "strings"
+)
+
+// Labels translate the fixed words of the output (section headings, metadata
+// names, nutrition facts), keyed by the English text. Missing ones stay
+// English.
+type Labels map[string]string
+
+var builtinLabels = map[string]Labels{
+  "de": {
+    "Rating": "Bewertung",
+    "Collections": "Sammlungen",
+    "Course": "Gang",
+    "Source": "Quelle",
+    "Cook Time": "Kochzeit",
+    "Prep Time": "Vorbereitungszeit",
+    "Rest Time": "Ruhezeit",
+    "Total Time": "Gesamtzeit",
+    "Instructions": "Zubereitung",
+    "Notes": "Notizen",
+    "Photos": "Fotos",
+    "Nutrition": "Nährwerte",
+    "Changelog": "Änderungen",
+    "Make ahead": "Zum Vorbereiten",
+    "Ingredients": "Zutaten",
+    "Yield": "Ergibt",
+    "Recipes": "Rezepte",
+    "Other recipes": "Weitere Rezepte",
+    "Credits": "Quellen",
+    "photo": "Foto",
+    "Serving size": "Portionsgröße",
+    "Calories": "Kalorien",
+    "Total fat": "Fett",
+    "Saturated fat": "Gesättigte Fettsäuren",
+    "Sodium": "Natrium",
+    "Total carbohydrate": "Kohlenhydrate",
+    "Dietary fiber": "Ballaststoffe",
+    "Sugars": "Zucker",
+    "Protein": "Eiweiß",
+  },
+  "fr": {
+    "Rating": "Note",
+    "Collections": "Collections",
+    "Course": "Plat",
+    "Source": "Source",
+    "Cook Time": "Temps de cuisson",
+    "Prep Time": "Temps de préparation",
+    "Rest Time": "Temps de repos",
+    "Total Time": "Temps total",
+    "Instructions": "Préparation",
+    "Notes": "Notes",
+    "Photos": "Photos",
+    "Nutrition": "Valeurs nutritionnelles",
+    "Changelog": "Modifications",
+    "Make ahead": "À préparer à l'avance",
+    "Ingredients": "Ingrédients",
+    "Yield": "Portions",
+    "Recipes": "Recettes",
+    "Other recipes": "Autres recettes",
+    "Credits": "Crédits",
+    "photo": "photo",
+    "Serving size": "Portion",
+    "Calories": "Calories",
+    "Total fat": "Matières grasses",
+    "Saturated fat": "Acides gras saturés",
+    "Sodium": "Sodium",
+    "Total carbohydrate": "Glucides",
+    "Dietary fiber": "Fibres alimentaires",
+    "Sugars": "Sucres",
+    "Protein": "Protéines",
+  },
+  "es": {
+    "Rating": "Valoración",
+    "Collections": "Colecciones",
+    "Course": "Plato",
+    "Source": "Fuente",
+    "Cook Time": "Tiempo de cocción",
+    "Prep Time": "Tiempo de preparación",
+    "Rest Time": "Tiempo de reposo",
+    "Total Time": "Tiempo total",
+    "Instructions": "Preparación",
+    "Notes": "Notas",
+    "Photos": "Fotos",
+    "Nutrition": "Información nutricional",
+    "Changelog": "Cambios",
+    "Make ahead": "Con antelación",
+    "Ingredients": "Ingredientes",
+    "Yield": "Raciones",
+    "Recipes": "Recetas",
+    "Other recipes": "Otras recetas",
+    "Credits": "Créditos",
+    "photo": "foto",
+    "Serving size": "Tamaño de la porción",
+    "Calories": "Calorías",
+    "Total fat": "Grasas",
+    "Saturated fat": "Grasas saturadas",
+    "Sodium": "Sodio",
+    "Total carbohydrate": "Carbohidratos",
+    "Dietary fiber": "Fibra alimentaria",
+    "Sugars": "Azúcares",
+    "Protein": "Proteínas",
+  },
+}
+
+// labelKeys maps every built in translation back to its English label, so
+// files written in any language can be read back.
+var labelKeys = func() map[string]string {
+  keys := map[string]string{}
+  for _, labels := range builtinLabels {
+    for key, translation := range labels {
+      keys[strings.ToLower(translation)] = key
+    }
+  }
+  return keys
+}()
+
+// canonical is the English label for a possibly translated one, by these
+// labels or any of the built in ones.
+func (l Labels) canonical(label string) string {
+  for key, translation := range l {
+    if strings.EqualFold(translation, label) {
+      return key
+    }
+  }
+  if key, exists := labelKeys[strings.ToLower(label)]; exists {
+    return key
+  }
+  return label
+}
+
+// LoadLabels picks the built in labels for lang ("" or "en" for English) and
+// overrides them with a JSON object of {"English": "translation"} from path,
+// if given.
+func LoadLabels(lang string, path string) (Labels, error) {
+  labels := Labels{}
+  if lang != "" && lang != "en" {
+    builtin, exists := builtinLabels[lang]
+    if !exists {
+      return nil, fmt.Errorf("no labels for language %q, supply them with a labels file", lang)
+    }
+    for key, translation := range builtin {
+      labels[key] = translation
+    }
+  }
+
+  if path != "" {
+    content, err := os.ReadFile(path)
+    if err != nil {
+      return nil, err
+    }
+    custom := Labels{}
+    if err := json.Unmarshal(content, &custom); err != nil {
+      return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    for key, translation := range custom {
+      labels[key] = translation
+    }
+  }
+  return labels, nil
+}
+
+func (l Labels) get(label string) string {
+  if translation, exists := l[label]; exists {
+    return translation
+  }
+  return label
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestCustomLabelsStayWithTheirRun(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "labels.json")
+  if err := os.WriteFile(path, []byte(`{"Notes": "Remarks"}`), 0644); err != nil {
+    t.Fatal(err)
+  }
+  labels, err := LoadLabels("", path)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  content := "# Soup\n\n---\n\n- 1 onion\n\n---\n\nCook.\n\n### Remarks\n\nFreezes well.\n"
+  recipe, err := ParseRecipeMDLabels(strings.NewReader(content), labels)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(recipe.NotesLines) != 1 || len(recipe.InstructionLines) != 1 {
+    t.Errorf("with the labels, read steps %q and notes %q", recipe.InstructionLines, recipe.NotesLines)
+  }
+
+  // another run without them doesn't know the heading
+  if canonical := (Labels{}).canonical("Remarks"); canonical != "Remarks" {
+    t.Errorf("custom label leaked into other runs as %q", canonical)
+  }
+  if canonical := (Labels{}).canonical("Notizen"); canonical != "Notes" {
+    t.Errorf("built in label Notizen read as %q", canonical)
+  }
+}
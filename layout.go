@@ -0,0 +1,150 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// How converted files are arranged below the output directory.
+const (
+  LayoutFlat = "flat"
+  LayoutCollection = "collection"
+  // content folders for static site generators, see site.go
+  LayoutHugo = "hugo"
+  LayoutJekyll = "jekyll"
+)
+
+// How a recipe shows up in the folders of its other collections when using
+// the collection layout.
+const (
+  AliasNone = "none"
+  AliasSymlink = "symlink"
+  AliasStub = "stub"
+)
+
+const uncategorizedFolder = "Uncategorized"
+
+func folderName(name string) string {
+  name = strings.Map(func(r rune) rune {
+    if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+      return '-'
+    }
+    return r
+  }, strings.TrimSpace(name))
+  name = strings.Trim(name, ". ")
+  if name == "" {
+    return uncategorizedFolder
+  }
+  return name
+}
+
+// recipeDir is the folder, relative to the output directory, a recipe's file
+// is written to.
+func (c *Converter) recipeDir(r Recipe) string {
+  if isSiteLayout(c.Layout) {
+    return siteRecipeDir(c.Layout, r, c.siteCharset())
+  }
+  if c.Layout != LayoutCollection {
+    return ""
+  }
+  if len(r.Metadata.CollectionList) == 0 {
+    return uncategorizedFolder
+  }
+  return folderName(c.Charsets.FileNames.Apply(r.Metadata.CollectionList[0]))
+}
+
+// Site paths are both file names and URLs, so they follow the stricter of
+// the two policies.
+func (c *Converter) siteCharset() Charset {
+  if c.Charsets.FileNames == CharsetASCII || c.Charsets.URLs == CharsetASCII {
+    return CharsetASCII
+  }
+  return CharsetUnicode
+}
+
+func (c *Converter) fileName(r Recipe) string {
+  if slug, exists := c.slugs[r.Metadata.UUID]; exists {
+    return slug + "." + c.Renderer.Ext()
+  }
+  return r.Metadata.UUID + "." + c.Renderer.Ext()
+}
+
+func (c *Converter) outputPath(r Recipe) string {
+  return filepath.Join(c.OutputDir, c.recipeDir(r), c.fileName(r))
+}
+
+// relativeTo rewrites a path relative to the output directory so that it is
+// relative to the folder of the recipe's file instead.
+func (c *Converter) relativeTo(r Recipe, target string) string {
+  relative, err := filepath.Rel(c.recipeDir(r), target)
+  if err != nil {
+    return target
+  }
+  return filepath.ToSlash(relative)
+}
+
+// resolvePaths points the links between recipes at the files they will be
+// written to.
+func (c *Converter) resolvePaths(recipes []Recipe) {
+  if isSiteLayout(c.Layout) {
+    c.slugs = siteSlugs(recipes, c.siteCharset())
+  }
+
+  paths := map[string]string{}
+  for _, recipe := range recipes {
+    paths[recipe.Metadata.UUID] = filepath.Join(c.recipeDir(recipe), c.fileName(recipe))
+  }
+
+  for i := range recipes {
+    for j, link := range recipes[i].Links {
+      if target, exists := paths[link.Target]; exists {
+        recipes[i].Links[j].Path = c.relativeTo(recipes[i], target)
+      }
+    }
+  }
+}
+
+// writeAliases gives the recipe an entry in the folder of each of its other
+// collections, pointing back at the real file.
+func (c *Converter) writeAliases(r Recipe) error {
+  if c.Layout != LayoutCollection || c.Aliases == AliasNone || len(r.Metadata.CollectionList) < 2 {
+    return nil
+  }
+
+  primary := filepath.Join(c.recipeDir(r), c.fileName(r))
+  for _, collection := range r.Metadata.CollectionList[1:] {
+    dir := folderName(c.Charsets.FileNames.Apply(collection))
+    if dir == c.recipeDir(r) {
+      continue
+    }
+    if err := os.MkdirAll(filepath.Join(c.OutputDir, dir), 0755); err != nil {
+      return err
+    }
+
+    alias := filepath.Join(c.OutputDir, dir, c.fileName(r))
+    target, err := filepath.Rel(dir, primary)
+    if err != nil {
+      return err
+    }
+
+    if err := os.Remove(alias); err != nil && !os.IsNotExist(err) {
+      return err
+    }
+    switch c.Aliases {
+    case AliasSymlink:
+      err = os.Symlink(target, alias)
+    case AliasStub:
+      stub := fmt.Sprintf("# %s\n\nSee [%s](%s).\n", r.Title, r.Title, filepath.ToSlash(target))
+      err = os.WriteFile(alias, []byte(stub), 0644)
+    default:
+      err = fmt.Errorf("unknown alias style %q", c.Aliases)
+    }
+    if err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+  "path"
+  "regexp"
+  "sort"
+  "strings"
+
+  "github.com/PuerkitoBio/goquery"
+)
+
+// A reference from one recipe to another, e.g. "use the dough from Pizza
+// Dough". Target is the UUID of the linked recipe once it has been resolved,
+// and Path the relative location of its file when that isn't next to ours.
+type RecipeLink struct {
+  Text string
+  Href string
+  Target string
+  Path string
+}
+
+func (s RecipeNode) ExtractRecipeLinks() []RecipeLink {
+  links := make([]RecipeLink, 0)
+
+  s.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+    text := strings.TrimSpace(a.Text())
+    href := a.AttrOr("href", "")
+    if text != "" && href != "" {
+      links = append(links, RecipeLink{Text: text, Href: href})
+    }
+  })
+
+  return links
+}
+
+func wordBoundaryRe(phrase string) *regexp.Regexp {
+  return regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(phrase) + `\b`)
+}
+
+// phrasesRe matches any of the phrases as whole words, the longest first so
+// "Pizza Dough" isn't read as "Pizza" when both are there. It is nil for no
+// phrases.
+func phrasesRe(phrases []string) *regexp.Regexp {
+  sorted := make([]string, 0, len(phrases))
+  for _, phrase := range phrases {
+    if phrase != "" {
+      sorted = append(sorted, regexp.QuoteMeta(phrase))
+    }
+  }
+  if len(sorted) == 0 {
+    return nil
+  }
+  sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+  return regexp.MustCompile(`(?i)\b(?:` + strings.Join(sorted, "|") + `)\b`)
+}
+
+// mentions are the phrases re finds in the recipe, lower cased.
+func (r Recipe) mentions(re *regexp.Regexp) map[string]bool {
+  found := map[string]bool{}
+  for _, lines := range [][]string{r.IngredientLines, r.InstructionLines, r.NotesLines} {
+    for _, line := range lines {
+      for _, match := range re.FindAllString(line, -1) {
+        found[strings.ToLower(match)] = true
+      }
+    }
+  }
+  return found
+}
+
+// LinkRecipes resolves the exported links to the recipes they point at, and
+// adds links for mentions of other recipes by their full title. Single word
+// titles ("Pancakes", "Naan") are too likely to be mere ingredients, so they are
+// only linked when the export had an explicit link.
+func LinkRecipes(recipes []Recipe) {
+  byTitle := map[string]string{}
+  byUUID := map[string]bool{}
+  // one regexp for all the titles worth looking for, not one per recipe
+  titles := make([]string, 0, len(recipes))
+  for _, recipe := range recipes {
+    title := strings.TrimSpace(recipe.Title)
+    byTitle[strings.ToLower(title)] = recipe.Metadata.UUID
+    byUUID[recipe.Metadata.UUID] = true
+    if len(strings.Fields(title)) >= 2 {
+      titles = append(titles, title)
+    }
+  }
+  mentionRe := phrasesRe(titles)
+
+  for i := range recipes {
+    recipe := &recipes[i]
+    linked := map[string]bool{recipe.Metadata.UUID: true}
+
+    resolved := make([]RecipeLink, 0, len(recipe.Links))
+    for _, link := range recipe.Links {
+      link.Target = ""
+      for uuid := range byUUID {
+        if uuid != "" && strings.Contains(link.Href, uuid) {
+          link.Target = uuid
+          break
+        }
+      }
+      if link.Target == "" {
+        link.Target = byTitle[strings.ToLower(link.Text)]
+      }
+      if link.Target != "" && !linked[link.Target] {
+        linked[link.Target] = true
+        resolved = append(resolved, link)
+      }
+    }
+
+    if mentionRe == nil {
+      recipe.Links = resolved
+      continue
+    }
+    mentioned := recipe.mentions(mentionRe)
+    for _, other := range recipes {
+      title := strings.TrimSpace(other.Title)
+      if linked[other.Metadata.UUID] || len(strings.Fields(title)) < 2 {
+        continue
+      }
+      if mentioned[strings.ToLower(title)] {
+        linked[other.Metadata.UUID] = true
+        resolved = append(resolved, RecipeLink{Text: title, Target: other.Metadata.UUID})
+      }
+    }
+
+    recipe.Links = resolved
+  }
+}
+
+// recipeLinker turns the first mention of each linked recipe into a Markdown
+// link to its converted file.
+type recipeLinker struct {
+  pending []RecipeLink
+  // re finds the texts of the pending links
+  re *regexp.Regexp
+  ext string
+  // wiki writes [[file|text]] links, as Obsidian and other wikis use
+  wiki bool
+}
+
+func newRecipeLinker(links []RecipeLink, ext string) *recipeLinker {
+  pending := make([]RecipeLink, 0, len(links))
+  texts := make([]string, 0, len(links))
+  for _, link := range links {
+    if link.Target != "" {
+      pending = append(pending, link)
+      texts = append(texts, link.Text)
+    }
+  }
+  return &recipeLinker{pending: pending, re: phrasesRe(texts), ext: ext}
+}
+
+// linkSpanRe matches the Markdown and wiki links already in a line.
+var linkSpanRe = regexp.MustCompile(`\[\[[^\]]*\]\]|\[[^\]]*\]\([^)]*\)`)
+
+func (l *recipeLinker) Linkify(line string) string {
+  if l.re == nil || len(l.pending) == 0 {
+    return line
+  }
+  spans := linkSpanRe.FindAllStringIndex(line, -1)
+  var output strings.Builder
+  last := 0
+  for _, location := range l.re.FindAllStringIndex(line, -1) {
+    if insideSpan(location, spans) {
+      continue
+    }
+    text := line[location[0]:location[1]]
+    i := l.pendingIndex(text)
+    if i < 0 {
+      continue
+    }
+    link := l.pending[i]
+    l.pending = append(l.pending[:i], l.pending[i+1:]...)
+
+    href := link.Path
+    if href == "" {
+      href = link.Target + "." + l.ext
+    }
+    replacement := "[" + text + "](" + href + ")"
+    if l.wiki {
+      replacement = "[[" + strings.TrimSuffix(path.Base(href), "."+l.ext) + "|" + text + "]]"
+    }
+    output.WriteString(line[last:location[0]] + replacement)
+    last = location[1]
+  }
+  output.WriteString(line[last:])
+  return output.String()
+}
+
+// pendingIndex finds the pending link with the text, -1 if it was linked
+// already.
+func (l *recipeLinker) pendingIndex(text string) int {
+  for i, link := range l.pending {
+    if strings.EqualFold(link.Text, text) {
+      return i
+    }
+  }
+  return -1
+}
+
+func insideSpan(location []int, spans [][]int) bool {
+  for _, span := range spans {
+    if location[0] < span[1] && location[1] > span[0] {
+      return true
+    }
+  }
+  return false
+}
+
+func (l *recipeLinker) LinkifyAll(lines []string) []string {
+  output := make([]string, len(lines))
+  for i, line := range lines {
+    output[i] = l.Linkify(line)
+  }
+  return output
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestLinkRecipesLongestTitle(t *testing.T) {
+  recipes := []Recipe{
+    {Title: "Pizza Dough", Metadata: RecipeMetadata{UUID: "dough"}},
+    {Title: "Quick Pizza Dough", Metadata: RecipeMetadata{UUID: "quick"}},
+    {Title: "Margherita", Metadata: RecipeMetadata{UUID: "margherita"}, InstructionLines: []string{"Stretch the quick pizza dough."}},
+  }
+  LinkRecipes(recipes)
+  want := []RecipeLink{{Text: "Quick Pizza Dough", Target: "quick"}}
+  if !reflect.DeepEqual(recipes[2].Links, want) {
+    t.Errorf("linked %v, want %v", recipes[2].Links, want)
+  }
+}
+
+func TestLinkifySkipsLinks(t *testing.T) {
+  for _, test := range []struct {
+    line string
+    want string
+  }{
+    {"Apple Pie, or any pie", "[Apple Pie](apple.md), or any [pie](pie.md)"},
+    {"See [Apple Pie](https://example.com) or use Apple Pie.", "See [Apple Pie](https://example.com) or use [Apple Pie](apple.md)."},
+    {"[[apple|Apple Pie]] and pie", "[[apple|Apple Pie]] and [pie](pie.md)"},
+  } {
+    linker := newRecipeLinker([]RecipeLink{{Text: "Pie", Target: "pie"}, {Text: "Apple Pie", Target: "apple"}}, "md")
+    if got := linker.Linkify(test.line); got != test.want {
+      t.Errorf("%q: got %q, want %q", test.line, got, test.want)
+    }
+  }
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "strings"
+  "time"
+  "unicode"
+)
+
+// A PlausibilityRule caps how much of something a recipe can sensibly call
+// for. Rules with keywords only apply to ingredients whose name has one of
+// them as whole words, and none of the excluded ones, rules with a unit only
+// to amounts given in that unit. Limits are in the rule's unit (Max) or
+// converted to millilitres / grams (MaxML / MaxG).
+type PlausibilityRule struct {
+  Name string `json:"name"`
+  Keywords []string `json:"keywords,omitempty"`
+  Exclude []string `json:"exclude,omitempty"`
+  Unit string `json:"unit,omitempty"`
+  Max float64 `json:"max,omitempty"`
+  MaxML float64 `json:"max_ml,omitempty"`
+  MaxG float64 `json:"max_g,omitempty"`
+}
+
+type Plausibility struct {
+  Rules []PlausibilityRule `json:"rules"`
+  MinTime Duration `json:"min_time"`
+  MaxTime Duration `json:"max_time"`
+}
+
+// Duration reads and writes as a Go duration string ("90s", "1h30m") in JSON.
+type Duration struct {
+  time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+  return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+  var text string
+  if err := json.Unmarshal(data, &text); err != nil {
+    return err
+  }
+  duration, err := time.ParseDuration(text)
+  if err != nil {
+    return err
+  }
+  d.Duration = duration
+  return nil
+}
+
+func DefaultPlausibility() Plausibility {
+  return Plausibility{
+    Rules: []PlausibilityRule{
+      {Name: "salt and leaveners", Keywords: []string{"salt", "baking soda", "baking powder", "yeast"}, MaxML: 45, MaxG: 60},
+      {Name: "spices", Keywords: []string{"pepper", "cinnamon", "cumin", "paprika", "nutmeg", "chili powder", "cayenne", "turmeric", "vanilla", "extract"}, Exclude: []string{"bell pepper", "sweet pepper", "chili pepper", "jalapeno pepper", "jalapeño pepper", "pepper jack"}, MaxML: 60, MaxG: 60},
+      {Name: "teaspoons", Unit: "tsp", Max: 30},
+      {Name: "tablespoons", Unit: "tbsp", Max: 30},
+      {Name: "cups", Unit: "cup", Max: 16},
+      {Name: "millilitres", Unit: "ml", Max: 5000},
+      {Name: "litres", Unit: "l", Max: 10},
+      {Name: "grams", Unit: "g", Max: 5000},
+      {Name: "kilograms", Unit: "kg", Max: 10},
+      {Name: "ounces", Unit: "oz", Max: 64},
+      {Name: "pounds", Unit: "lb", Max: 20},
+    },
+    MinTime: Duration{time.Minute},
+    MaxTime: Duration{72 * time.Hour},
+  }
+}
+
+func LoadPlausibility(path string) (Plausibility, error) {
+  plausibility := DefaultPlausibility()
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return plausibility, err
+  }
+  if err := json.Unmarshal(content, &plausibility); err != nil {
+    return plausibility, fmt.Errorf("%s: %w", path, err)
+  }
+  return plausibility, nil
+}
+
+func (rule PlausibilityRule) Check(ingredient Ingredient) bool {
+  if ingredient.Unit == "" || (rule.Unit != "" && rule.Unit != ingredient.Unit) {
+    return true
+  }
+
+  if len(rule.Keywords) > 0 {
+    name := nameWords(ingredient.Name)
+    matched := false
+    for _, keyword := range rule.Keywords {
+      if strings.Contains(name, nameWords(keyword)) {
+        matched = true
+        break
+      }
+    }
+    for _, excluded := range rule.Exclude {
+      if strings.Contains(name, nameWords(excluded)) {
+        matched = false
+      }
+    }
+    if !matched {
+      return true
+    }
+  }
+
+  if rule.Max > 0 && ingredient.Amount > rule.Max {
+    return false
+  }
+
+  unit := LookupUnit(ingredient.Unit)
+  if unit == nil {
+    return true
+  }
+  switch unit.Kind {
+  case UnitVolume:
+    return rule.MaxML <= 0 || ingredient.Amount*unit.Factor <= rule.MaxML
+  case UnitWeight:
+    return rule.MaxG <= 0 || ingredient.Amount*unit.Factor <= rule.MaxG
+  }
+  return true
+}
+
+// nameWords is a name in lower case with a space around every word, so
+// " salt " is found in " kosher salt " but not in " unsalted butter ".
+func nameWords(name string) string {
+  words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+  })
+  return " " + strings.Join(words, " ") + " "
+}
+
+// LintRecipe flags probable data entry mistakes such as "2 cups salt".
+func LintRecipe(r Recipe, p Plausibility) []string {
+  findings := make([]string, 0)
+
+  for _, ingredient := range r.Ingredients {
+    for _, rule := range p.Rules {
+      if !rule.Check(ingredient) {
+        findings = append(findings, fmt.Sprintf("implausible amount for %s: %q", rule.Name, ingredient.Raw))
+        break
+      }
+    }
+  }
+
+  times := []struct {
+    name string
+    duration time.Duration
+  }{
+    {"prep", r.Metadata.PrepTime},
+    {"cook", r.Metadata.CookTime},
+    {"rest", r.Metadata.RestTime},
+  }
+  for _, t := range times {
+    if t.duration > 0 && t.duration < p.MinTime.Duration {
+      findings = append(findings, fmt.Sprintf("%s time of %s is suspiciously short", t.name, t.duration))
+    }
+    if p.MaxTime.Duration > 0 && t.duration > p.MaxTime.Duration {
+      findings = append(findings, fmt.Sprintf("%s time of %s is suspiciously long", t.name, t.duration))
+    }
+  }
+
+  return findings
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestPlausibilityKeywords(t *testing.T) {
+  for _, test := range []struct {
+    line string
+    flagged bool
+  }{
+    {"1 cup salt", true},
+    {"1 cup kosher salt", true},
+    {"1/2 cup ground cinnamon", true},
+    {"1/2 cup black pepper", true},
+    {"1 cup unsalted butter", false},
+    {"1 cup salted butter", false},
+    {"1 cup red bell pepper, diced", false},
+    {"2 cups sweet peppers", false},
+    {"1 cup pepper jack cheese, grated", false},
+  } {
+    recipe := Recipe{Ingredients: ParseIngredients([]string{test.line})}
+    findings := LintRecipe(recipe, DefaultPlausibility())
+    if flagged := len(findings) > 0; flagged != test.flagged {
+      t.Errorf("%q: flagged %v (%q), want %v", test.line, flagged, findings, test.flagged)
+    }
+  }
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+  "bytes"
   "fmt"
   "log"
   "io"
   "os"
+  "path/filepath"
   "time"
   "strconv"
   "strings"
+  "unicode"
 
   "errors"
   "regexp"
@@ -16,21 +19,27 @@ import (
 )
 
 // TODOS:
-//  [] - Parse out ammount and unit of the ingredients and wrap in asterisks
+//  [x] - Parse out ammount and unit of the ingredients and wrap in asterisks
 //  [] - Consider including images
-//  [] - Consider writing out nutrition
-//  [] - Extract linked recipes (missing in export data)
+//  [x] - Consider writing out nutrition
+//  [x] - Extract linked recipes (missing in export data)
 //  [] - Decide if we should purge the non ascii characters or not. If so include bullets and degree symbols in the replacement list
-//  [] - If we continue replacing the fractions we should ensure that the are spaces before them to avoid improper fractions being rendered as  11/2 rather than 1 1/2
-//  [] - Parse instructions to see if they have a trailing colon and make it a sub ingredient list
+//  [x] - If we continue replacing the fractions we should ensure that the are spaces before them to avoid improper fractions being rendered as  11/2 rather than 1 1/2
+//  [x] - Parse instructions to see if they have a trailing colon and make it a sub ingredient list
 
 
 type RecipeNode struct {
   *goquery.Selection
+  // itemprops to fall back to for older exports, see exportShims
+  shims map[string]string
 }
 
 func (s RecipeNode) ItemProp(elemName string, propName string) *goquery.Selection {
- return s.Find(elemName + "[itemprop=\"" + propName + "\"]")
+ selector := elemName + "[itemprop=\"" + propName + "\"]"
+ if alias, exists := s.shims[propName]; exists {
+   selector += ", " + elemName + "[itemprop=\"" + alias + "\"]"
+ }
+ return s.Find(selector)
 }
 
 func (s RecipeNode) ItemPropAttrOr(elemName string, propName string, attr string, defaultValue string) string {
@@ -78,13 +87,19 @@ var fractions = map[rune]string{
 
 func ConvertFractions(input string) string {
 	var output strings.Builder
+	var previous rune
 
 	for _, r := range input {
 		if replacement, exists := fractions[r]; exists {
+			// Keep mixed numbers apart so 1½ becomes 1 1/2 rather than 11/2
+			if unicode.IsDigit(previous) {
+				output.WriteRune(' ')
+			}
 			output.WriteString(replacement)
 		} else {
 			output.WriteRune(r)
 		}
+		previous = r
 	}
 
 	return output.String()
@@ -94,7 +109,7 @@ func (s RecipeNode) ItemPropChildrenText(propName string) []string {
   stringList := make([]string, 0)
 
   s.ItemProp("", propName).Children().Each(func (i int, par *goquery.Selection){
-    partext := ConvertFractions(strings.TrimSpace(par.Text()))
+    partext := strings.TrimSpace(par.Text())
     if partext != "" {
       stringList = append(stringList, partext)
     }
@@ -127,8 +142,16 @@ func (s RecipeNode) ExtractRecipeCourses() []string {
 func (s RecipeNode) ExtractRecipePhotos() []string {
   photos := make([]string, 0)
 
-  s.Find("img.recipe-photos").Each(func (i int, img *goquery.Selection){
+  selector := "img.recipe-photos"
+  if s.shims != nil {
+    selector += `, img[itemprop="image"]`
+  }
+  s.Find(selector).Each(func (i int, img *goquery.Selection){
     img_src := img.AttrOr("src", "")
+    // Photos can also be embedded inline as data URIs, skip anything that isn't an image
+    if isDataURI(img_src) && !strings.HasPrefix(img_src, "data:image/") {
+      return
+    }
     if img_src != "" {
       photos = append(photos, img_src)
     }
@@ -143,8 +166,16 @@ func (s RecipeNode) ExtractRecipeMetadata() RecipeMetadata {
   metadata.UUID = s.ItemPropContentOr("recipeId", "")
 	metadata.Favorited = s.ItemPropContentOr("recipeIsFavourite", "False") == "True"
 
-  rating, err := strconv.Atoi(s.ItemPropContentOr("recipeRating", "0"))
-  if err == nil { metadata.Rating = rating }
+  unparsed := func(propName string, value string) {
+    if value == "" {
+      return
+    }
+    metadata.Unparsed = append(metadata.Unparsed, fmt.Sprintf("%s %q", propName, value))
+  }
+
+  ratingText := s.ItemPropContentOr("recipeRating", "0")
+  rating, err := strconv.Atoi(ratingText)
+  if err == nil { metadata.Rating = rating } else { unparsed("recipeRating", ratingText) }
 
 	metadata.Source = s.ItemPropElemText("recipeSource")
 
@@ -154,11 +185,27 @@ func (s RecipeNode) ExtractRecipeMetadata() RecipeMetadata {
 
 	metadata.Yield = s.ItemPropElemText("recipeYield" )
 
-	prepDuration, err := ParseISODuration(strings.TrimSpace(s.ItemPropContentOr("prepTime", "PT50S")))
-	if err == nil { metadata.PrepTime = prepDuration }
+	prepText := strings.TrimSpace(s.ItemPropContentOr("prepTime", "PT50S"))
+	prepDuration, err := ParseISODuration(prepText)
+	if err == nil { metadata.PrepTime = prepDuration } else { unparsed("prepTime", prepText) }
 
-	cookDuration, err := ParseISODuration(s.ItemPropContentOr("cookTime", "PT0S"))
-	if err == nil { metadata.CookTime = cookDuration }
+	cookText := s.ItemPropContentOr("cookTime", "PT0S")
+	cookDuration, err := ParseISODuration(cookText)
+	if err == nil { metadata.CookTime = cookDuration } else { unparsed("cookTime", cookText) }
+
+	restText := strings.TrimSpace(s.ItemPropContentOr("restTime", "PT0S"))
+	restDuration, err := ParseISODuration(restText)
+	if err == nil { metadata.RestTime = restDuration } else { unparsed("restTime", restText) }
+
+	totalText := strings.TrimSpace(s.ItemPropContentOr("totalTime", "PT0S"))
+	totalDuration, err := ParseISODuration(totalText)
+	if err == nil { metadata.TotalTime = totalDuration } else { unparsed("totalTime", totalText) }
+	if metadata.TotalTime == 0 {
+	  metadata.TotalTime = metadata.PrepTime + metadata.CookTime
+	}
+
+  metadata.Created = s.ItemPropDate("recipeDateCreated", "dateCreated", "datePublished")
+  metadata.Modified = s.ItemPropDate("recipeDateModified", "dateModified")
 
   return metadata
 }
@@ -179,16 +226,47 @@ func (s RecipeNode) ExtractRecipeNutrition() RecipeNutrition {
   return nutrition
 }
 
+// Descriptions come either as an element, possibly holding several paragraphs,
+// or as a meta tag. Paragraphs are kept apart by a blank line.
+func (s RecipeNode) ExtractRecipeDescription() string {
+  if content := strings.TrimSpace(s.ItemPropContentOr("description", "")); content != "" {
+    return content
+  }
+
+  paragraphs := make([]string, 0)
+  s.ItemProp("", "description").Not("meta").Each(func (i int, elem *goquery.Selection){
+    if elem.Children().Length() == 0 {
+      paragraphs = append(paragraphs, strings.TrimSpace(elem.Text()))
+      return
+    }
+    elem.Children().Each(func (i int, par *goquery.Selection){
+      paragraphs = append(paragraphs, strings.TrimSpace(par.Text()))
+    })
+  })
+
+  nonEmpty := paragraphs[:0]
+  for _, paragraph := range paragraphs {
+    if paragraph != "" {
+      nonEmpty = append(nonEmpty, paragraph)
+    }
+  }
+  return strings.Join(nonEmpty, "\n\n")
+}
+
 func (s RecipeNode) ExtractRecipe() Recipe {
   recipe := Recipe{}
 
   recipe.Title = s.ItemPropElemText("name")
+  recipe.Description = s.ExtractRecipeDescription()
   recipe.Metadata = s.ExtractRecipeMetadata()
+  recipe.Nutrition = s.ExtractRecipeNutrition()
   recipe.PhotoPaths = s.ExtractRecipePhotos()
 
 	recipe.IngredientLines = s.ItemPropChildrenText("recipeIngredients")
+	recipe.Ingredients = ParseIngredients(recipe.IngredientLines)
 	recipe.InstructionLines = s.ItemPropChildrenText("recipeDirections")
 	recipe.NotesLines = s.ItemPropChildrenText("recipeNotes")
+	recipe.Links = s.ExtractRecipeLinks()
 
   return recipe
 }
@@ -216,44 +294,100 @@ type RecipeMetadata struct {
   Yield string
   CookTime time.Duration
   PrepTime time.Duration
+  RestTime time.Duration
+  TotalTime time.Duration
+  // Created and Modified are zero unless the export has them, see
+  // ItemPropDate.
+  Created time.Time
+  Modified time.Time
+  // Unparsed are the values that couldn't be read, e.g. `prepTime "PT1X"`,
+  // to be reported.
+  Unparsed []string
 }
 
 type Recipe struct {
   Title string
+  Description string
   Nutrition RecipeNutrition
   Metadata RecipeMetadata
   PhotoPaths []string
   IngredientLines []string
+  // Ingredients are the IngredientLines parsed, see ParseIngredients. The
+  // converter parses them again once it is done editing the lines.
+  Ingredients []Ingredient
   InstructionLines []string
   NotesLines []string
+  Links []RecipeLink
+  // MakeAhead are the sentences shown in a callout below the description,
+  // see AnnotateMakeAhead.
+  MakeAhead []string
+
+  // where the export the recipe came from was, to find its photos
+  exportDir string
+  // the page it was fetched from instead, see FetchRecipes
+  fetched string
 }
 
 func (r Recipe) FormatAsRecipeMD() string {
+	return r.formatAsRecipeMD(FormatOptions{})
+}
+
+func (r Recipe) formatAsRecipeMD(options FormatOptions) string {
+	if options.Strict {
+	  return r.formatStrictRecipeMD(options)
+	}
 	var output strings.Builder
+	if options.Frontmatter {
+	  output.WriteString(r.formatFrontmatter(options))
+	}
 	output.WriteString(fmt.Sprintf("# %s\n", r.Title))
+	if !options.Frontmatter {
+	  output.WriteString(r.sourceComment())
+	}
+
+	if options.HeroImage && len(r.PhotoPaths) > 0 {
+	  output.WriteString(fmt.Sprintf("\n![%s](%s)\n", r.Title, r.PhotoPaths[0]))
+	}
+
+	if r.Description != "" {
+	  output.WriteString(fmt.Sprintf("\n%s\n", r.Description))
+	}
+	if len(r.MakeAhead) > 0 {
+	  output.WriteString("\n" + r.formatMakeAhead(options) + "\n")
+	}
 
 	output.WriteString("\n")
-	if r.Metadata.Rating != 0 {
-	  output.WriteString(fmt.Sprintf("Rating: %d-star\n", r.Metadata.Rating))
+	if rating := options.rating(r.Metadata.Rating); rating != "" {
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Rating"), rating))
 	}
 	if len(r.Metadata.CollectionList) > 0 {
-	  output.WriteString(fmt.Sprintf("Collections: %s\n", strings.Join(r.Metadata.CollectionList, ", ")))
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Collections"), strings.Join(r.Metadata.CollectionList, ", ")))
 	}
 	if len(r.Metadata.CourseList) > 0 {
-	  output.WriteString(fmt.Sprintf("Course: %s\n", strings.Join(r.Metadata.CourseList, ", ")))
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Course"), strings.Join(r.Metadata.CourseList, ", ")))
 	}
 
 	output.WriteString("\n")
 	if r.Metadata.Source != "" {
-	  output.WriteString(fmt.Sprintf("Source: %s\n", r.Metadata.Source))
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Source"), FormatSource(r.Metadata.Source)))
 	}
 
 	output.WriteString("\n")
 	if r.Metadata.CookTime > time.Duration(0) {
-	  output.WriteString(fmt.Sprintf("Cook Time: %s\n", r.Metadata.CookTime))
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Cook Time"), r.Metadata.CookTime))
 	}
 	if r.Metadata.PrepTime > time.Duration(0) {
-	  output.WriteString(fmt.Sprintf("Prep Time: %s\n", r.Metadata.PrepTime))
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Prep Time"), r.Metadata.PrepTime))
+	}
+	if r.Metadata.RestTime > time.Duration(0) {
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Rest Time"), r.Metadata.RestTime))
+	}
+	if r.Metadata.TotalTime > time.Duration(0) {
+	  output.WriteString(fmt.Sprintf("%s: %s\n", options.Labels.get("Total Time"), r.Metadata.TotalTime))
+	}
+
+	if tags := r.formatHashTags(options.TagCharset); options.HashTags && tags != "" {
+	  output.WriteString("\n" + tags + "\n")
 	}
 
 	output.WriteString("\n")
@@ -268,62 +402,212 @@ func (r Recipe) FormatAsRecipeMD() string {
 
 	output.WriteString("\n---\n\n")
 
+	linker := newRecipeLinker(r.Links, "md")
+	linker.wiki = options.WikiLinks
 	for _, ingredient := range r.IngredientLines {
-	  output.WriteString(fmt.Sprintf("- %s\n", ingredient)) // TODO: parse so the ammount and unit go inside *
+	  output.WriteString(fmt.Sprintf("- %s\n", linker.Linkify(options.ingredient(ingredient))))
 	}
 
 	output.WriteString("\n---\n\n")
 
-	output.WriteString("### Instructions\n\n")
-	output.WriteString(strings.Join(r.InstructionLines, "\n"))
+	output.WriteString(options.heading("Instructions") + "\n\n")
+	if summary := SectionTimingSummary(r.InstructionLines); options.TimingSummary && summary != "" {
+	  output.WriteString(summary + "\n\n")
+	}
+	instructions := linker.LinkifyAll(r.InstructionLines)
+	highlighter := options.highlighter(r)
+	for i, line := range instructions {
+	  instructions[i] = options.fractions(highlighter.Highlight(line))
+	}
+	output.WriteString(options.formatSteps(instructions))
 
   if len(r.NotesLines) > 0 {
-	  output.WriteString("\n\n### Notes\n\n")
-	  output.WriteString(strings.Join(r.NotesLines, "\n"))
+	  output.WriteString("\n\n" + options.heading("Notes") + "\n\n")
+	  output.WriteString(strings.Join(linker.LinkifyAll(r.NotesLines), "\n"))
   }
 
 	output.WriteString("\n")
 
+	for _, block := range []string{r.formatPhotosBlock(options), r.formatNutritionBlock(options)} {
+	  if block != "" {
+	    output.WriteString("\n" + block)
+	  }
+	}
+
 	return output.String()
 }
 
-func (r Recipe) WriteRecipeMD() error {
-	content := r.FormatAsRecipeMD()
-	return os.WriteFile("./recipes/" + r.Metadata.UUID + ".md", []byte(content), 0644)
+const outputDir = "./recipes"
+
+// defaultFileMode is the mode of the files we write unless told otherwise.
+const defaultFileMode os.FileMode = 0644
+
+func (r Recipe) OutputPath(dir string, renderer Renderer) string {
+	return filepath.Join(dir, r.Metadata.UUID+"."+renderer.Ext())
+}
+
+// WriteRecipe renders the recipe into a file in dir named after its UUID,
+// creating dir if it doesn't exist yet.
+func (r Recipe) WriteRecipe(renderer Renderer, dir string, mode os.FileMode) error {
+	content, err := renderer.Render(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.Metadata.UUID, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFile(r.OutputPath(dir, renderer), content, mode)
+}
+
+func (r Recipe) WriteRecipeMD(dir string, mode os.FileMode) error {
+	return r.WriteRecipe(RecipeMDRenderer{}, dir, mode)
+}
+
+func ScrapeRecipeKeeperExportHtml(reader io.Reader) ([]Recipe, error) {
+  recipes, _, err := ScrapeExport(reader)
+  return recipes, err
+}
+
+// RecipeKeeperHTML is the recipes.html of Recipe Keeper's export, or one of
+// the files of an export with a file per recipe.
+type RecipeKeeperHTML struct{}
+
+func (RecipeKeeperHTML) Detect(r io.Reader) bool {
+  return bytes.Contains(sniff(r), []byte("recipe-details"))
+}
+
+func (h RecipeKeeperHTML) Parse(r io.Reader) ([]Recipe, error) {
+  recipes, _, err := h.ParseVersion(r)
+  return recipes, err
 }
 
-func ScrapeRecipeKeeperExportHtml(reader io.Reader) {
-  doc, err := goquery.NewDocumentFromReader(reader)
+func (h RecipeKeeperHTML) ParseVersion(r io.Reader) ([]Recipe, ExportVersion, error) {
+  recipes := make([]Recipe, 0)
+  version, err := h.ParseEach(r, func(recipe Recipe) error {
+    recipes = append(recipes, recipe)
+    return nil
+  })
   if err != nil {
-    log.Fatal(err)
+    return nil, version, err
   }
+  return recipes, version, nil
+}
 
-  doc.Find("div.recipe-details").Each(func(i int, s *goquery.Selection) {
-
-		r := RecipeNode{ s }
-		recipe := r.ExtractRecipe()
-		r.ExtractRecipe().WriteRecipeMD()
+// ParseEach parses the export a recipe at a time, so only the markup of the
+// recipe being read is ever in memory, not the whole document. The version
+// is told by the first recipe that has the itemprops to tell it by.
+func (RecipeKeeperHTML) ParseEach(r io.Reader, fn func(Recipe) error) (ExportVersion, error) {
+  version := ExportUnknown
+  found := false
+  var head []byte
+  err := splitRecipeDetails(r, func(chunk []byte, isRecipe bool) error {
+    if !isRecipe {
+      head = chunk
+      return nil
+    }
+    doc, err := goquery.NewDocumentFromReader(bytes.NewReader(chunk))
+    if err != nil {
+      return err
+    }
+    if version == ExportUnknown {
+      version = DetectExportVersion(doc)
+    }
+    found = true
+    var parseErr error
+    doc.Find("div.recipe-details").Each(func(i int, s *goquery.Selection) {
+      if parseErr == nil {
+        parseErr = fn(RecipeNode{ s, exportShims[version] }.ExtractRecipe())
+      }
+    })
+    return parseErr
+  })
+  if err != nil || found {
+    return version, err
+  }
+  // no recipes, the head is the whole file
+  doc, err := goquery.NewDocumentFromReader(bytes.NewReader(head))
+  if err != nil {
+    return version, err
+  }
+  return DetectExportVersion(doc), diagnoseEmptyExport(doc)
+}
 
-    if strings.Contains(recipe.Title, "Saag") {
-    // if recipe.Metadata.Favorited {
-		  // fmt.Printf("%+v\n", recipe)
-		  // fmt.Print(r.ExtractRecipe().FormatAsRecipeMD())
+// splitRecipeDetails cuts the export before each recipe-details tag and hands
+// over the pieces as they are read: first whatever comes before the first
+// recipe, then a piece per recipe.
+func splitRecipeDetails(r io.Reader, fn func(chunk []byte, isRecipe bool) error) error {
+  marker := []byte("recipe-details")
+  buffer := make([]byte, 0, 64<<10)
+  read := make([]byte, 64<<10)
+  isRecipe := false
+  searched := 0
+  for {
+    n, err := r.Read(read)
+    buffer = append(buffer, read[:n]...)
+    for {
+      i := bytes.Index(buffer[searched:], marker)
+      if i < 0 {
+        // the marker may be cut in two by the read
+        if len(buffer)-len(marker) > searched {
+          searched = len(buffer) - len(marker)
+        }
+        break
+      }
+      i += searched
+      end := i + len(marker)
+      if end >= len(buffer) && err == nil {
+        // read on to see where the class name ends
+        searched = i
+        break
+      }
+      searched = end
+      start := bytes.LastIndexByte(buffer[:i], '<')
+      if start < 0 || !isRecipeDetailsTag(buffer[start:i], buffer[end:]) {
+        continue
+      }
+      if start == 0 {
+        // the tag this chunk starts with
+        isRecipe = true
+        continue
+      }
+      if callErr := fn(buffer[:start], isRecipe); callErr != nil {
+        return callErr
+      }
+      isRecipe = true
+      buffer = append(make([]byte, 0, cap(buffer)), buffer[start:]...)
+      searched = end - start
+    }
+    if err == io.EOF {
+      return fn(buffer, isRecipe)
     }
-	})
+    if err != nil {
+      return err
+    }
+  }
+}
+
+// isRecipeDetailsTag tells if the recipe-details found between tag and rest
+// is a class of a div, and not part of a longer name or of some text.
+func isRecipeDetailsTag(tag []byte, rest []byte) bool {
+  if len(tag) < 4 || !strings.EqualFold(string(tag[:4]), "<div") || bytes.ContainsRune(tag, '>') {
+    return false
+  }
+  return len(rest) > 0 && strings.ContainsRune("\"' ", rune(rest[0]))
 }
 
 func main() {
-  path := "/home/kalebo/Downloads/RecipeKeeper_20230630_093852/recipes.html"
+  args := os.Args[1:]
+  command := runConvert
+  if len(args) > 0 {
+    if subcommand, exists := commands[args[0]]; exists {
+      command = subcommand
+      args = args[1:]
+    }
+  }
 
-  file, err := os.Open(path)
-  if err != nil {
+  if err := command(args); err != nil {
     log.Fatal(err)
   }
-
-  reader := io.Reader(file)
-  defer file.Close()
-
-  ScrapeRecipeKeeperExportHtml(reader)
 }
 
 // Dates are full of edge cases, and go has completely punted on ISO_8601 durations. :p
@@ -0,0 +1,71 @@
+package main
+
+import (
+  "fmt"
+  "regexp"
+  "strings"
+)
+
+// Make-ahead detection finds the sentences of the steps and notes that matter
+// when planning the cooking, like "Refrigerate overnight." or "The sauce can
+// be made 2 days ahead.", so they can be shown at the top rather than found
+// in step 7.
+
+const makeAheadTag = "make-ahead"
+
+var makeAheadRes = []*regexp.Regexp{
+  regexp.MustCompile(`(?i)\bovernight\b`),
+  regexp.MustCompile(`(?i)\bmake[- ]ahead\b`),
+  regexp.MustCompile(`(?i)\bin advance\b`),
+  regexp.MustCompile(`(?i)\b(?:a|one|the) day (?:ahead|before)\b`),
+  regexp.MustCompile(`(?i)\b\d+\s*(?:hours?|hrs?|days?|weeks?)\s+(?:ahead|before)\b`),
+  regexp.MustCompile(`(?i)\bcan be (?:made|prepared|assembled|frozen|refrigerated)\b`),
+  regexp.MustCompile(`(?i)\b(?:refrigerate|chill|marinate|rest|soak|freeze|rise|proof|cure|brine)\b.*\b(?:\d+|several|a few)(?:\s*(?:to|-|–)\s*\d+)?\s*(?:hours?|hrs?|days?)\b`),
+}
+
+var sentenceRe = regexp.MustCompile(`[^.!?]+[.!?]*`)
+
+// MakeAheadNotes returns the sentences of the lines that mention make-ahead
+// steps or long resting times.
+func MakeAheadNotes(lines []string) []string {
+  notes := make([]string, 0)
+  seen := map[string]bool{}
+  for _, line := range lines {
+    for _, sentence := range sentenceRe.FindAllString(line, -1) {
+      sentence = strings.TrimSpace(sentence)
+      if sentence == "" || seen[sentence] {
+        continue
+      }
+      for _, re := range makeAheadRes {
+        if re.MatchString(sentence) {
+          notes = append(notes, sentence)
+          seen[sentence] = true
+          break
+        }
+      }
+    }
+  }
+  return notes
+}
+
+// AnnotateMakeAhead fills in the recipe's MakeAhead callout from its steps and
+// notes and tags it make-ahead.
+func AnnotateMakeAhead(r Recipe) Recipe {
+  r.MakeAhead = MakeAheadNotes(append(append([]string(nil), r.InstructionLines...), r.NotesLines...))
+  if len(r.MakeAhead) == 0 {
+    return r
+  }
+  for _, category := range r.Metadata.CategoryList {
+    if strings.EqualFold(category, makeAheadTag) {
+      return r
+    }
+  }
+  r.Metadata.CategoryList = append(append([]string(nil), r.Metadata.CategoryList...), makeAheadTag)
+  return r
+}
+
+func (r Recipe) formatMakeAhead(options FormatOptions) string {
+  return fmt.Sprintf("> **%s:** %s", options.Labels.get("Make ahead"), strings.Join(r.MakeAhead, " "))
+}
+
+var makeAheadCalloutRe = regexp.MustCompile(`^> \*\*(.+):\*\* (.+)$`)
@@ -0,0 +1,141 @@
+package main
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "os"
+  "path/filepath"
+  "runtime/debug"
+  "sort"
+  "strings"
+  "time"
+)
+
+// Version is stamped at build time with -ldflags "-X main.Version=v1.2.3".
+var Version = ""
+
+const manifestName = "manifest.json"
+
+func ConverterVersion() string {
+  if Version != "" {
+    return Version
+  }
+
+  info, ok := debug.ReadBuildInfo()
+  if !ok {
+    return "unknown"
+  }
+  if info.Main.Version != "" && info.Main.Version != "(devel)" {
+    return info.Main.Version
+  }
+  for _, setting := range info.Settings {
+    if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+      return "devel-" + setting.Value[:12]
+    }
+  }
+  return "devel"
+}
+
+// OptionsSnapshot records what produced a set of output files, so differing
+// output between machines can be traced to either the options or the code.
+type OptionsSnapshot struct {
+  Version string `json:"version"`
+  Options map[string]string `json:"options"`
+  ConfigHash string `json:"config_hash"`
+}
+
+// NewOptionsSnapshot hashes the options together with the contents of any
+// config files they point at, since editing those changes the output too.
+func NewOptionsSnapshot(options map[string]string, configFiles ...string) (OptionsSnapshot, error) {
+  snapshot := OptionsSnapshot{Version: ConverterVersion(), Options: options}
+
+  hash := sha256.New()
+  keys := make([]string, 0, len(options))
+  for key := range options {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  for _, key := range keys {
+    fmt.Fprintf(hash, "%s=%s\n", key, options[key])
+  }
+
+  for _, path := range configFiles {
+    if path == "" {
+      continue
+    }
+    content, err := os.ReadFile(path)
+    if err != nil {
+      return snapshot, err
+    }
+    hash.Write(content)
+  }
+
+  snapshot.ConfigHash = hex.EncodeToString(hash.Sum(nil))[:16]
+  return snapshot, nil
+}
+
+func (s OptionsSnapshot) Comment() string {
+  return fmt.Sprintf("<!-- recipekeeper2recipemd %s config %s -->\n", s.Version, s.ConfigHash)
+}
+
+type ManifestEntry struct {
+  Title string `json:"title"`
+  Path string `json:"path"`
+  Hash string `json:"hash"`
+  Changes []ChangeEntry `json:"changes,omitempty"`
+  // Fetched is the page the recipe was fetched from, if it isn't from an
+  // export. Such recipes are kept when converting the exports again.
+  Fetched string `json:"fetched,omitempty"`
+}
+
+type Manifest struct {
+  OptionsSnapshot
+  GeneratedAt time.Time `json:"generated_at"`
+  Recipes map[string]ManifestEntry `json:"recipes"`
+}
+
+func NewManifest(snapshot OptionsSnapshot) *Manifest {
+  return &Manifest{
+    OptionsSnapshot: snapshot,
+    GeneratedAt: time.Now().UTC(),
+    Recipes: map[string]ManifestEntry{},
+  }
+}
+
+func ContentHash(content []byte) string {
+  sum := sha256.Sum256(content)
+  return hex.EncodeToString(sum[:])
+}
+
+func (m *Manifest) Record(r Recipe, path string, content []byte, changes []ChangeEntry) {
+  m.Recipes[r.Metadata.UUID] = ManifestEntry{
+    Title: strings.TrimSpace(r.Title),
+    Path: path,
+    Hash: ContentHash(content),
+    Changes: changes,
+    Fetched: r.fetched,
+  }
+}
+
+func (m *Manifest) Write(dir string) error {
+  content, err := json.MarshalIndent(m, "", "  ")
+  if err != nil {
+    return err
+  }
+  return os.WriteFile(filepath.Join(dir, manifestName), append(content, '\n'), 0644)
+}
+
+func ReadManifest(dir string) (*Manifest, error) {
+  content, err := os.ReadFile(filepath.Join(dir, manifestName))
+  if err != nil {
+    return nil, err
+  }
+
+  manifest := &Manifest{}
+  if err := json.Unmarshal(content, manifest); err != nil {
+    return nil, fmt.Errorf("%s: %w", manifestName, err)
+  }
+  return manifest, nil
+}
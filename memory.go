@@ -0,0 +1,69 @@
+package main
+
+import (
+  "runtime"
+  "sync/atomic"
+  "time"
+)
+
+// On small machines (a NAS, a Raspberry Pi) a big export with lots of photos
+// can outgrow the memory available. Exports are parsed a recipe at a time
+// (see RecipeKeeperHTML.ParseEach) and each recipe is let go once written,
+// and with a limit set, once the heap goes over it the converter drops the
+// optional work that holds on to memory (see degrade) instead of getting
+// killed halfway through. The limit is only watched, never handed to the
+// runtime: that would be for the whole process, serve included.
+
+type memoryWatchdog struct {
+  limit uint64
+  exceeded atomic.Bool
+  done chan struct{}
+}
+
+func startMemoryWatchdog(limit uint64, interval time.Duration) *memoryWatchdog {
+  w := &memoryWatchdog{
+    limit: limit,
+    done: make(chan struct{}),
+  }
+  go func() {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    var stats runtime.MemStats
+    for {
+      select {
+      case <-w.done:
+        return
+      case <-ticker.C:
+        runtime.ReadMemStats(&stats)
+        if stats.HeapAlloc > w.limit {
+          w.exceeded.Store(true)
+        }
+      }
+    }
+  }()
+  return w
+}
+
+func (w *memoryWatchdog) Exceeded() bool {
+  return w != nil && w.exceeded.Load()
+}
+
+func (w *memoryWatchdog) Stop() {
+  if w == nil {
+    return
+  }
+  close(w.done)
+}
+
+// degrade turns off photo resizing (decoding a photo takes far more memory
+// than the file) and the checks that read every file back. The returned function puts the options back for the next
+// run.
+func (c *Converter) degrade() func() {
+  images, verify, validator := c.Images, c.Verify, c.ReferenceValidator
+  c.Images.MaxSize = 0
+  c.Verify = false
+  c.ReferenceValidator = ""
+  return func() {
+    c.Images, c.Verify, c.ReferenceValidator = images, verify, validator
+  }
+}
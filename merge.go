@@ -0,0 +1,175 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// Line based three way merge, in the spirit of diff3. "mine" is the file on
+// disk (possibly edited by hand), "theirs" is freshly rendered from the export
+// and "base" is what we rendered last time.
+
+// lcsMatches maps every line of a to the line of b it is matched with by a
+// longest common subsequence, or -1.
+func lcsMatches(a []string, b []string) []int {
+  lengths := make([][]int, len(a)+1)
+  for i := range lengths {
+    lengths[i] = make([]int, len(b)+1)
+  }
+  for i := len(a) - 1; i >= 0; i-- {
+    for j := len(b) - 1; j >= 0; j-- {
+      if a[i] == b[j] {
+        lengths[i][j] = lengths[i+1][j+1] + 1
+      } else if lengths[i+1][j] >= lengths[i][j+1] {
+        lengths[i][j] = lengths[i+1][j]
+      } else {
+        lengths[i][j] = lengths[i][j+1]
+      }
+    }
+  }
+
+  matches := make([]int, len(a))
+  i, j := 0, 0
+  for i < len(a) {
+    switch {
+    case j < len(b) && a[i] == b[j]:
+      matches[i] = j
+      i++
+      j++
+    case j < len(b) && lengths[i][j+1] > lengths[i+1][j]:
+      j++
+    default:
+      matches[i] = -1
+      i++
+    }
+  }
+  return matches
+}
+
+func equalLines(a []string, b []string) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for i := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+  return true
+}
+
+func splitLines(content string) []string {
+  if content == "" {
+    return nil
+  }
+  return strings.SplitAfter(content, "\n")
+}
+
+// Merge3 merges the changes made in mine and theirs relative to base. Where
+// both changed the same lines it emits git style conflict markers and reports
+// conflicted as true.
+func Merge3(base string, mine string, theirs string) (merged string, conflicted bool) {
+  o, a, b := splitLines(base), splitLines(mine), splitLines(theirs)
+  matchA, matchB := lcsMatches(o, a), lcsMatches(o, b)
+
+  var output strings.Builder
+  writeLines := func(lines []string) {
+    for _, line := range lines {
+      output.WriteString(line)
+    }
+  }
+  writeMarker := func(marker string) {
+    if output.Len() > 0 && !strings.HasSuffix(output.String(), "\n") {
+      output.WriteString("\n")
+    }
+    output.WriteString(marker + "\n")
+  }
+
+  oi, ai, bi := 0, 0, 0
+  for oi < len(o) || ai < len(a) || bi < len(b) {
+    for oi < len(o) && ai < len(a) && bi < len(b) && matchA[oi] == ai && matchB[oi] == bi {
+      output.WriteString(o[oi])
+      oi++
+      ai++
+      bi++
+    }
+    if oi >= len(o) && ai >= len(a) && bi >= len(b) {
+      break
+    }
+
+    // Find the next base line both sides kept, the end of this unstable chunk
+    no, na, nb := len(o), len(a), len(b)
+    for k := oi; k < len(o); k++ {
+      if matchA[k] >= ai && matchB[k] >= bi {
+        no, na, nb = k, matchA[k], matchB[k]
+        break
+      }
+    }
+
+    chunkO, chunkA, chunkB := o[oi:no], a[ai:na], b[bi:nb]
+    switch {
+    case equalLines(chunkA, chunkO):
+      writeLines(chunkB)
+    case equalLines(chunkB, chunkO), equalLines(chunkA, chunkB):
+      writeLines(chunkA)
+    default:
+      conflicted = true
+      writeMarker("<<<<<<< mine")
+      writeLines(chunkA)
+      writeMarker("||||||| base")
+      writeLines(chunkO)
+      writeMarker("=======")
+      writeLines(chunkB)
+      writeMarker(">>>>>>> export")
+    }
+
+    oi, ai, bi = no, na, nb
+  }
+
+  return output.String(), conflicted
+}
+
+// The content we rendered on previous runs is kept by hash next to the
+// manifest, so it can serve as the merge base.
+const baseStoreDir = ".base"
+
+func baseStorePath(dir string, hash string) string {
+  return filepath.Join(dir, baseStoreDir, hash)
+}
+
+func StoreBase(dir string, content []byte) error {
+  path := baseStorePath(dir, ContentHash(content))
+  if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+    return err
+  }
+  return os.WriteFile(path, content, 0644)
+}
+
+func LoadBase(dir string, hash string) ([]byte, error) {
+  return os.ReadFile(baseStorePath(dir, hash))
+}
+
+// PruneBases drops stored content no longer referenced by the manifest.
+func PruneBases(dir string, manifest *Manifest) error {
+  referenced := map[string]bool{}
+  for _, entry := range manifest.Recipes {
+    referenced[entry.Hash] = true
+  }
+
+  entries, err := os.ReadDir(filepath.Join(dir, baseStoreDir))
+  if os.IsNotExist(err) {
+    return nil
+  } else if err != nil {
+    return err
+  }
+
+  for _, entry := range entries {
+    if !referenced[entry.Name()] {
+      if err := os.Remove(filepath.Join(dir, baseStoreDir, entry.Name())); err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
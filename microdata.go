@@ -0,0 +1,322 @@
+package main
+
+import (
+  "bytes"
+  "io"
+  "math"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/PuerkitoBio/goquery"
+)
+
+// Outside Recipe Keeper, recipe sites and apps mark their recipes up with
+// plain schema.org/Recipe microdata. Pages without Recipe Keeper's containers
+// are read that way instead, following the microdata rules for what belongs
+// to which item rather than Recipe Keeper's class names.
+
+const schemaRecipe = "schema.org/Recipe"
+
+// microdataItem holds the properties of an itemscope, leaving out those of
+// the items nested in it.
+type microdataItem map[string][]*goquery.Selection
+
+func readMicrodataItem(scope *goquery.Selection) microdataItem {
+  item := microdataItem{}
+  var walk func(*goquery.Selection)
+  walk = func(parent *goquery.Selection) {
+    parent.Children().Each(func(i int, child *goquery.Selection) {
+      for _, name := range strings.Fields(child.AttrOr("itemprop", "")) {
+        item[name] = append(item[name], child)
+      }
+      if _, nested := child.Attr("itemscope"); !nested {
+        walk(child)
+      }
+    })
+  }
+  walk(scope)
+  return item
+}
+
+func isItemScope(s *goquery.Selection) bool {
+  _, scope := s.Attr("itemscope")
+  return scope
+}
+
+func hasItemType(s *goquery.Selection, itemType string) bool {
+  for _, t := range strings.Fields(s.AttrOr("itemtype", "")) {
+    if strings.HasSuffix(t, itemType) {
+      return true
+    }
+  }
+  return false
+}
+
+// microdataValue is the value of a property by its element, as the
+// microdata spec has it.
+func microdataValue(s *goquery.Selection) string {
+  attr := ""
+  switch goquery.NodeName(s) {
+  case "meta":
+    attr = "content"
+  case "img", "audio", "embed", "iframe", "source", "track", "video":
+    attr = "src"
+  case "a", "area", "link":
+    attr = "href"
+  case "object":
+    attr = "data"
+  case "data", "meter":
+    attr = "value"
+  case "time":
+    if value, exists := s.Attr("datetime"); exists {
+      return strings.TrimSpace(value)
+    }
+  }
+  if attr != "" {
+    return strings.TrimSpace(s.AttrOr(attr, ""))
+  }
+  return strings.TrimSpace(s.Text())
+}
+
+func (m microdataItem) text(name string) string {
+  for _, s := range m[name] {
+    if value := microdataValue(s); value != "" {
+      return value
+    }
+  }
+  return ""
+}
+
+// list is every value of the property, split at commas as keywords are.
+func (m microdataItem) list(name string) []string {
+  values := make([]string, 0)
+  for _, s := range m[name] {
+    for _, value := range strings.Split(microdataValue(s), ",") {
+      if value = strings.TrimSpace(value); value != "" {
+        values = append(values, value)
+      }
+    }
+  }
+  return values
+}
+
+// microdataLines splits a block of ingredients or steps into lines: by its
+// list items or paragraphs if it has them, or else by line.
+func microdataLines(s *goquery.Selection) []string {
+  lines := make([]string, 0)
+  add := func(text string) {
+    if text = strings.Join(strings.Fields(text), " "); text != "" {
+      lines = append(lines, text)
+    }
+  }
+  if parts := s.Find("li"); parts.Length() > 0 {
+    parts.Each(func(i int, part *goquery.Selection) { add(part.Text()) })
+    return lines
+  }
+  if parts := s.Find("p"); parts.Length() > 0 {
+    parts.Each(func(i int, part *goquery.Selection) { add(part.Text()) })
+    return lines
+  }
+  for _, line := range strings.Split(microdataValue(s), "\n") {
+    add(line)
+  }
+  return lines
+}
+
+// instructions reads recipeInstructions as text, HowToSteps or HowToSections
+// of them. Sections become "Name:" headings, like Recipe Keeper's.
+func (m microdataItem) instructions() []string {
+  lines := make([]string, 0)
+  var add func(*goquery.Selection)
+  add = func(s *goquery.Selection) {
+    if !isItemScope(s) {
+      lines = append(lines, microdataLines(s)...)
+      return
+    }
+    item := readMicrodataItem(s)
+    if hasItemType(s, "HowToSection") {
+      if name := item.text("name"); name != "" {
+        lines = append(lines, strings.TrimSuffix(name, ":")+":")
+      }
+      for _, step := range item["itemListElement"] {
+        add(step)
+      }
+      return
+    }
+    if len(item["text"]) > 0 {
+      for _, text := range item["text"] {
+        lines = append(lines, microdataLines(text)...)
+      }
+      return
+    }
+    lines = append(lines, microdataLines(s)...)
+  }
+  for _, s := range m["recipeInstructions"] {
+    add(s)
+  }
+  return lines
+}
+
+// images are the photos, given either as a URL or an ImageObject.
+func (m microdataItem) images() []string {
+  photos := make([]string, 0)
+  for _, s := range m["image"] {
+    src := microdataValue(s)
+    if isItemScope(s) {
+      image := readMicrodataItem(s)
+      if src = image.text("contentUrl"); src == "" {
+        src = image.text("url")
+      }
+    }
+    if src != "" && (!isDataURI(src) || strings.HasPrefix(src, "data:image/")) {
+      photos = append(photos, src)
+    }
+  }
+  return photos
+}
+
+// rating is the aggregateRating out of 5 stars.
+func (m microdataItem) rating() (int, bool) {
+  rating := m
+  for _, s := range m["aggregateRating"] {
+    if isItemScope(s) {
+      rating = readMicrodataItem(s)
+    }
+  }
+  value, err := strconv.ParseFloat(rating.text("ratingValue"), 64)
+  if err != nil {
+    return 0, false
+  }
+  if best, err := strconv.ParseFloat(rating.text("bestRating"), 64); err == nil && best > 0 {
+    value = value * 5 / best
+  }
+  return int(math.Round(value)), true
+}
+
+func (m microdataItem) nutrition() RecipeNutrition {
+  nutrition := RecipeNutrition{}
+  for _, s := range m["nutrition"] {
+    if !isItemScope(s) {
+      continue
+    }
+    item := readMicrodataItem(s)
+    for label, name := range schemaNutrition {
+      if value := item.text(name); value != "" {
+        nutrition.Set(label, value)
+      }
+    }
+  }
+  return nutrition
+}
+
+// ExtractMicrodataRecipe reads a schema.org/Recipe item. Without an
+// identifier the recipe's id is made up from its title and ingredients, see
+// fallbackUUID.
+func ExtractMicrodataRecipe(scope *goquery.Selection) Recipe {
+  item := readMicrodataItem(scope)
+  recipe := Recipe{}
+  recipe.Title = strings.Join(strings.Fields(item.text("name")), " ")
+  recipe.Description = item.text("description")
+  recipe.PhotoPaths = item.images()
+
+  recipe.IngredientLines = make([]string, 0)
+  for _, name := range []string{"recipeIngredient", "ingredients"} {
+    for _, s := range item[name] {
+      recipe.IngredientLines = append(recipe.IngredientLines, microdataLines(s)...)
+    }
+  }
+  recipe.Ingredients = ParseIngredients(recipe.IngredientLines)
+  recipe.InstructionLines = item.instructions()
+  recipe.NotesLines = make([]string, 0)
+  recipe.Nutrition = item.nutrition()
+
+  metadata := &recipe.Metadata
+  metadata.UUID = item.text("identifier")
+  if rating, ok := item.rating(); ok {
+    metadata.Rating = rating
+  }
+  if metadata.Source = item.text("url"); metadata.Source == "" {
+    metadata.Source = item.text("isBasedOn")
+  }
+  metadata.CourseList = item.list("recipeCategory")
+  metadata.CategoryList = append(item.list("recipeCuisine"), item.list("keywords")...)
+  metadata.CollectionList = make([]string, 0)
+  metadata.Yield = item.text("recipeYield")
+  metadata.readSchemaTimes(item.text)
+  return recipe
+}
+
+// readSchemaTimes sets the durations and dates from the schema.org
+// properties, whether from microdata or JSON-LD.
+func (m *RecipeMetadata) readSchemaTimes(text func(name string) string) {
+  for _, duration := range []struct {
+    name string
+    value *time.Duration
+  }{
+    {"prepTime", &m.PrepTime},
+    {"cookTime", &m.CookTime},
+    {"totalTime", &m.TotalTime},
+  } {
+    value := text(duration.name)
+    if value == "" {
+      continue
+    }
+    if parsed, err := ParseISODuration(value); err == nil {
+      *duration.value = parsed
+    } else {
+      m.Unparsed = append(m.Unparsed, duration.name+" "+strconv.Quote(value))
+    }
+  }
+  if m.TotalTime == 0 {
+    m.TotalTime = m.PrepTime + m.CookTime
+  }
+  for _, name := range []string{"dateCreated", "datePublished"} {
+    if date, ok := ParseExportDate(text(name)); ok {
+      m.Created = date
+      break
+    }
+  }
+  if date, ok := ParseExportDate(text("dateModified")); ok {
+    m.Modified = date
+  }
+}
+
+// MicrodataSource reads the recipes of a page with schema.org microdata.
+type MicrodataSource struct{}
+
+func (MicrodataSource) Detect(r io.Reader) bool {
+  return bytes.Contains(sniff(r), []byte(schemaRecipe))
+}
+
+func (MicrodataSource) Parse(r io.Reader) ([]Recipe, error) {
+  doc, err := goquery.NewDocumentFromReader(r)
+  if err != nil {
+    return nil, err
+  }
+  return ScrapeMicrodata(doc), nil
+}
+
+// ScrapeMicrodata finds the schema.org/Recipe items of a page. Recipes
+// nested in another, like a sauce in a dish, are left to their parent.
+func ScrapeMicrodata(doc *goquery.Document) []Recipe {
+  recipes := make([]Recipe, 0)
+  taken := map[string]string{}
+  doc.Find(`[itemscope][itemtype*="` + schemaRecipe + `"]`).Each(func(i int, s *goquery.Selection) {
+    if !hasItemType(s, schemaRecipe) {
+      return
+    }
+    for parent := s.Parent(); parent.Length() > 0; parent = parent.Parent() {
+      if isItemScope(parent) && hasItemType(parent, schemaRecipe) {
+        return
+      }
+    }
+    recipe := ExtractMicrodataRecipe(s)
+    if recipe.Metadata.UUID == "" {
+      recipe.Metadata.UUID = fallbackUUID(recipe, taken)
+    }
+    taken[recipe.Metadata.UUID] = recipe.Title
+    recipes = append(recipes, recipe)
+  })
+  return recipes
+}
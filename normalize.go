@@ -0,0 +1,135 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "strings"
+  "unicode/utf8"
+)
+
+// Characters Recipe Keeper (and whatever sites recipes were pasted from) like
+// to sprinkle through the text that render badly or are awkward to type.
+var defaultReplacements = map[rune]string{
+  '\u00a0': " ", // non-breaking space
+  '\u2009': " ", // thin space
+  '\u202f': " ", // narrow non-breaking space
+  '\u200b': "", // zero width space
+  '\ufeff': "", // byte order mark
+  '‘': "'",
+  '’': "'",
+  '“': "\"",
+  '”': "\"",
+  '–': "-",
+  '—': "-",
+  '…': "...",
+  '•': "-",
+  '·': "-",
+  '×': "x",
+}
+
+// TextNormalizer is the one place extracted text gets cleaned up, including
+// the expansion of unicode vulgar fractions.
+type TextNormalizer struct {
+  Replacements map[rune]string
+  ConvertFractions bool
+}
+
+func DefaultTextNormalizer() *TextNormalizer {
+  replacements := make(map[rune]string, len(defaultReplacements))
+  for r, replacement := range defaultReplacements {
+    replacements[r] = replacement
+  }
+  return &TextNormalizer{Replacements: replacements, ConvertFractions: true}
+}
+
+// SpellDegrees writes degree signs as the word, "350 degrees F", for fonts
+// and tools that lack the sign.
+func (n *TextNormalizer) SpellDegrees() {
+  n.Replacements['°'] = " degrees "
+}
+
+// LoadReplacements merges a JSON object of {"character": "replacement"} pairs
+// over the current table. Map a character to itself to keep it untouched.
+func (n *TextNormalizer) LoadReplacements(path string) error {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return err
+  }
+
+  custom := map[string]string{}
+  if err := json.Unmarshal(content, &custom); err != nil {
+    return fmt.Errorf("%s: %w", path, err)
+  }
+
+  for key, replacement := range custom {
+    r, size := utf8.DecodeRuneInString(key)
+    if size == 0 || size != len(key) {
+      return fmt.Errorf("%s: replacement key %q must be a single character", path, key)
+    }
+    if string(r) == replacement {
+      delete(n.Replacements, r)
+    } else {
+      n.Replacements[r] = replacement
+    }
+  }
+
+  return nil
+}
+
+func (n *TextNormalizer) Normalize(input string) string {
+  var output strings.Builder
+
+  if n.ConvertFractions {
+    input = ConvertFractions(input)
+  }
+
+  for _, r := range input {
+    if replacement, exists := n.Replacements[r]; exists {
+      output.WriteString(replacement)
+    } else {
+      output.WriteRune(r)
+    }
+  }
+
+  // Replacements can leave doubled up or trailing spaces behind (e.g. "350° F")
+  return strings.Join(strings.Fields(output.String()), " ")
+}
+
+func (n *TextNormalizer) NormalizeList(input []string) []string {
+  output := make([]string, 0, len(input))
+  for _, line := range input {
+    if normalized := n.Normalize(line); normalized != "" {
+      output = append(output, normalized)
+    }
+  }
+  return output
+}
+
+// NormalizeRecipe applies the normalizer to every text field of the recipe.
+func (n *TextNormalizer) NormalizeRecipe(r Recipe) Recipe {
+  r.Title = n.Normalize(r.Title)
+  r.Description = strings.Join(n.NormalizeList(strings.Split(r.Description, "\n\n")), "\n\n")
+
+  r.Metadata.Source = n.Normalize(r.Metadata.Source)
+  r.Metadata.Yield = n.Normalize(r.Metadata.Yield)
+  r.Metadata.CategoryList = n.NormalizeList(r.Metadata.CategoryList)
+  r.Metadata.CourseList = n.NormalizeList(r.Metadata.CourseList)
+  r.Metadata.CollectionList = n.NormalizeList(r.Metadata.CollectionList)
+
+  r.Nutrition.Serving = n.Normalize(r.Nutrition.Serving)
+  r.Nutrition.Calories = n.Normalize(r.Nutrition.Calories)
+  r.Nutrition.TotalFat = n.Normalize(r.Nutrition.TotalFat)
+  r.Nutrition.SaturatedFat = n.Normalize(r.Nutrition.SaturatedFat)
+  r.Nutrition.Sodium = n.Normalize(r.Nutrition.Sodium)
+  r.Nutrition.TotalCarbohydrate = n.Normalize(r.Nutrition.TotalCarbohydrate)
+  r.Nutrition.DietaryFiber = n.Normalize(r.Nutrition.DietaryFiber)
+  r.Nutrition.Sugars = n.Normalize(r.Nutrition.Sugars)
+  r.Nutrition.Protein = n.Normalize(r.Nutrition.Protein)
+
+  r.IngredientLines = n.NormalizeList(r.IngredientLines)
+  r.InstructionLines = n.NormalizeList(r.InstructionLines)
+  r.NotesLines = n.NormalizeList(r.NotesLines)
+
+  return r
+}
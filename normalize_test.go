@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestNormalizeDegrees(t *testing.T) {
+  normalizer := DefaultTextNormalizer()
+  if got := normalizer.Normalize("Bake at 350° F – 20 minutes"); got != "Bake at 350° F - 20 minutes" {
+    t.Errorf("got %q, the degree sign is kept unless asked", got)
+  }
+  normalizer.SpellDegrees()
+  if got := normalizer.Normalize("Bake at 350°F"); got != "Bake at 350 degrees F" {
+    t.Errorf("got %q with SpellDegrees", got)
+  }
+}
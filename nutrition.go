@@ -0,0 +1,103 @@
+package main
+
+import (
+  "encoding/json"
+  "math"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+// NutritionAmount is a nutrition value taken apart: 245 and "kcal" for
+// "245 calories", 12 and "g" for "12g".
+type NutritionAmount struct {
+  Value float64 `json:"value"`
+  Unit string `json:"unit,omitempty"`
+}
+
+var nutritionAmountRe = regexp.MustCompile(`^(\d{1,3}(?:,\d{3})+|\d+(?:[.,]\d+)?)\s*([\p{L}µ]*)\.?$`)
+
+// nutritionUnits are the ways the units are written, by the one we use.
+var nutritionUnits = map[string]string{
+  "kcal": "kcal", "cal": "kcal", "cals": "kcal", "calorie": "kcal", "calories": "kcal", "kcals": "kcal",
+  "kj": "kJ", "kilojoule": "kJ", "kilojoules": "kJ",
+  "g": "g", "gr": "g", "gram": "g", "grams": "g",
+  "mg": "mg", "milligram": "mg", "milligrams": "mg",
+  "µg": "µg", "mcg": "µg", "microgram": "µg", "micrograms": "µg",
+}
+
+// nutritionDefaultUnits are assumed for bare numbers, which Recipe Keeper
+// writes when the unit was left out.
+var nutritionDefaultUnits = map[string]string{
+  "Calories": "kcal",
+  "Sodium": "mg",
+  "Total fat": "g",
+  "Saturated fat": "g",
+  "Total carbohydrate": "g",
+  "Dietary fiber": "g",
+  "Sugars": "g",
+  "Protein": "g",
+}
+
+// ParseNutritionAmount reads a value like "245 calories", "1,200 mg" or
+// "12.5g". The label picks the unit of bare numbers. Anything more, like
+// "< 1 g" or a "1 cup, cooked" serving size, isn't an amount.
+func ParseNutritionAmount(label string, text string) (NutritionAmount, bool) {
+  matches := nutritionAmountRe.FindStringSubmatch(strings.TrimSpace(text))
+  if matches == nil {
+    return NutritionAmount{}, false
+  }
+  number := matches[1]
+  if strings.Count(number, ",") > 1 || (strings.Contains(number, ",") && len(number)-strings.Index(number, ",") == 4) {
+    // a thousands separator, "1,200"
+    number = strings.ReplaceAll(number, ",", "")
+  }
+  value, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", "."), 64)
+  if err != nil {
+    return NutritionAmount{}, false
+  }
+
+  unit := nutritionDefaultUnits[label]
+  if matches[2] != "" {
+    if unit = nutritionUnits[strings.ToLower(matches[2])]; unit == "" {
+      unit = strings.ToLower(matches[2])
+    }
+  }
+  return NutritionAmount{Value: value, Unit: unit}, true
+}
+
+func (a NutritionAmount) String() string {
+  value := strconv.FormatFloat(a.Value, 'f', -1, 64)
+  if a.Unit == "" {
+    return value
+  }
+  return value + " " + a.Unit
+}
+
+// Scale multiplies the value, e.g. to go from one serving to the whole dish.
+func (a NutritionAmount) Scale(factor float64) NutritionAmount {
+  a.Value = math.Round(a.Value*factor*10) / 10
+  return a
+}
+
+// MarshalJSON writes each value with its number and unit next to the text,
+// so the JSON dumps can be queried without parsing the text again.
+func (n RecipeNutrition) MarshalJSON() ([]byte, error) {
+  type jsonValue struct {
+    Text string `json:"text"`
+    *NutritionAmount
+  }
+  values := map[string]jsonValue{}
+  for _, value := range n.Values() {
+    if value.Value == "" {
+      continue
+    }
+    entry := jsonValue{Text: value.Value}
+    if value.Parsed {
+      amount := value.Amount
+      entry.NutritionAmount = &amount
+    }
+    values[value.Label] = entry
+  }
+  return json.Marshal(values)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "fmt"
+  "path/filepath"
+  "regexp"
+  "strings"
+)
+
+// Everything in the export is untrusted: UUIDs end up in file names and photo
+// sources are read from disk, so neither may reach outside the folders they
+// belong to.
+
+var uuidUnsafeRe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// safeUUID reduces a UUID to characters that are safe in a file name. Real
+// Recipe Keeper UUIDs come through unchanged.
+func safeUUID(uuid string) string {
+  return strings.Trim(uuidUnsafeRe.ReplaceAllString(uuid, "-"), "-")
+}
+
+// fallbackUUID makes up an id for a recipe without a usable one of its own
+// from its title and ingredients, so it stays the same from one export to the
+// next. Recipes alike in both get numbered, in order, to stay clear of the
+// ids already taken.
+func fallbackUUID(r Recipe, taken map[string]string) string {
+  sum := sha256.Sum256([]byte(r.Title + "\x00" + strings.Join(r.IngredientLines, "\n")))
+  base := "recipe-" + hex.EncodeToString(sum[:])[:16]
+  uuid := base
+  for n := 2; ; n++ {
+    if _, exists := taken[uuid]; !exists {
+      return uuid
+    }
+    uuid = fmt.Sprintf("%s-%d", base, n)
+  }
+}
+
+// confinedPath joins a relative path from the export onto root, refusing
+// absolute paths and anything that climbs out of root.
+func confinedPath(root string, name string) (string, error) {
+  name = filepath.FromSlash(name)
+  if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, string(filepath.Separator)) {
+    return "", fmt.Errorf("%q is an absolute path", name)
+  }
+  path := filepath.Join(root, name)
+  if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+    return "", fmt.Errorf("%q points outside %s", name, root)
+  }
+  return path, nil
+}
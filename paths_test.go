@@ -0,0 +1,93 @@
+package main
+
+import (
+  "archive/zip"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestSafeUUID(t *testing.T) {
+  for _, test := range []struct {
+    uuid string
+    safe string
+  }{
+    {"0b6c3a2e-4f1d-4c8e-9a57-2f3d1e6b8c90", "0b6c3a2e-4f1d-4c8e-9a57-2f3d1e6b8c90"},
+    {"../../etc/passwd", "etc-passwd"},
+    {"/etc/passwd", "etc-passwd"},
+    {`..\..\windows\win.ini`, "windows-win-ini"},
+    {"../..", ""},
+  } {
+    if safe := safeUUID(test.uuid); safe != test.safe {
+      t.Errorf("safeUUID(%q) = %q, want %q", test.uuid, safe, test.safe)
+    }
+  }
+}
+
+func TestFallbackUUID(t *testing.T) {
+  recipe := Recipe{Title: "Pancakes", IngredientLines: []string{"1 cup flour", "1 egg"}}
+  uuid := fallbackUUID(recipe, map[string]string{})
+  if safeUUID(uuid) != uuid {
+    t.Errorf("fallback id %q isn't safe in a file name", uuid)
+  }
+  if again := fallbackUUID(recipe, map[string]string{}); again != uuid {
+    t.Errorf("fallback id changed from %q to %q", uuid, again)
+  }
+  if next := fallbackUUID(recipe, map[string]string{uuid: "Pancakes"}); next != uuid+"-2" {
+    t.Errorf("fallback id next to a taken one is %q, want %q", next, uuid+"-2")
+  }
+}
+
+func TestConfinedPath(t *testing.T) {
+  root := filepath.Join("export", "recipes")
+  for _, test := range []struct {
+    name string
+    ok bool
+  }{
+    {"images/photo.jpg", true},
+    {"images/../photo.jpg", true},
+    {"../photo.jpg", false},
+    {"images/../../photo.jpg", false},
+    {"../recipes-other/photo.jpg", false},
+    {"/etc/passwd", false},
+    {"..", false},
+  } {
+    path, err := confinedPath(root, test.name)
+    if test.ok && (err != nil || !strings.HasPrefix(path, root+string(filepath.Separator))) {
+      t.Errorf("confinedPath(%q) = %q, %v, want a path inside %s", test.name, path, err, root)
+    }
+    if !test.ok && err == nil {
+      t.Errorf("confinedPath(%q) = %q, want an error", test.name, path)
+    }
+  }
+}
+
+func TestExtractExportOutsideEntries(t *testing.T) {
+  for _, name := range []string{"../evil.txt", "export/../../evil.txt", "/evil.txt"} {
+    tmp := t.TempDir()
+    archive := filepath.Join(tmp, "export.zip")
+    file, err := os.Create(archive)
+    if err != nil {
+      t.Fatal(err)
+    }
+    writer := zip.NewWriter(file)
+    for _, entry := range []string{"export/recipes.html", name} {
+      w, err := writer.Create(entry)
+      if err != nil {
+        t.Fatal(err)
+      }
+      w.Write([]byte("<html></html>"))
+    }
+    writer.Close()
+    file.Close()
+
+    dir := filepath.Join(tmp, "unpacked")
+    if _, err := extractExport(archive, dir, 0); err == nil {
+      t.Errorf("zip entry %q was unpacked", name)
+    }
+    if _, err := os.Stat(filepath.Join(tmp, "evil.txt")); err == nil {
+      t.Errorf("zip entry %q was written outside %s", name, dir)
+    }
+  }
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+  "bytes"
+  "compress/zlib"
+  "fmt"
+  "image"
+  "image/color"
+  "image/jpeg"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// A small PDF writer of our own, for family members who want something to
+// print rather than a folder of Markdown. It knows just enough of the format
+// for text in the standard Helvetica fonts, JPEG photos and bookmarks, so it
+// needs no fonts or libraries installed. Text is in the WinAnsi encoding;
+// anything outside it is transliterated, see pdfEncode.
+
+const (
+  // A4, in points
+  pdfPageWidth = 595.0
+  pdfPageHeight = 842.0
+  pdfMargin = 56.0
+  // photos are shrunk to this many pixels on their longest side
+  pdfPhotoSize = 1200
+)
+
+type pdfFont int
+
+const (
+  pdfRegular pdfFont = iota
+  pdfBold
+  pdfItalic
+)
+
+var pdfFontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// Glyph widths, per 1000 points of font size, of the ASCII characters from
+// space to ~. Helvetica-Oblique has the widths of Helvetica.
+var helveticaWidths = [95]int{
+  278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+  556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+  1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+  667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+  333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+  556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+  278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+  556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+  975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+  667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+  333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+  611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// WinAnsi codes of the characters above Latin-1's control range that recipes
+// use; Latin-1 itself (0xA0-0xFF) has the same codes.
+var pdfWinAnsi = map[rune]byte{
+  '€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‰': 0x89, 'Š': 0x8A, 'Œ': 0x8C, 'Ž': 0x8E,
+  '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+  'š': 0x9A, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+func pdfEncode(text string) []byte {
+  encoded := make([]byte, 0, len(text))
+  for _, r := range text {
+    switch code, exists := pdfWinAnsi[r]; {
+    case r < 0x80:
+      encoded = append(encoded, byte(r))
+    case exists:
+      encoded = append(encoded, code)
+    case r >= 0xA0 && r <= 0xFF:
+      encoded = append(encoded, byte(r))
+    default:
+      encoded = append(encoded, ToASCII(string(r))...)
+    }
+  }
+  return encoded
+}
+
+func pdfWidth(font pdfFont, size float64, text []byte) float64 {
+  widths := &helveticaWidths
+  if font == pdfBold {
+    widths = &helveticaBoldWidths
+  }
+  total := 0
+  for _, c := range text {
+    switch {
+    case c >= 32 && c < 127:
+      total += widths[c-32]
+    case c == 0x95:
+      total += 350
+    case c == 0x97:
+      total += 1000
+    case c >= 0x91 && c <= 0x94:
+      total += 333
+    default:
+      total += 556
+    }
+  }
+  return float64(total) * size / 1000
+}
+
+func pdfString(text []byte) string {
+  var output strings.Builder
+  output.WriteByte('(')
+  for _, c := range text {
+    if c == '(' || c == ')' || c == '\\' {
+      output.WriteByte('\\')
+    }
+    output.WriteByte(c)
+  }
+  output.WriteByte(')')
+  return output.String()
+}
+
+type pdfPage struct {
+  content bytes.Buffer
+  images []int
+}
+
+type pdfBookmark struct {
+  title string
+  page int
+  y float64
+}
+
+// pdfDocument lays out text and photos top to bottom, starting new pages as
+// they fill up.
+type pdfDocument struct {
+  // the objects written so far, object n is objects[n-1]; the first three
+  // are the catalog, page tree and fonts, filled in by Bytes
+  objects [][]byte
+  pages []*pdfPage
+  bookmarks []pdfBookmark
+  y float64
+}
+
+func newPDFDocument() *pdfDocument {
+  return &pdfDocument{objects: make([][]byte, 3)}
+}
+
+func (d *pdfDocument) addObject(content []byte) int {
+  d.objects = append(d.objects, content)
+  return len(d.objects)
+}
+
+func (d *pdfDocument) page() *pdfPage {
+  return d.pages[len(d.pages)-1]
+}
+
+func (d *pdfDocument) newPage() {
+  d.pages = append(d.pages, &pdfPage{})
+  d.y = pdfPageHeight - pdfMargin
+}
+
+// ensure starts a new page unless there is height left on this one.
+func (d *pdfDocument) ensure(height float64) {
+  if len(d.pages) == 0 || d.y-height < pdfMargin {
+    d.newPage()
+  }
+}
+
+func (d *pdfDocument) space(height float64) {
+  if len(d.pages) > 0 && d.y < pdfPageHeight-pdfMargin {
+    d.y -= height
+  }
+}
+
+func (d *pdfDocument) bookmark(title string) {
+  d.ensure(0)
+  d.bookmarks = append(d.bookmarks, pdfBookmark{title, len(d.pages) - 1, d.y})
+}
+
+func (d *pdfDocument) show(font pdfFont, size float64, x float64, text []byte) {
+  fmt.Fprintf(&d.page().content, "BT /F%d %.1f Tf %.2f %.2f Td %s Tj ET\n", font+1, size, x, d.y, pdfString(text))
+}
+
+// paragraph wraps text to the width of the page. A prefix, like a bullet or
+// step number, hangs in front of the first line.
+func (d *pdfDocument) paragraph(font pdfFont, size float64, prefix string, text string) {
+  lineHeight := size * 1.35
+  x := pdfMargin
+  indent := 0.0
+  if prefix != "" {
+    indent = pdfWidth(font, size, pdfEncode(prefix+" "))
+  }
+  width := pdfPageWidth - 2*pdfMargin - indent
+
+  line := []byte{}
+  first := true
+  flush := func() {
+    d.ensure(lineHeight)
+    d.y -= size
+    if first && prefix != "" {
+      d.show(font, size, x, pdfEncode(prefix))
+    }
+    d.show(font, size, x+indent, line)
+    d.y -= lineHeight - size
+    line, first = []byte{}, false
+  }
+  for _, word := range strings.Fields(text) {
+    encoded := pdfEncode(word)
+    if len(line) > 0 && pdfWidth(font, size, line)+pdfWidth(font, size, encoded)+pdfWidth(font, size, []byte(" ")) > width {
+      flush()
+    }
+    if len(line) > 0 {
+      line = append(line, ' ')
+    }
+    line = append(line, encoded...)
+  }
+  if len(line) > 0 {
+    flush()
+  }
+}
+
+// photo draws a JPEG across the page, no taller than a third of it.
+func (d *pdfDocument) photo(content []byte) error {
+  data, width, height, colorSpace, err := pdfJPEG(content)
+  if err != nil {
+    return err
+  }
+  id := d.addObject([]byte(fmt.Sprintf(
+    "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream",
+    width, height, colorSpace, len(data), data)))
+
+  drawWidth := pdfPageWidth - 2*pdfMargin
+  drawHeight := drawWidth * float64(height) / float64(width)
+  if limit := (pdfPageHeight - 2*pdfMargin) / 3; drawHeight > limit {
+    drawHeight = limit
+    drawWidth = drawHeight * float64(width) / float64(height)
+  }
+  d.ensure(drawHeight)
+  d.y -= drawHeight
+  page := d.page()
+  page.images = append(page.images, id)
+  fmt.Fprintf(&page.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", drawWidth, drawHeight, pdfMargin, d.y, id)
+  d.y -= 12
+  return nil
+}
+
+// pdfJPEG gets a photo ready to embed: JPEGs are used as they are when small
+// enough, anything else Go can decode is re-encoded.
+func pdfJPEG(content []byte) ([]byte, int, int, string, error) {
+  config, format, err := image.DecodeConfig(bytes.NewReader(content))
+  if err != nil {
+    return nil, 0, 0, "", err
+  }
+  longest := config.Width
+  if config.Height > longest {
+    longest = config.Height
+  }
+  if format == "jpeg" && longest <= pdfPhotoSize {
+    switch config.ColorModel {
+    case color.YCbCrModel:
+      return content, config.Width, config.Height, "DeviceRGB", nil
+    case color.GrayModel:
+      return content, config.Width, config.Height, "DeviceGray", nil
+    }
+  }
+
+  img, _, err := image.Decode(bytes.NewReader(content))
+  if err != nil {
+    return nil, 0, 0, "", err
+  }
+  if longest > pdfPhotoSize {
+    bounds := img.Bounds()
+    img = downscale(img, maxInt(bounds.Dx()*pdfPhotoSize/longest, 1), maxInt(bounds.Dy()*pdfPhotoSize/longest, 1))
+  }
+  var buffer bytes.Buffer
+  if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 85}); err != nil {
+    return nil, 0, 0, "", err
+  }
+  return buffer.Bytes(), img.Bounds().Dx(), img.Bounds().Dy(), "DeviceRGB", nil
+}
+
+func pdfStream(dictionary string, content []byte) []byte {
+  var compressed bytes.Buffer
+  writer := zlib.NewWriter(&compressed)
+  writer.Write(content)
+  writer.Close()
+  return []byte(fmt.Sprintf("<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", dictionary, compressed.Len(), compressed.Bytes()))
+}
+
+func (d *pdfDocument) Bytes() []byte {
+  if len(d.pages) == 0 {
+    d.newPage()
+  }
+
+  fonts := make([]string, 0, len(pdfFontNames))
+  for i, name := range pdfFontNames {
+    id := d.addObject([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>"))
+    fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, id))
+  }
+  d.objects[2] = []byte("<< " + strings.Join(fonts, " ") + " >>")
+
+  pageIDs := make([]int, 0, len(d.pages))
+  kids := make([]string, 0, len(d.pages))
+  for _, page := range d.pages {
+    content := d.addObject(pdfStream("", page.content.Bytes()))
+    images := make([]string, 0, len(page.images))
+    for _, id := range page.images {
+      images = append(images, fmt.Sprintf("/Im%d %d 0 R", id, id))
+    }
+    id := d.addObject([]byte(fmt.Sprintf(
+      "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font 3 0 R /XObject << %s >> >> /Contents %d 0 R >>",
+      pdfPageWidth, pdfPageHeight, strings.Join(images, " "), content)))
+    pageIDs = append(pageIDs, id)
+    kids = append(kids, fmt.Sprintf("%d 0 R", id))
+  }
+  d.objects[1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+  catalog := "<< /Type /Catalog /Pages 2 0 R"
+  if len(d.bookmarks) > 0 {
+    // the outline and its items are numbered in a row, so they can point at
+    // each other before they exist
+    outline := len(d.objects) + 1
+    first, last := outline+1, outline+len(d.bookmarks)
+    d.addObject([]byte(fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", first, last, len(d.bookmarks))))
+    for i, bookmark := range d.bookmarks {
+      item := fmt.Sprintf("<< /Title %s /Parent %d 0 R /Dest [%d 0 R /XYZ null %.2f null]", pdfString(pdfEncode(bookmark.title)), outline, pageIDs[bookmark.page], bookmark.y)
+      if i > 0 {
+        item += fmt.Sprintf(" /Prev %d 0 R", first+i-1)
+      }
+      if i < len(d.bookmarks)-1 {
+        item += fmt.Sprintf(" /Next %d 0 R", first+i+1)
+      }
+      d.addObject([]byte(item + " >>"))
+    }
+    catalog += fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", outline)
+  }
+  d.objects[0] = []byte(catalog + " >>")
+
+  var output bytes.Buffer
+  output.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+  offsets := make([]int, len(d.objects))
+  for i, object := range d.objects {
+    offsets[i] = output.Len()
+    fmt.Fprintf(&output, "%d 0 obj\n", i+1)
+    output.Write(object)
+    output.WriteString("\nendobj\n")
+  }
+  xref := output.Len()
+  fmt.Fprintf(&output, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+  for _, offset := range offsets {
+    fmt.Fprintf(&output, "%010d 00000 n \n", offset)
+  }
+  fmt.Fprintf(&output, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, xref)
+  return output.Bytes()
+}
+
+// readExportPhoto reads a photo from the export, for the formats that embed
+// the photos. Photos on the web aren't fetched.
+func readExportPhoto(r Recipe, src string) ([]byte, error) {
+  switch {
+  case isRemotePhoto(src):
+    return nil, fmt.Errorf("photo %s is on the web", photoLabel(src))
+  case isDataURI(src):
+    content, _, err := DecodeDataURI(src)
+    return content, err
+  }
+  path, err := confinedPath(r.exportDir, src)
+  if err != nil {
+    return nil, err
+  }
+  return os.ReadFile(path)
+}
+
+// addRecipe lays out a recipe: its title, first photo, metadata, the
+// ingredients, steps and notes. A marker above 0 is the number of its source
+// in the credits.
+func (d *pdfDocument) addRecipe(r Recipe, options FormatOptions, marker int) {
+  d.bookmark(r.Title)
+  d.paragraph(pdfBold, 20, "", r.Title)
+  d.space(6)
+
+  for _, src := range r.PhotoPaths {
+    if content, err := readExportPhoto(r, src); err == nil && d.photo(content) == nil {
+      break
+    }
+  }
+
+  metadata := make([]string, 0)
+  add := func(label string, value string) {
+    if value != "" {
+      metadata = append(metadata, options.Labels.get(label)+": "+value)
+    }
+  }
+  if r.Metadata.Rating > 0 {
+    add("Rating", strconv.Itoa(r.Metadata.Rating)+"/5")
+  }
+  add("Course", strings.Join(r.Metadata.CourseList, ", "))
+  add("Collections", strings.Join(r.Metadata.CollectionList, ", "))
+  add("Yield", r.Metadata.Yield)
+  for _, duration := range []struct {
+    label string
+    value time.Duration
+  }{
+    {"Prep Time", r.Metadata.PrepTime},
+    {"Cook Time", r.Metadata.CookTime},
+    {"Rest Time", r.Metadata.RestTime},
+    {"Total Time", r.Metadata.TotalTime},
+  } {
+    if duration.value > 0 {
+      add(duration.label, FormatShortDuration(duration.value))
+    }
+  }
+  if marker > 0 {
+    add("Source", fmt.Sprintf("%s [%d]", r.Metadata.Source, marker))
+  } else {
+    add("Source", r.Metadata.Source)
+  }
+  for _, line := range metadata {
+    d.paragraph(pdfRegular, 9, "", line)
+  }
+  if r.Description != "" {
+    d.space(8)
+    for _, paragraph := range strings.Split(r.Description, "\n\n") {
+      d.paragraph(pdfItalic, 11, "", paragraph)
+      d.space(4)
+    }
+  }
+
+  heading := func(title string) {
+    d.space(10)
+    d.ensure(60)
+    d.paragraph(pdfBold, 14, "", title)
+    d.space(4)
+  }
+  if len(r.IngredientLines) > 0 {
+    heading(options.Labels.get("Ingredients"))
+    for _, line := range r.IngredientLines {
+      if IsSectionHeading(line) {
+        d.space(4)
+        d.paragraph(pdfBold, 11, "", SectionTitle(line))
+        continue
+      }
+      d.paragraph(pdfRegular, 11, "•", options.fractions(line))
+    }
+  }
+
+  if len(r.InstructionLines) > 0 {
+    heading(options.Labels.get("Instructions"))
+    step := 0
+    for _, line := range r.InstructionLines {
+      if IsSectionHeading(line) {
+        step = 0
+        d.space(4)
+        d.paragraph(pdfBold, 11, "", SectionTitle(line))
+        continue
+      }
+      step++
+      prefix := ""
+      switch options.StepsStyle {
+      case StepsNumbered:
+        prefix = strconv.Itoa(step) + "."
+      case StepsBulleted:
+        prefix = "•"
+      }
+      d.paragraph(pdfRegular, 11, prefix, options.fractions(line))
+      d.space(5)
+    }
+  }
+
+  if len(r.NotesLines) > 0 {
+    heading(options.Labels.get("Notes"))
+    for _, line := range r.NotesLines {
+      d.paragraph(pdfRegular, 11, "", line)
+      d.space(3)
+    }
+  }
+}
+
+// PDFRenderer writes each recipe as a PDF of its own, with the first photo
+// embedded, so the photos aren't copied next to the files.
+type PDFRenderer struct {
+  Options FormatOptions
+}
+
+func (p PDFRenderer) Render(r Recipe) ([]byte, error) {
+  document := newPDFDocument()
+  document.addRecipe(r, p.Options, 0)
+  return document.Bytes(), nil
+}
+
+func (PDFRenderer) Ext() string {
+  return "pdf"
+}
+
+// PDFCookbookRenderer writes all recipes into one PDF, each starting on a new
+// page and listed in the bookmarks.
+type PDFCookbookRenderer struct {
+  Options FormatOptions
+}
+
+func (p PDFCookbookRenderer) RenderAll(recipes []Recipe) ([]byte, error) {
+  sorted := append([]Recipe(nil), recipes...)
+  sort.SliceStable(sorted, func(i, j int) bool {
+    return strings.ToLower(sorted[i].Title) < strings.ToLower(sorted[j].Title)
+  })
+
+  var citations *Citations
+  if p.Options.Credits {
+    citations = NewCitations(sorted)
+  }
+
+  document := newPDFDocument()
+  for _, recipe := range sorted {
+    document.newPage()
+    document.addRecipe(recipe, p.Options, citations.Marker(recipe))
+  }
+  if citations != nil && citations.Len() > 0 {
+    title := p.Options.Labels.get("Credits")
+    document.newPage()
+    document.bookmark(title)
+    document.paragraph(pdfBold, 20, "", title)
+    document.space(6)
+    for _, credit := range citations.Credits() {
+      document.paragraph(pdfRegular, 11, fmt.Sprintf("%d.", credit.Marker), credit.Citation.Text()+" — "+strings.Join(credit.Titles, ", "))
+      document.space(3)
+    }
+  }
+  return document.Bytes(), nil
+}
+
+func (PDFCookbookRenderer) FileName() string {
+  return "cookbook.pdf"
+}
+
+func init() {
+  RegisterFormat(OutputFormat{Name: "pdf", Exts: []string{"pdf"}, New: func(s RenderSettings) (Renderer, CollectionRenderer, error) {
+    return PDFRenderer{Options: s.Options}, nil, nil
+  }})
+  RegisterFormat(OutputFormat{Name: "cookbook-pdf", New: func(s RenderSettings) (Renderer, CollectionRenderer, error) {
+    return nil, PDFCookbookRenderer{Options: s.Options}, nil
+  }})
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+  "bufio"
+  "errors"
+  "io"
+  "os"
+  "path/filepath"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// The reverse of FormatAsRecipeMD. Anything we emit should survive a round
+// trip, and hand written RecipeMD files are parsed on a best effort basis.
+
+type recipeMDSection int
+
+const (
+  sectionHeader recipeMDSection = iota
+  sectionIngredients
+  sectionInstructions
+  sectionNotes
+  sectionPhotos
+  sectionNutrition
+  sectionChangelog
+)
+
+func isThematicBreak(line string) bool {
+  trimmed := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+  if len(trimmed) < 3 {
+    return false
+  }
+  for _, c := range []string{"-", "*", "_"} {
+    if strings.Trim(trimmed, c) == "" {
+      return true
+    }
+  }
+  return false
+}
+
+var markdownImageRe = regexp.MustCompile(`^!\[[^\]]*\]\(([^)]+)\)$`)
+
+// photos are images, or links for the gzipped ones that can't be shown
+var photoLineRe = regexp.MustCompile(`^!?\[[^\]]*\]\(([^)]+)\)$`)
+
+// the numbers of steps written with --steps numbered
+var stepNumberRe = regexp.MustCompile(`^\d+[.)]\s+`)
+
+func isHTMLComment(line string) bool {
+  return strings.HasPrefix(line, "<!--") && strings.HasSuffix(line, "-->")
+}
+
+func listItemText(line string) (string, bool) {
+  trimmed := strings.TrimSpace(line)
+  for _, bullet := range []string{"- ", "* ", "+ "} {
+    if strings.HasPrefix(trimmed, bullet) {
+      return strings.TrimSpace(trimmed[len(bullet):]), true
+    }
+  }
+  return "", false
+}
+
+// a line of #tags, as written with FormatOptions.HashTags
+func isHashTagLine(line string) bool {
+  for _, field := range strings.Fields(line) {
+    if len(field) < 2 || field[0] != '#' || field[1] == '#' {
+      return false
+    }
+  }
+  return line != ""
+}
+
+func splitList(value string) []string {
+  list := make([]string, 0)
+  for _, item := range strings.Split(value, ",") {
+    item = strings.TrimSpace(item)
+    if item != "" {
+      list = append(list, item)
+    }
+  }
+  return list
+}
+
+func (m *RecipeMetadata) parseHeaderLine(line string, labels Labels) bool {
+  key, value, found := strings.Cut(line, ": ")
+  if !found {
+    return false
+  }
+
+  switch labels.canonical(key) {
+  case "Rating":
+    rating, err := strconv.Atoi(strings.TrimSuffix(value, "-star"))
+    if err != nil {
+      // or written as stars, ★★★☆☆
+      if rating = strings.Count(value, "★"); rating == 0 || strings.Trim(value, "★☆") != "" {
+        return false
+      }
+    }
+    m.Rating = rating
+  case "Collections":
+    m.CollectionList = splitList(value)
+  case "Course":
+    m.CourseList = splitList(value)
+  case "Source":
+    m.Source = UnformatSource(value)
+  case "Cook Time":
+    duration, err := time.ParseDuration(value)
+    if err != nil {
+      return false
+    }
+    m.CookTime = duration
+  case "Prep Time":
+    duration, err := time.ParseDuration(value)
+    if err != nil {
+      return false
+    }
+    m.PrepTime = duration
+  case "Rest Time":
+    duration, err := time.ParseDuration(value)
+    if err != nil {
+      return false
+    }
+    m.RestTime = duration
+  case "Total Time":
+    duration, err := time.ParseDuration(value)
+    if err != nil {
+      return false
+    }
+    m.TotalTime = duration
+  default:
+    return false
+  }
+
+  return true
+}
+
+func ParseRecipeMD(reader io.Reader) (Recipe, error) {
+  return ParseRecipeMDLabels(reader, nil)
+}
+
+// ParseRecipeMDLabels reads a file written with custom labels, see
+// LoadLabels. The built in translations are understood either way.
+func ParseRecipeMDLabels(reader io.Reader, labels Labels) (Recipe, error) {
+  recipe := Recipe{}
+  section := sectionHeader
+  breaks := 0
+  blank := false
+
+  scanner := bufio.NewScanner(reader)
+  scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+  for scanner.Scan() {
+    line := strings.TrimRight(scanner.Text(), " \t\r")
+    trimmed := strings.TrimSpace(line)
+
+    if recipe.Title == "" && section == sectionHeader {
+      if strings.HasPrefix(trimmed, "# ") {
+        recipe.Title = strings.TrimSpace(trimmed[2:])
+      } else if strings.HasPrefix(trimmed, "uuid: ") {
+        // from the front matter
+        recipe.Metadata.UUID, _ = strconv.Unquote(strings.TrimPrefix(trimmed, "uuid: "))
+      } else if trimmed == "favorite: true" {
+        recipe.Metadata.Favorited = true
+      } else if strings.HasPrefix(trimmed, "created: ") {
+        recipe.Metadata.Created, _ = ParseExportDate(strings.TrimPrefix(trimmed, "created: "))
+      } else if strings.HasPrefix(trimmed, "modified: ") {
+        recipe.Metadata.Modified, _ = ParseExportDate(strings.TrimPrefix(trimmed, "modified: "))
+      }
+      continue
+    }
+
+    if matches := sourceCommentRe.FindStringSubmatch(trimmed); matches != nil {
+      recipe.Metadata.UUID = matches[1]
+      recipe.Metadata.Favorited = matches[2] != ""
+      continue
+    }
+
+    if breaks < 2 && isThematicBreak(trimmed) {
+      breaks++
+      section++
+      continue
+    }
+
+    if trimmed == "" || isHTMLComment(trimmed) {
+      blank = blank || trimmed == ""
+      continue
+    }
+    paragraphBreak := blank
+    blank = false
+
+    switch section {
+    case sectionHeader:
+      // the hero image is a copy of the first photo
+      if matches := makeAheadCalloutRe.FindStringSubmatch(trimmed); matches != nil && labels.canonical(matches[1]) == "Make ahead" {
+        recipe.MakeAhead = []string{matches[2]}
+        continue
+      }
+      if recipe.Metadata.parseHeaderLine(trimmed, labels) || markdownImageRe.MatchString(trimmed) || isHashTagLine(trimmed) {
+        continue
+      }
+      if strings.HasPrefix(trimmed, "**") && strings.HasSuffix(trimmed, "**") && len(trimmed) > 4 {
+        recipe.Metadata.Yield = strings.TrimSpace(trimmed[2 : len(trimmed)-2])
+      } else if strings.HasPrefix(trimmed, "*") && strings.HasSuffix(trimmed, "*") && len(trimmed) > 2 {
+        recipe.Metadata.CategoryList = splitList(trimmed[1 : len(trimmed)-1])
+      } else if recipe.Description == "" {
+        recipe.Description = trimmed
+      } else if paragraphBreak {
+        recipe.Description += "\n\n" + trimmed
+      } else {
+        recipe.Description += "\n" + trimmed
+      }
+    case sectionIngredients:
+      if item, ok := listItemText(trimmed); ok {
+        recipe.IngredientLines = append(recipe.IngredientLines, item)
+      } else if strings.HasPrefix(trimmed, "#") {
+        // a group heading, written as a "For the sauce:" line in the export
+        recipe.IngredientLines = append(recipe.IngredientLines, labels.canonical(strings.TrimLeft(trimmed, "# "))+":")
+      }
+    case sectionInstructions, sectionNotes, sectionPhotos, sectionNutrition, sectionChangelog:
+      if strings.HasPrefix(trimmed, "#") {
+        switch labels.canonical(strings.TrimLeft(trimmed, "# ")) {
+        case "Instructions":
+          continue
+        case "Notes":
+          section = sectionNotes
+          continue
+        case "Photos":
+          section = sectionPhotos
+          continue
+        case "Nutrition":
+          section = sectionNutrition
+          continue
+        case "Changelog":
+          section = sectionChangelog
+          continue
+        }
+        if section == sectionInstructions {
+          // a sub heading, written as a "For the filling:" line in the export
+          recipe.InstructionLines = append(recipe.InstructionLines, strings.TrimLeft(trimmed, "# ")+":")
+          continue
+        }
+      }
+
+      if section == sectionChangelog {
+        continue
+      } else if section == sectionPhotos {
+        if matches := photoLineRe.FindStringSubmatch(trimmed); matches != nil {
+          recipe.PhotoPaths = append(recipe.PhotoPaths, matches[1])
+        }
+      } else if section == sectionNutrition {
+        if item, ok := listItemText(trimmed); ok {
+          label, value, _ := strings.Cut(item, ": ")
+          recipe.Nutrition.Set(labels.canonical(label), value)
+        }
+      } else if section == sectionNotes {
+        recipe.NotesLines = append(recipe.NotesLines, trimmed)
+      } else {
+        if item, ok := listItemText(trimmed); ok {
+          trimmed = item
+        } else {
+          trimmed = stepNumberRe.ReplaceAllString(trimmed, "")
+        }
+        recipe.InstructionLines = append(recipe.InstructionLines, trimmed)
+      }
+    }
+  }
+
+  if err := scanner.Err(); err != nil {
+    return recipe, err
+  }
+  if recipe.Title == "" {
+    return recipe, errors.New("recipemd: missing title")
+  }
+  // the timing summary under the Instructions heading is made from the steps
+  if lines := recipe.InstructionLines; len(lines) > 1 && lines[0] == SectionTimingSummary(lines[1:]) {
+    recipe.InstructionLines = lines[1:]
+  }
+
+  // "*2 cups* flour" is how RecipeMD marks the amount
+  lines := make([]string, 0, len(recipe.IngredientLines))
+  for _, line := range recipe.IngredientLines {
+    if strings.HasPrefix(line, "*") && strings.Count(line, "*") >= 2 {
+      line = strings.Replace(line[1:], "*", "", 1)
+    }
+    lines = append(lines, line)
+  }
+  recipe.Ingredients = ParseIngredients(lines)
+
+  return recipe, nil
+}
+
+// Files without the UUID in their front matter or source comment are named
+// after it, so recover it from the name.
+func ParseRecipeMDFile(path string, labels Labels) (Recipe, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return Recipe{}, err
+  }
+  defer file.Close()
+
+  recipe, err := ParseRecipeMDLabels(file, labels)
+  if err != nil {
+    return recipe, err
+  }
+
+  if recipe.Metadata.UUID == "" {
+    recipe.Metadata.UUID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+  }
+  return recipe, nil
+}
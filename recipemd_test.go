@@ -0,0 +1,40 @@
+package main
+
+import (
+  "bytes"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestRecipeMDStepsRoundTrip(t *testing.T) {
+  recipe := Recipe{
+    Title: "Pie",
+    IngredientLines: []string{"1 cup flour", "2 apples"},
+    InstructionLines: []string{"For the crust:", "Mix the flour.", "Roll it out.", "For the filling:", "Slice the apples.", "Bake for 40 minutes."},
+  }
+  for _, style := range []StepsStyle{StepsPlain, StepsNumbered, StepsBulleted} {
+    content, err := RecipeMDRenderer{Options: FormatOptions{StepsStyle: style}}.Render(recipe)
+    if err != nil {
+      t.Fatal(err)
+    }
+    parsed, err := ParseRecipeMD(bytes.NewReader(content))
+    if err != nil {
+      t.Fatalf("steps %s: %s", style, err)
+    }
+    if !reflect.DeepEqual(parsed.InstructionLines, recipe.InstructionLines) {
+      t.Errorf("steps %s: read back %q, want %q", style, parsed.InstructionLines, recipe.InstructionLines)
+    }
+  }
+}
+
+func TestRecipeMDHeaderLinks(t *testing.T) {
+  content := "# Pie\n\n![Pie](pie.jpg)\n\n[Grandma's notes](https://example.com/notes)\n\n---\n\n- 2 apples\n\n---\n\nBake.\n"
+  recipe, err := ParseRecipeMD(strings.NewReader(content))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if recipe.Description != "[Grandma's notes](https://example.com/notes)" {
+    t.Errorf("description %q, want the link line", recipe.Description)
+  }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+  "bytes"
+  "errors"
+  "os/exec"
+  "strings"
+)
+
+// The built in validator only knows the parts of the spec we thought of. The
+// reference implementation (https://github.com/tstehr/recipemd, installed with
+// `pip install recipemd`) is the final word, so it can be run over every file
+// we write when it is available.
+
+const referenceCommand = "recipemd"
+
+// FindReferenceValidator returns the path of the reference recipemd command,
+// or "" when it isn't installed.
+func FindReferenceValidator() string {
+  path, err := exec.LookPath(referenceCommand)
+  if err != nil {
+    return ""
+  }
+  return path
+}
+
+// ValidateWithReference runs the reference command on a file and returns the
+// problems it reported. The command prints the parsed recipe on success, and
+// exits with an error and a message on stderr otherwise.
+func ValidateWithReference(command string, path string) ([]string, error) {
+  var stderr bytes.Buffer
+  cmd := exec.Command(command, path)
+  cmd.Stderr = &stderr
+
+  err := cmd.Run()
+  var exitErr *exec.ExitError
+  if err != nil && !errors.As(err, &exitErr) {
+    return nil, err
+  }
+  if err == nil {
+    return nil, nil
+  }
+
+  problems := make([]string, 0)
+  for _, line := range strings.Split(stderr.String(), "\n") {
+    if line = strings.TrimSpace(line); line != "" {
+      problems = append(problems, line)
+    }
+  }
+  if len(problems) == 0 {
+    problems = append(problems, exitErr.Error())
+  }
+  return problems, nil
+}
+
+func (c *Converter) referenceValidate(recipe Recipe) {
+  if c.Renderer.Ext() != "md" {
+    return
+  }
+
+  problems, err := ValidateWithReference(c.ReferenceValidator, c.outputPath(recipe))
+  if err != nil {
+    c.emit(ConversionWarning, recipe, "recipemd: "+err.Error())
+    return
+  }
+  for _, problem := range problems {
+    c.emit(ReferenceViolation, recipe, problem)
+  }
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+  "time"
+)
+
+// A Renderer turns a single Recipe into the bytes of one output file. New
+// output formats should implement this rather than growing FormatAsRecipeMD.
+type Renderer interface {
+  Render(Recipe) ([]byte, error)
+  Ext() string
+}
+
+type RecipeMDRenderer struct {
+  Options FormatOptions
+}
+
+func (m RecipeMDRenderer) Render(r Recipe) ([]byte, error) {
+  content, err := FormatRecipeMD(r, m.Options)
+  return []byte(content), err
+}
+
+func (RecipeMDRenderer) Ext() string {
+  return "md"
+}
+
+// An IndexRenderer also writes a page linking to every recipe it rendered,
+// for formats that are browsed rather than edited.
+type IndexRenderer interface {
+  RenderIndex([]IndexEntry) ([]byte, error)
+  IndexFileName() string
+}
+
+// A CollectionRenderer writes all recipes into a single file instead, for
+// formats like spreadsheets where one file per recipe makes no sense.
+type CollectionRenderer interface {
+  RenderAll([]Recipe) ([]byte, error)
+  FileName() string
+}
+
+// RenderSettings are the options an output format's renderer is made with.
+type RenderSettings struct {
+  Options FormatOptions
+  Language string
+  TextWidth int
+  // Date is for formats that need one, the time of the newest export.
+  Date time.Time
+}
+
+// An OutputFormat is one --format. New makes either a Renderer, for a file
+// per recipe, or a CollectionRenderer for a single file. Formats register
+// themselves from the file they are written in.
+type OutputFormat struct {
+  Name string
+  // Exts are the file extensions that select the format as well, like md.
+  Exts []string
+  New func(RenderSettings) (Renderer, CollectionRenderer, error)
+}
+
+var outputFormats = map[string]OutputFormat{}
+
+func RegisterFormat(format OutputFormat) {
+  outputFormats[format.Name] = format
+}
+
+// FormatNames lists the registered formats by name.
+func FormatNames() []string {
+  names := make([]string, 0, len(outputFormats))
+  for name := range outputFormats {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// LookupFormat finds a format by its name or by the extension of the files
+// it writes, so "md" and ".txt" work too.
+func LookupFormat(name string) (OutputFormat, bool) {
+  name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "."))
+  if format, exists := outputFormats[name]; exists {
+    return format, true
+  }
+  for _, known := range FormatNames() {
+    if contains(outputFormats[known].Exts, name) {
+      return outputFormats[known], true
+    }
+  }
+  return OutputFormat{}, false
+}
+
+// SelectFormats reads a --format: one format, a comma separated list of
+// them, or all for every registered one.
+func SelectFormats(value string) ([]OutputFormat, error) {
+  names := splitList(value)
+  if len(names) == 1 && names[0] == "all" {
+    names = FormatNames()
+  }
+  formats := make([]OutputFormat, 0, len(names))
+  seen := map[string]bool{}
+  for _, name := range names {
+    format, exists := LookupFormat(name)
+    if !exists {
+      return nil, fmt.Errorf("unknown format %q, use one of %s or all", name, strings.Join(FormatNames(), ", "))
+    }
+    if !seen[format.Name] {
+      seen[format.Name] = true
+      formats = append(formats, format)
+    }
+  }
+  if len(formats) == 0 {
+    return nil, fmt.Errorf("no output format given")
+  }
+  return formats, nil
+}
+
+func init() {
+  RegisterFormat(OutputFormat{Name: "recipemd", Exts: []string{"md", "markdown"}, New: func(s RenderSettings) (Renderer, CollectionRenderer, error) {
+    return RecipeMDRenderer{Options: s.Options}, nil, nil
+  }})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+  "encoding/json"
+  "os"
+  "path/filepath"
+)
+
+// ConversionReport is the outcome of a conversion for every recipe, written
+// as JSON with --report for scripts to act on.
+type ConversionReport struct {
+  Pipeline string `json:"pipeline,omitempty"`
+  Output string `json:"output"`
+  // Error is what stopped the conversion, if anything did.
+  Error string `json:"error,omitempty"`
+  Recipes []*RecipeReport `json:"recipes"`
+
+  byUUID map[string]*RecipeReport
+}
+
+// RecipeReport is what became of one recipe. Status is converted, skipped,
+// failed, or unselected when left out by --favorites-only or --only-tags.
+type RecipeReport struct {
+  UUID string `json:"uuid"`
+  Title string `json:"title"`
+  Status string `json:"status"`
+  // Path is the written file, or Reason why it was skipped.
+  Path string `json:"path,omitempty"`
+  Reason string `json:"reason,omitempty"`
+  Warnings []ReportMessage `json:"warnings"`
+  Errors []string `json:"errors"`
+}
+
+type ReportMessage struct {
+  // Kind is that of the ProgressEvent: warning, lint, duplicate, incomplete,
+  // ingredient or recipemd.
+  Kind string `json:"kind"`
+  Message string `json:"message"`
+}
+
+func NewConversionReport(pipeline string, outputDir string) *ConversionReport {
+  return &ConversionReport{
+    Pipeline: pipeline,
+    Output: outputDir,
+    Recipes: make([]*RecipeReport, 0),
+    byUUID: map[string]*RecipeReport{},
+  }
+}
+
+// Add records an event, in the order they come.
+func (r *ConversionReport) Add(event ProgressEvent) {
+  if event.UUID == "" || event.Kind == RecipeRemoved {
+    return
+  }
+  recipe, exists := r.byUUID[event.UUID]
+  if !exists {
+    recipe = &RecipeReport{UUID: event.UUID, Title: event.Title, Status: "unselected", Warnings: make([]ReportMessage, 0), Errors: make([]string, 0)}
+    r.byUUID[event.UUID] = recipe
+    r.Recipes = append(r.Recipes, recipe)
+  }
+
+  switch event.Kind {
+  case RecipeConverted:
+    recipe.Status = "converted"
+    recipe.Path = filepath.Join(r.Output, event.Message)
+  case RecipeSkipped:
+    recipe.Status = "skipped"
+    recipe.Reason = event.Message
+  case RecipeFailed:
+    recipe.Status = "failed"
+    recipe.Errors = append(recipe.Errors, event.Message)
+  case ConversionWarning, LintWarning, ReferenceViolation, UncertainIngredient, DuplicateRecipe, IncompleteRecipe:
+    recipe.Warnings = append(recipe.Warnings, ReportMessage{event.Kind.String(), event.Message})
+  }
+}
+
+// Write saves the report to path, with err the error the conversion ended
+// with.
+func (r *ConversionReport) Write(path string, err error) error {
+  if err != nil {
+    r.Error = err.Error()
+  }
+  content, err := json.MarshalIndent(r, "", "  ")
+  if err != nil {
+    return err
+  }
+  return os.WriteFile(path, append(content, '\n'), 0644)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+  "bytes"
+  "image"
+  "image/color"
+  "image/jpeg"
+  "image/png"
+)
+
+// ImageOptions control how copied photos are shrunk. Zero values leave the
+// photos untouched.
+type ImageOptions struct {
+  // MaxSize is the longest side in pixels.
+  MaxSize int
+  // Quality is the JPEG quality (1-100) used when re-encoding.
+  Quality int
+}
+
+func (o ImageOptions) enabled() bool {
+  return o.MaxSize > 0 || o.Quality > 0
+}
+
+// ResizePhoto scales a JPEG or PNG photo down to fit options.MaxSize and
+// re-encodes it. Other formats, and results that aren't any smaller, are
+// returned unchanged.
+func ResizePhoto(content []byte, ext string, options ImageOptions) ([]byte, error) {
+  if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+    return content, nil
+  }
+
+  img, _, err := image.Decode(bytes.NewReader(content))
+  if err != nil {
+    return nil, err
+  }
+
+  bounds := img.Bounds()
+  longest := bounds.Dx()
+  if bounds.Dy() > longest {
+    longest = bounds.Dy()
+  }
+  if options.MaxSize > 0 && longest > options.MaxSize {
+    width := bounds.Dx() * options.MaxSize / longest
+    height := bounds.Dy() * options.MaxSize / longest
+    img = downscale(img, maxInt(width, 1), maxInt(height, 1))
+  }
+
+  var buffer bytes.Buffer
+  if ext == ".png" {
+    encoder := png.Encoder{CompressionLevel: png.BestCompression}
+    err = encoder.Encode(&buffer, img)
+  } else {
+    quality := options.Quality
+    if quality <= 0 {
+      quality = jpeg.DefaultQuality
+    }
+    err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: quality})
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  if buffer.Len() >= len(content) {
+    return content, nil
+  }
+  return buffer.Bytes(), nil
+}
+
+// downscale averages the source pixels falling into each destination pixel,
+// which is plenty for shrinking photos.
+func downscale(src image.Image, width int, height int) image.Image {
+  bounds := src.Bounds()
+  dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+
+  for y := 0; y < height; y++ {
+    y0 := bounds.Min.Y + y*bounds.Dy()/height
+    y1 := maxInt(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+    for x := 0; x < width; x++ {
+      x0 := bounds.Min.X + x*bounds.Dx()/width
+      x1 := maxInt(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+
+      var r, g, b, a, n uint64
+      for sy := y0; sy < y1; sy++ {
+        for sx := x0; sx < x1; sx++ {
+          pr, pg, pb, pa := src.At(sx, sy).RGBA()
+          r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+          n++
+        }
+      }
+      dst.SetRGBA64(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+    }
+  }
+
+  return dst
+}
+
+func maxInt(a int, b int) int {
+  if a > b {
+    return a
+  }
+  return b
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+  "time"
+)
+
+// The review command walks through the recipes of an export one at a time so
+// they can be sorted into what should be converted and what needs work first.
+// Every decision is saved straight away, so a long session can be stopped and
+// picked up again later; convert --review-state then leaves out the excluded
+// recipes.
+
+type Decision string
+
+const (
+  DecisionInclude Decision = "include"
+  DecisionExclude Decision = "exclude"
+  DecisionNeedsFixing Decision = "needs-fixing"
+)
+
+const defaultReviewState = "review.json"
+
+type ReviewEntry struct {
+  Title string `json:"title"`
+  Decision Decision `json:"decision"`
+  Note string `json:"note,omitempty"`
+  DecidedAt time.Time `json:"decidedAt"`
+}
+
+type ReviewState struct {
+  // Recipes holds the decisions by recipe UUID.
+  Recipes map[string]ReviewEntry `json:"recipes"`
+  // Last is the UUID of the recipe the last session stopped at.
+  Last string `json:"last,omitempty"`
+}
+
+func NewReviewState() *ReviewState {
+  return &ReviewState{Recipes: map[string]ReviewEntry{}}
+}
+
+// LoadReviewState reads a state file, a missing file is an empty state.
+func LoadReviewState(path string) (*ReviewState, error) {
+  content, err := os.ReadFile(path)
+  if os.IsNotExist(err) {
+    return NewReviewState(), nil
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  state := NewReviewState()
+  if err := json.Unmarshal(content, state); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+  if state.Recipes == nil {
+    state.Recipes = map[string]ReviewEntry{}
+  }
+  return state, nil
+}
+
+// Save writes the state through a temporary file so that quitting halfway
+// through a write can't lose the earlier decisions.
+func (s *ReviewState) Save(path string) error {
+  content, err := json.MarshalIndent(s, "", "  ")
+  if err != nil {
+    return err
+  }
+  temp := path + ".tmp"
+  if err := os.WriteFile(temp, append(content, '\n'), 0644); err != nil {
+    return err
+  }
+  return os.Rename(temp, path)
+}
+
+func (s *ReviewState) Decide(r Recipe, decision Decision, note string) {
+  s.Recipes[r.Metadata.UUID] = ReviewEntry{
+    Title: r.Title,
+    Decision: decision,
+    Note: note,
+    DecidedAt: time.Now().UTC().Truncate(time.Second),
+  }
+  s.Last = r.Metadata.UUID
+}
+
+// Excluded reports whether the recipe was excluded during review.
+func (s *ReviewState) Excluded(r Recipe) bool {
+  return s.Recipes[r.Metadata.UUID].Decision == DecisionExclude
+}
+
+func (s *ReviewState) counts() map[Decision]int {
+  counts := map[Decision]int{}
+  for _, entry := range s.Recipes {
+    counts[entry.Decision]++
+  }
+  return counts
+}
+
+func printRecipeSummary(w io.Writer, r Recipe, position int, total int) {
+  fmt.Fprintf(w, "\n[%d/%d] %s (%s)\n", position, total, r.Title, r.Metadata.UUID)
+  if r.Description != "" {
+    fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(r.Description, "\n\n", " "))
+  }
+  if len(r.Metadata.CollectionList) > 0 {
+    fmt.Fprintf(w, "  collections: %s\n", strings.Join(r.Metadata.CollectionList, ", "))
+  }
+  fmt.Fprintf(w, "  %d ingredient(s), %d step(s), %d photo(s)\n",
+    len(r.IngredientLines), len(r.InstructionLines), len(r.PhotoPaths))
+  if reason := JunkReason(r); reason != "" {
+    fmt.Fprintf(w, "  looks like junk: %s\n", reason)
+  }
+}
+
+func runReview(args []string) error {
+  flags := flag.NewFlagSet("review", flag.ExitOnError)
+  statePath := flags.String("state", defaultReviewState, "file the decisions are kept in")
+  all := flags.Bool("all", false, "also go over recipes that already have a decision")
+  flags.Parse(args)
+
+  path := defaultExportPath
+  if flags.NArg() > 0 {
+    path = flags.Arg(0)
+  }
+  path = exportFile(path)
+
+  file, err := os.Open(path)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+
+  recipes, err := ScrapeRecipeKeeperExportHtml(file)
+  if err != nil {
+    return err
+  }
+  state, err := LoadReviewState(*statePath)
+  if err != nil {
+    return err
+  }
+
+  pending := make([]Recipe, 0, len(recipes))
+  for _, recipe := range recipes {
+    if _, decided := state.Recipes[recipe.Metadata.UUID]; *all || !decided {
+      pending = append(pending, DefaultTextNormalizer().NormalizeRecipe(recipe))
+    }
+  }
+  // going over everything again picks up after the recipe decided last
+  if *all {
+    for i, recipe := range pending {
+      if recipe.Metadata.UUID == state.Last {
+        pending = append(pending[i+1:], pending[:i+1]...)
+        break
+      }
+    }
+  }
+  fmt.Printf("%d of %d recipe(s) to review, decisions are saved to %s\n", len(pending), len(recipes), *statePath)
+
+  input := bufio.NewScanner(os.Stdin)
+  for i := 0; i < len(pending); i++ {
+    recipe := pending[i]
+    printRecipeSummary(os.Stdout, recipe, i+1, len(pending))
+    if entry, decided := state.Recipes[recipe.Metadata.UUID]; decided {
+      fmt.Printf("  currently: %s %s\n", entry.Decision, entry.Note)
+    }
+
+    fmt.Print("[i]nclude, [e]xclude, [f]ix (with a note), [s]kip, [q]uit? ")
+    if !input.Scan() {
+      break
+    }
+    answer := strings.TrimSpace(input.Text())
+    command, note, _ := strings.Cut(answer, " ")
+
+    switch strings.ToLower(command) {
+    case "i":
+      state.Decide(recipe, DecisionInclude, "")
+    case "e":
+      state.Decide(recipe, DecisionExclude, strings.TrimSpace(note))
+    case "f":
+      state.Decide(recipe, DecisionNeedsFixing, strings.TrimSpace(note))
+    case "s", "":
+      continue
+    case "q":
+      i = len(pending)
+      continue
+    default:
+      fmt.Printf("unknown answer %q\n", answer)
+      i--
+      continue
+    }
+
+    if err := state.Save(*statePath); err != nil {
+      return err
+    }
+  }
+
+  counts := state.counts()
+  fmt.Printf("\n%d included, %d excluded, %d need fixing, %d undecided\n",
+    counts[DecisionInclude], counts[DecisionExclude], counts[DecisionNeedsFixing],
+    len(recipes)-len(state.Recipes))
+  return input.Err()
+}
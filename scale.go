@@ -0,0 +1,137 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+  "strings"
+)
+
+// Scaling multiplies the ingredient amounts and the yield of a recipe. Lines we
+// can't split reliably (ranges, "2 x 400g", ...) are left alone rather than
+// scaled wrong. The same goes for converting units.
+
+const minRewriteConfidence = 0.5
+
+// ServingsFactor is the factor that turns the recipe's yield into servings,
+// e.g. 1.5 for a yield of "4 servings" and 6 servings.
+func ServingsFactor(r Recipe, servings int) (float64, error) {
+  // a yield of "4-6 servings" is scaled from its low end
+  _, value, _, ok := parseAmountRange(strings.TrimSpace(r.Metadata.Yield))
+  if !ok {
+    return 0, fmt.Errorf("yield %q has no amount to scale from", r.Metadata.Yield)
+  }
+  if value == 0 {
+    return 0, fmt.Errorf("yield %q is zero", r.Metadata.Yield)
+  }
+  return float64(servings) / value, nil
+}
+
+// ScaleRecipe multiplies the recipe's ingredient amounts and yield by factor,
+// and the parts of divided ingredients mentioned in the steps.
+func ScaleRecipe(r Recipe, factor float64) Recipe {
+  r.InstructionLines = scaleDividedSteps(r, factor)
+  lines := make([]string, 0, len(r.IngredientLines))
+  for _, line := range r.IngredientLines {
+    lines = append(lines, ScaleIngredient(line, factor))
+  }
+  r.IngredientLines = lines
+
+  if amount, rest, ok := splitAmount(r.Metadata.Yield); ok {
+    r.Metadata.Yield = scaleAmount(amount, factor) + rest
+  }
+  return r
+}
+
+// ScaleIngredient scales the amount of an ingredient line, e.g. "1/2 cup
+// milk" by 3 is "1 1/2 cup milk".
+func ScaleIngredient(line string, factor float64) string {
+  if IsSectionHeading(line) {
+    return line
+  }
+  ingredient := ParseIngredient(line)
+  if ingredient.AmountText == "" || ingredient.Confidence < minRewriteConfidence {
+    return line
+  }
+  before, rest, ok := ingredient.splitAt(ingredient.AmountText)
+  if !ok {
+    return line
+  }
+  if _, after, ok := ingredient.splitAt(ingredient.Quantity()); ingredient.AltText != "" && ok {
+    alt := scaleAmount(ingredient.AltAmountText, factor) + ingredient.AltText[len(ingredient.AltAmountText):]
+    rest = " " + ingredient.UnitText + " (" + alt + ")" + after
+  }
+  return before + scaleAmount(ingredient.AmountText, factor) + rest
+}
+
+// scaleAmount scales an amount as written, both ends of a range.
+func scaleAmount(amount string, factor float64) string {
+  if low, separator, high, ok := splitRange(amount); ok {
+    lowValue, _ := ParseAmount(low)
+    highValue, _ := ParseAmount(high)
+    return formatAmount(lowValue*factor, low) + separator + formatAmount(highValue*factor, high)
+  }
+  value, _ := ParseAmount(amount)
+  return formatAmount(value*factor, amount)
+}
+
+func splitAmount(text string) (string, string, bool) {
+  text = strings.TrimSpace(text)
+  amount, _, _, ok := parseAmountRange(text)
+  if amount == "" || !ok {
+    return "", text, false
+  }
+  return amount, text[len(amount):], true
+}
+
+// formatAmount writes a scaled amount in the style of the original: fractions
+// stay fractions (rounded to the nearest eighth or third), decimals keep their
+// separator and are rounded to what a kitchen scale or measuring cup shows.
+func formatAmount(value float64, original string) string {
+  if strings.Contains(original, "/") {
+    return formatFraction(value)
+  }
+
+  switch {
+  case value >= 10:
+    value = math.Round(value)
+  case value >= 1:
+    value = math.Round(value*10) / 10
+  default:
+    value = math.Round(value*100) / 100
+  }
+  text := strconv.FormatFloat(value, 'f', -1, 64)
+  if strings.Contains(original, ",") {
+    text = strings.Replace(text, ".", ",", 1)
+  }
+  return text
+}
+
+func formatFraction(value float64) string {
+  whole := math.Floor(value)
+  rest := value - whole
+
+  numerator, denominator := 0, 1
+  best := rest
+  for _, d := range []int{2, 3, 4, 8} {
+    n := int(math.Round(rest * float64(d)))
+    if diff := math.Abs(rest - float64(n)/float64(d)); diff < best-1e-9 {
+      numerator, denominator, best = n, d, diff
+    }
+  }
+  if numerator == denominator {
+    whole++
+    numerator = 0
+  }
+
+  switch {
+  case numerator == 0 && whole == 0:
+    // don't scale an ingredient away entirely
+    return "1/8"
+  case numerator == 0:
+    return strconv.Itoa(int(whole))
+  case whole == 0:
+    return fmt.Sprintf("%d/%d", numerator, denominator)
+  }
+  return fmt.Sprintf("%d %d/%d", int(whole), numerator, denominator)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io/fs"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strings"
+)
+
+// search finds recipes among the converted files by title, tags and
+// ingredients: "what can I make with leeks". The files are read each time,
+// which is quick enough for any library a household has.
+
+// SearchEntry is what is searched of one recipe file.
+type SearchEntry struct {
+  Path string
+  Title string
+  Tags []string
+  Ingredients []string
+}
+
+// SearchResult is a recipe matching every word of the query. Matched are the
+// ingredient lines the query found, to show.
+type SearchResult struct {
+  SearchEntry
+  Score int
+  Matched []string
+}
+
+// IndexRecipeFiles reads the RecipeMD files under dir for searching. Files
+// that don't parse are left out.
+func IndexRecipeFiles(dir string) ([]SearchEntry, error) {
+  entries := make([]SearchEntry, 0)
+  err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    if d.IsDir() || filepath.Ext(path) != ".md" || d.Name() == indexFile {
+      return nil
+    }
+    recipe, err := ParseRecipeMDFile(path, nil)
+    if err != nil || recipe.Title == "" {
+      return nil
+    }
+    entries = append(entries, SearchEntry{
+      Path: path,
+      Title: recipe.Title,
+      Tags: recipe.tags(CharsetUnicode),
+      Ingredients: recipe.IngredientLines,
+    })
+    return nil
+  })
+  return entries, err
+}
+
+var searchWordRe = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchStem lowercases a word and drops the plural endings, so "leeks"
+// finds "leek" and the other way around.
+func searchStem(word string) string {
+  word = strings.ToLower(word)
+  switch {
+  case len(word) <= 3:
+  case strings.HasSuffix(word, "ies"):
+    return strings.TrimSuffix(word, "ies") + "y"
+  case strings.HasSuffix(word, "oes"), strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "sses"):
+    return strings.TrimSuffix(word, "es")
+  case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+    return strings.TrimSuffix(word, "s")
+  }
+  return word
+}
+
+// searchMatches tells whether a word of the text starts with the stem.
+func searchMatches(text string, stem string) bool {
+  for _, word := range searchWordRe.FindAllString(text, -1) {
+    if strings.HasPrefix(searchStem(word), stem) {
+      return true
+    }
+  }
+  return false
+}
+
+// SearchRecipes finds the entries matching every word of the query, the best
+// first: a word in the title counts most, then in the tags, then in the
+// ingredients. A word can be limited to one of them with title:, tag: or
+// ingredient:, as in "tag:vegan leeks".
+func SearchRecipes(entries []SearchEntry, query string) ([]SearchResult, error) {
+  type term struct {
+    field string
+    stem string
+  }
+  terms := make([]term, 0)
+  for _, word := range strings.Fields(query) {
+    field := ""
+    if prefix, rest, found := strings.Cut(word, ":"); found {
+      switch prefix {
+      case "title", "tag", "ingredient":
+        field, word = prefix, rest
+      default:
+        return nil, fmt.Errorf("unknown search field %q, use title:, tag: or ingredient:", prefix)
+      }
+    }
+    for _, part := range searchWordRe.FindAllString(word, -1) {
+      terms = append(terms, term{field, searchStem(part)})
+    }
+  }
+  if len(terms) == 0 {
+    return nil, errors.New("nothing to search for")
+  }
+
+  results := make([]SearchResult, 0)
+  for _, entry := range entries {
+    result := SearchResult{SearchEntry: entry}
+    matched := map[string]bool{}
+    for _, t := range terms {
+      score := 0
+      if (t.field == "" || t.field == "title") && searchMatches(entry.Title, t.stem) {
+        score += 3
+      }
+      if t.field == "" || t.field == "tag" {
+        for _, tag := range entry.Tags {
+          if searchMatches(tag, t.stem) {
+            score += 2
+            break
+          }
+        }
+      }
+      if t.field == "" || t.field == "ingredient" {
+        found := false
+        for _, line := range entry.Ingredients {
+          if IsSectionHeading(line) || !searchMatches(line, t.stem) {
+            continue
+          }
+          found = true
+          if !matched[line] {
+            matched[line] = true
+            result.Matched = append(result.Matched, line)
+          }
+        }
+        if found {
+          score++
+        }
+      }
+      if score == 0 {
+        result.Score = 0
+        break
+      }
+      result.Score += score
+    }
+    if result.Score > 0 {
+      results = append(results, result)
+    }
+  }
+
+  sort.SliceStable(results, func(i, j int) bool {
+    if results[i].Score != results[j].Score {
+      return results[i].Score > results[j].Score
+    }
+    return strings.ToLower(results[i].Title) < strings.ToLower(results[j].Title)
+  })
+  return results, nil
+}
+
+func runSearch(args []string) error {
+  flags := flag.NewFlagSet("search", flag.ExitOnError)
+  dir := flags.String("dir", outputDir, "folder of converted recipes to search")
+  limit := flags.Int("limit", 0, "show at most this many recipes, 0 for all")
+  flags.Parse(args)
+
+  if flags.NArg() == 0 {
+    return errors.New("usage: recipekeeper2recipemd search [-dir dir] <query>")
+  }
+
+  entries, err := IndexRecipeFiles(*dir)
+  if err != nil {
+    return err
+  }
+  results, err := SearchRecipes(entries, strings.Join(flags.Args(), " "))
+  if err != nil {
+    return err
+  }
+  if len(results) == 0 {
+    return fmt.Errorf("no recipes in %s match %q", *dir, strings.Join(flags.Args(), " "))
+  }
+  if *limit > 0 && len(results) > *limit {
+    results = results[:*limit]
+  }
+  for _, result := range results {
+    fmt.Printf("%s\t%s\n", result.Title, result.Path)
+    for _, line := range result.Matched {
+      fmt.Printf("  %s\n", line)
+    }
+  }
+  return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+  "fmt"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+  "unicode"
+  "unicode/utf8"
+)
+
+// Recipe Keeper has no notion of sub sections, so people write lines like
+// "For the filling:" in between the steps instead.
+type InstructionSection struct {
+  Title string
+  Lines []string
+}
+
+func IsSectionHeading(line string) bool {
+  line = strings.TrimSpace(line)
+  if !strings.HasSuffix(line, ":") || utf8.RuneCountInString(line) > 60 {
+    return false
+  }
+  body := strings.TrimSuffix(line, ":")
+  return body != "" && !strings.ContainsAny(body, ".!?:") && len(strings.Fields(body)) <= 6
+}
+
+func SectionTitle(heading string) string {
+  return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(heading), ":"))
+}
+
+// SplitInstructionSections groups the lines under their headings. Lines before
+// the first heading end up in a section without a title.
+func SplitInstructionSections(lines []string) []InstructionSection {
+  sections := make([]InstructionSection, 0)
+  current := InstructionSection{}
+
+  for _, line := range lines {
+    if IsSectionHeading(line) {
+      if current.Title != "" || len(current.Lines) > 0 {
+        sections = append(sections, current)
+      }
+      current = InstructionSection{Title: SectionTitle(line)}
+      continue
+    }
+    current.Lines = append(current.Lines, line)
+  }
+
+  if current.Title != "" || len(current.Lines) > 0 {
+    sections = append(sections, current)
+  }
+
+  return sections
+}
+
+var durationRe = regexp.MustCompile(`(?i)\b(\d+(?:[.,]\d+)?)(?:\s*(?:-|to)\s*(\d+(?:[.,]\d+)?))?\s*(hours?|hrs?|minutes?|mins?|seconds?|secs?)\b`)
+
+// DetectDurations finds times like "20 minutes" or "1-2 hrs" in free text. For
+// ranges the upper bound is used since that is what you need to plan for. A
+// bare "m" or "h" isn't taken as a time, "2 m of dough" is a length.
+func DetectDurations(text string) []time.Duration {
+  durations := make([]time.Duration, 0)
+
+  for _, matches := range durationRe.FindAllStringSubmatch(text, -1) {
+    amount := matches[1]
+    if matches[2] != "" {
+      amount = matches[2]
+    }
+    value, err := strconv.ParseFloat(strings.ReplaceAll(amount, ",", "."), 64)
+    if err != nil {
+      continue
+    }
+
+    unit := time.Minute
+    switch strings.ToLower(matches[3])[0] {
+    case 'h':
+      unit = time.Hour
+    case 's':
+      unit = time.Second
+    }
+    durations = append(durations, time.Duration(value*float64(unit)))
+  }
+
+  return durations
+}
+
+func (s InstructionSection) Duration() time.Duration {
+  total := time.Duration(0)
+  for _, line := range s.Lines {
+    for _, duration := range DetectDurations(line) {
+      total += duration
+    }
+  }
+  return total
+}
+
+func FormatShortDuration(d time.Duration) string {
+  d = d.Round(time.Minute)
+  hours := int(d / time.Hour)
+  minutes := int((d % time.Hour) / time.Minute)
+
+  switch {
+  case hours > 0 && minutes > 0:
+    return fmt.Sprintf("%d h %d min", hours, minutes)
+  case hours > 0:
+    return fmt.Sprintf("%d h", hours)
+  }
+  return fmt.Sprintf("%d min", minutes)
+}
+
+func capitalize(s string) string {
+  r, size := utf8.DecodeRuneInString(s)
+  return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// SectionTimingSummary gives an at a glance plan like "Dough 20 min · Bake
+// 35 min" for recipes whose instructions are split into titled sections.
+func SectionTimingSummary(lines []string) string {
+  parts := make([]string, 0)
+
+  for _, section := range SplitInstructionSections(lines) {
+    duration := section.Duration()
+    if section.Title == "" || duration < time.Minute {
+      continue
+    }
+
+    name := section.Title
+    for _, prefix := range []string{"For the ", "for the ", "For ", "for "} {
+      name = strings.TrimPrefix(name, prefix)
+    }
+    parts = append(parts, capitalize(name)+" "+FormatShortDuration(duration))
+  }
+
+  return strings.Join(parts, " · ")
+}
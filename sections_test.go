@@ -0,0 +1,49 @@
+package main
+
+import (
+  "bytes"
+  "reflect"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestDetectDurations(t *testing.T) {
+  for _, test := range []struct {
+    text string
+    want []time.Duration
+  }{
+    {"Simmer for 20 minutes.", []time.Duration{20 * time.Minute}},
+    {"Rise 1-2 hrs, then bake 35 min.", []time.Duration{2 * time.Hour, 35 * time.Minute}},
+    {"Roll into a 2 m rope.", []time.Duration{}},
+    {"Chill 1 h.", []time.Duration{}},
+  } {
+    if got := DetectDurations(test.text); !reflect.DeepEqual(got, test.want) {
+      t.Errorf("%q: got %v, want %v", test.text, got, test.want)
+    }
+  }
+}
+
+func TestTimingSummaryOption(t *testing.T) {
+  recipe := Recipe{
+    Title: "Bread",
+    IngredientLines: []string{"500 g flour"},
+    InstructionLines: []string{"For the dough:", "Knead 10 minutes.", "Bake:", "Bake 35 min."},
+  }
+  plain, _ := RecipeMDRenderer{}.Render(recipe)
+  if strings.Contains(string(plain), "·") {
+    t.Errorf("summarized without TimingSummary:\n%s", plain)
+  }
+
+  summarized, _ := RecipeMDRenderer{Options: FormatOptions{TimingSummary: true}}.Render(recipe)
+  if !strings.Contains(string(summarized), "Dough 10 min · Bake 35 min") {
+    t.Errorf("no summary:\n%s", summarized)
+  }
+  parsed, err := ParseRecipeMD(bytes.NewReader(summarized))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(parsed.InstructionLines, recipe.InstructionLines) {
+    t.Errorf("read back %q", parsed.InstructionLines)
+  }
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+  "archive/zip"
+  "bytes"
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "io/fs"
+  "log"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// The serve command puts the converter behind a small web page, for people
+// who would rather upload their export than install anything. Uploads are
+// converted in a temporary folder and sent back as a zip, or converted in
+// memory when the folder can't be written, see downloads.go.
+
+const uploadForm = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Recipe Keeper to RecipeMD</title></head>
+<body>
+<h1>Recipe Keeper to RecipeMD</h1>
+<p>Upload the recipes.html from your Recipe Keeper export, or the whole export as a zip to keep the photos.</p>
+<form method="post" action="/convert" enctype="multipart/form-data">
+<input type="file" name="export" accept=".html,.zip" required>
+<button type="submit">Convert</button>
+</form>
+</body>
+</html>
+`
+
+func runServe(args []string) error {
+  flags := flag.NewFlagSet("serve", flag.ExitOnError)
+  addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+  maxUpload := flags.Int64("max-upload", 512<<20, "largest export accepted, in bytes")
+  maxUnpacked := flags.Int64("max-unpacked", 4<<30, "largest a zipped export may unpack to, in bytes")
+  flags.Parse(args)
+
+  downloads := newDownloadStore()
+  mux := http.NewServeMux()
+  mux.Handle("/download/", downloads)
+  mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/" {
+      http.NotFound(w, r)
+      return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    io.WriteString(w, uploadForm)
+  })
+  mux.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+      w.Header().Set("Allow", http.MethodPost)
+      http.Error(w, "POST an export to convert it", http.StatusMethodNotAllowed)
+      return
+    }
+    r.Body = http.MaxBytesReader(w, r.Body, *maxUpload)
+    if err := handleConversion(w, r, *maxUnpacked, downloads); err != nil {
+      log.Printf("convert: %s", err)
+      http.Error(w, err.Error(), http.StatusBadRequest)
+    }
+  })
+
+  log.Printf("listening on http://%s", *addr)
+  return http.ListenAndServe(*addr, mux)
+}
+
+// handleConversion converts an uploaded export, a zipped one only if it
+// unpacks to no more than maxUnpacked bytes.
+func handleConversion(w http.ResponseWriter, r *http.Request, maxUnpacked int64, downloads *downloadStore) error {
+  // a bare POST of the file works too, e.g. curl --data-binary @recipes.html
+  var upload io.ReadCloser = r.Body
+  name := "recipes.zip"
+  if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+    file, header, err := r.FormFile("export")
+    if err != nil {
+      return err
+    }
+    upload = file
+    name = strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename)) + "-recipemd.zip"
+  }
+  defer upload.Close()
+
+  work, err := os.MkdirTemp("", "recipekeeper2recipemd-")
+  if err == nil {
+    defer os.RemoveAll(work)
+    err = CheckWritable(work)
+  }
+  if err != nil {
+    log.Printf("converting in memory: %s", err)
+    return handleInMemory(w, upload, maxUnpacked, downloads)
+  }
+
+  exportDir := filepath.Join(work, "export")
+  if err := os.MkdirAll(exportDir, 0755); err != nil {
+    return err
+  }
+  uploaded := filepath.Join(work, "upload")
+  if err := saveUpload(uploaded, upload); err != nil {
+    return err
+  }
+
+  exportPath := filepath.Join(exportDir, "recipes.html")
+  if isZipFile(uploaded) {
+    if exportPath, err = extractExport(uploaded, exportDir, maxUnpacked); err != nil {
+      return err
+    }
+  } else if err := os.Rename(uploaded, exportPath); err != nil {
+    return err
+  }
+
+  report, err := convertUpload(exportPath, filepath.Join(work, "recipes"))
+  if err != nil {
+    return err
+  }
+
+  var archive bytes.Buffer
+  if err := zipOutput(&archive, filepath.Join(work, "recipes"), report); err != nil {
+    return err
+  }
+
+  w.Header().Set("Content-Type", "application/zip")
+  w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+  _, err = w.Write(archive.Bytes())
+  return err
+}
+
+func saveUpload(path string, upload io.Reader) error {
+  file, err := os.Create(path)
+  if err != nil {
+    return err
+  }
+  if _, err := io.Copy(file, upload); err != nil {
+    file.Close()
+    return err
+  }
+  return file.Close()
+}
+
+func isZipFile(path string) bool {
+  file, err := os.Open(path)
+  if err != nil {
+    return false
+  }
+  defer file.Close()
+
+  magic := make([]byte, 4)
+  _, err = io.ReadFull(file, magic)
+  return err == nil && string(magic) == "PK\x03\x04"
+}
+
+// extractExport unpacks a zipped export and returns the path of its
+// recipes.html. With a limit, unpacking more than limit bytes in all fails,
+// so a zip bomb can't fill the disk.
+func extractExport(archive string, dir string, limit int64) (string, error) {
+  reader, err := zip.OpenReader(archive)
+  if err != nil {
+    return "", err
+  }
+  defer reader.Close()
+
+  exportPath := ""
+  remaining := limit
+  for _, entry := range reader.File {
+    target, err := confinedPath(dir, entry.Name)
+    if err != nil || target == filepath.Clean(dir) {
+      return "", fmt.Errorf("zip entry %q points outside the export", entry.Name)
+    }
+    if entry.FileInfo().IsDir() {
+      continue
+    }
+    if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+      return "", err
+    }
+    written, err := extractZipEntry(entry, target, remaining, limit > 0)
+    if err != nil {
+      return "", err
+    }
+    remaining -= written
+    if limit > 0 && remaining < 0 {
+      return "", fmt.Errorf("the zip unpacks to more than %d bytes", limit)
+    }
+    if strings.EqualFold(filepath.Base(target), "recipes.html") && (exportPath == "" || len(target) < len(exportPath)) {
+      exportPath = target
+    }
+  }
+
+  if exportPath == "" {
+    return "", errors.New("the zip has no recipes.html")
+  }
+  return exportPath, nil
+}
+
+// extractZipEntry writes an entry to target, reading at most one byte past
+// remaining when limited, which is enough to tell it was too large.
+func extractZipEntry(entry *zip.File, target string, remaining int64, limited bool) (int64, error) {
+  source, err := entry.Open()
+  if err != nil {
+    return 0, err
+  }
+  defer source.Close()
+
+  var reader io.Reader = source
+  if limited {
+    reader = io.LimitReader(source, remaining+1)
+  }
+  file, err := os.Create(target)
+  if err != nil {
+    return 0, err
+  }
+  written, err := io.Copy(file, reader)
+  if err != nil {
+    file.Close()
+    return written, err
+  }
+  return written, file.Close()
+}
+
+// convertUpload converts with the default options and returns a report of the
+// warnings and skipped recipes, empty if there were none.
+func convertUpload(exportPath string, output string) (string, error) {
+  file, err := os.Open(exportPath)
+  if err != nil {
+    return "", err
+  }
+  defer file.Close()
+
+  converter := NewConverter()
+  converter.OutputDir = output
+  converter.ExportDir = filepath.Dir(exportPath)
+
+  report := reportProblems(converter)
+  err = converter.Convert(file)
+  return report(), err
+}
+
+// reportProblems collects the warnings and skipped recipes of a conversion
+// until the returned function is called, which gives them as a report.
+func reportProblems(converter *Converter) func() string {
+  progress := make(chan ProgressEvent)
+  converter.Progress = progress
+  var report strings.Builder
+  done := make(chan struct{})
+  go func() {
+    defer close(done)
+    for event := range progress {
+      if event.Kind == ConversionWarning || event.Kind == RecipeSkipped || event.Kind == RecipeFailed || event.Kind == IncompleteRecipe {
+        fmt.Fprintf(&report, "%s: %s (%s): %s\n", event.Kind, event.Title, event.UUID, event.Message)
+      }
+    }
+  }()
+  return func() string {
+    close(progress)
+    <-done
+    return report.String()
+  }
+}
+
+// handleInMemory converts an upload without writing anything to disk, and
+// answers with a page of download links to the files.
+func handleInMemory(w http.ResponseWriter, upload io.Reader, maxUnpacked int64, downloads *downloadStore) error {
+  content, err := io.ReadAll(upload)
+  if err != nil {
+    return err
+  }
+  if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+    if content, err = exportFromZip(content, maxUnpacked); err != nil {
+      return err
+    }
+  }
+
+  converter := NewConverter()
+  converter.OutputDir = ""
+  report := reportProblems(converter)
+  files, err := converter.convertInMemory(context.Background(), bytes.NewReader(content))
+  if problems := report(); problems != "" && err == nil {
+    files["conversion-report.txt"] = []byte(problems)
+  }
+  if err != nil {
+    return err
+  }
+
+  token, err := downloads.add(files)
+  if err != nil {
+    return err
+  }
+  w.Header().Set("Content-Type", "text/html; charset=utf-8")
+  _, err = io.WriteString(w, downloadPage(token, files))
+  return err
+}
+
+// zipOutput packs the converted files, leaving out the bookkeeping that only
+// matters for reconverting in place.
+func zipOutput(w io.Writer, dir string, report string) error {
+  archive := zip.NewWriter(w)
+
+  err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    if d.IsDir() {
+      if d.Name() == baseStoreDir {
+        return filepath.SkipDir
+      }
+      return nil
+    }
+    if d.Name() == manifestName {
+      return nil
+    }
+
+    relative, err := filepath.Rel(dir, path)
+    if err != nil {
+      return err
+    }
+    entry, err := archive.Create(filepath.ToSlash(relative))
+    if err != nil {
+      return err
+    }
+    content, err := os.ReadFile(path)
+    if err != nil {
+      return err
+    }
+    _, err = entry.Write(content)
+    return err
+  })
+  if err != nil {
+    return err
+  }
+
+  if report != "" {
+    entry, err := archive.Create("conversion-report.txt")
+    if err != nil {
+      return err
+    }
+    if _, err := io.WriteString(entry, report); err != nil {
+      return err
+    }
+  }
+
+  return archive.Close()
+}
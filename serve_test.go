@@ -0,0 +1,69 @@
+package main
+
+import (
+  "archive/zip"
+  "io"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "regexp"
+  "strings"
+  "testing"
+)
+
+const servedExport = `<html><head><script type="application/ld+json">
+{"@context": "https://schema.org", "@type": "Recipe", "name": "Pancakes", "recipeIngredient": ["1 cup milk", "1 egg"], "recipeInstructions": "Mix and fry."}
+</script></head><body></body></html>`
+
+func TestServeInMemoryWhenUnwritable(t *testing.T) {
+  // a temporary folder inside a file can't be made, even as root
+  file := filepath.Join(t.TempDir(), "file")
+  if err := os.WriteFile(file, nil, 0644); err != nil {
+    t.Fatal(err)
+  }
+  t.Setenv("TMPDIR", filepath.Join(file, "tmp"))
+
+  downloads := newDownloadStore()
+  recorder := httptest.NewRecorder()
+  request := httptest.NewRequest("POST", "/convert", strings.NewReader(servedExport))
+  if err := handleConversion(recorder, request, 1<<20, downloads); err != nil {
+    t.Fatal(err)
+  }
+  page := recorder.Body.String()
+  links := regexp.MustCompile(`href="(/download/[^"]+\.md)"`).FindStringSubmatch(page)
+  if links == nil {
+    t.Fatalf("no download link in\n%s", page)
+  }
+
+  download := httptest.NewRecorder()
+  downloads.ServeHTTP(download, httptest.NewRequest("GET", links[1], nil))
+  content, _ := io.ReadAll(download.Body)
+  if !strings.Contains(string(content), "# Pancakes") || !strings.Contains(string(content), "milk") {
+    t.Errorf("downloaded %s:\n%s", links[1], content)
+  }
+}
+
+func TestExtractExportUnpackLimit(t *testing.T) {
+  tmp := t.TempDir()
+  archive := filepath.Join(tmp, "export.zip")
+  file, err := os.Create(archive)
+  if err != nil {
+    t.Fatal(err)
+  }
+  writer := zip.NewWriter(file)
+  entry, err := writer.Create("export/recipes.html")
+  if err != nil {
+    t.Fatal(err)
+  }
+  // 2 MB of HTML that zips to a few KB, well under an upload limit
+  entry.Write([]byte(strings.Repeat("<p>recipe</p>\n", 150000)))
+  writer.Close()
+  file.Close()
+
+  if _, err := extractExport(archive, filepath.Join(tmp, "small"), 1<<20); err == nil {
+    t.Error("unpacked 2 MB with a 1 MB limit")
+  }
+  if _, err := extractExport(archive, filepath.Join(tmp, "large"), 4<<20); err != nil {
+    t.Errorf("unpacking 2 MB with a 4 MB limit: %s", err)
+  }
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// ShoppingItem is the total of one ingredient over a set of recipes. Amounts
+// in compatible units are added up in the unit seen first, e.g. 1 cup and 4
+// tbsp of milk make 1 1/4 cup.
+type ShoppingItem struct {
+  Name string
+  Amount float64
+  // AmountMax is the high end when ranges were added up, 0 otherwise
+  AmountMax float64
+  AmountText string
+  Unit *Unit
+  UnitText string
+  // Qualifier is "optional", "to taste" or "for garnish" for ingredients
+  // that needn't be bought, see Ingredient.Qualifier. They are added up
+  // apart from the others and listed after them.
+  Qualifier string
+  // Lines that couldn't be added up, kept as written
+  Raw string
+}
+
+// formatAmount writes a total as a fraction, unless the amount was given as
+// a decimal or in metric units.
+func (item ShoppingItem) formatAmount(value float64) string {
+  if strings.ContainsAny(item.AmountText, ".,") || (item.Unit != nil && metricUnits[item.Unit.Name]) {
+    return formatAmount(value, item.AmountText)
+  }
+  return formatFraction(value)
+}
+
+func (item ShoppingItem) String() string {
+  amount := item.formatAmount(item.Amount)
+  if item.AmountMax > item.Amount {
+    amount += "-" + item.formatAmount(item.AmountMax)
+  }
+  switch {
+  case item.Raw != "":
+    return item.Raw
+  }
+  text := item.Name
+  switch {
+  case item.AmountText == "":
+  case item.UnitText == "":
+    text = amount + " " + item.Name
+  default:
+    text = amount + " " + item.UnitText + " " + item.Name
+  }
+  if item.Qualifier != "" {
+    text += " (" + item.Qualifier + ")"
+  }
+  return text
+}
+
+func shoppingKey(ingredient Ingredient, unit *Unit) string {
+  name := strings.ToLower(ingredient.Name)
+  if qualifier := ingredient.Qualifier(); qualifier != "" {
+    name += "|" + qualifier
+  }
+  switch {
+  case ingredient.AmountText == "":
+    return name
+  case unit == nil:
+    return name + "|"
+  case unit.Kind == UnitCount:
+    return name + "|" + unit.Name
+  }
+  return fmt.Sprintf("%s|%d", name, unit.Kind)
+}
+
+// ShoppingList adds up the ingredients of the recipes.
+func ShoppingList(recipes []Recipe) []ShoppingItem {
+  items := make([]ShoppingItem, 0)
+  byKey := map[string]int{}
+
+  for _, recipe := range recipes {
+    for _, ingredient := range recipe.Ingredients {
+      if ingredient.Confidence < minRewriteConfidence {
+        items = append(items, ShoppingItem{Raw: ingredient.Raw, Qualifier: ingredient.Qualifier()})
+        continue
+      }
+
+      unit := LookupUnit(ingredient.UnitText)
+      key := shoppingKey(ingredient, unit)
+      high := ingredient.Amount
+      if ingredient.AmountMax > 0 {
+        high = ingredient.AmountMax
+      }
+      i, exists := byKey[key]
+      if !exists {
+        byKey[key] = len(items)
+        items = append(items, ShoppingItem{
+          Name: ingredient.Name,
+          Amount: ingredient.Amount,
+          AmountMax: ingredient.AmountMax,
+          AmountText: ingredient.AmountText,
+          Unit: unit,
+          UnitText: ingredient.UnitText,
+          Qualifier: ingredient.Qualifier(),
+        })
+        continue
+      }
+
+      factor := 1.0
+      if unit != nil && unit.Factor > 0 && items[i].Unit != nil {
+        factor = unit.Factor / items[i].Unit.Factor
+      }
+      if items[i].AmountMax > 0 || ingredient.AmountMax > 0 {
+        if items[i].AmountMax == 0 {
+          items[i].AmountMax = items[i].Amount
+        }
+        items[i].AmountMax += high * factor
+      }
+      items[i].Amount += ingredient.Amount * factor
+    }
+  }
+  return items
+}
+
+// FormatShoppingList writes the list as checkboxes, the optional, to taste
+// and garnish ingredients last under a heading of their own.
+func FormatShoppingList(items []ShoppingItem) string {
+  var output strings.Builder
+  output.WriteString("# Shopping list\n\n")
+  extras := make([]ShoppingItem, 0)
+  for _, item := range items {
+    if item.Qualifier != "" {
+      extras = append(extras, item)
+      continue
+    }
+    output.WriteString("- [ ] " + item.String() + "\n")
+  }
+  if len(extras) > 0 {
+    output.WriteString("\n## If needed\n\n")
+    for _, item := range extras {
+      output.WriteString("- [ ] " + item.String() + "\n")
+    }
+  }
+  return output.String()
+}
+
+// RequiredItems leaves out the optional, to taste and garnish ingredients.
+func RequiredItems(items []ShoppingItem) []ShoppingItem {
+  required := make([]ShoppingItem, 0, len(items))
+  for _, item := range items {
+    if item.Qualifier == "" {
+      required = append(required, item)
+    }
+  }
+  return required
+}
+
+// selectRecipes reads the converted recipes in dir picked by file, title or
+// tag. A recipe picked more than one way is only added once.
+func selectRecipes(dir string, picks []string, tag string) ([]Recipe, error) {
+  selected := make([]Recipe, 0)
+  found := map[string]bool{}
+  // the absolute paths of the files added
+  added := map[string]bool{}
+  add := func(path string, recipe Recipe) {
+    if absolute, err := filepath.Abs(path); err == nil {
+      path = absolute
+    }
+    if !added[path] {
+      added[path] = true
+      selected = append(selected, recipe)
+    }
+  }
+
+  for _, pick := range picks {
+    if info, err := os.Stat(pick); err == nil && !info.IsDir() {
+      recipe, err := ParseRecipeMDFile(pick, nil)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", pick, err)
+      }
+      add(pick, recipe)
+      found[pick] = true
+    }
+  }
+
+  err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    if d.IsDir() || filepath.Ext(path) != ".md" || d.Name() == indexFile {
+      return nil
+    }
+    recipe, err := ParseRecipeMDFile(path, nil)
+    if err != nil {
+      return nil
+    }
+
+    picked := false
+    for _, pick := range picks {
+      if !found[pick] && strings.EqualFold(strings.TrimSpace(pick), recipe.Title) {
+        found[pick] = true
+        picked = true
+      }
+    }
+    if tag != "" {
+      for _, name := range recipe.tags(CharsetUnicode) {
+        picked = picked || name == tagName(tag)
+      }
+    }
+    if picked {
+      add(path, recipe)
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+
+  for _, pick := range picks {
+    if !found[pick] {
+      return nil, fmt.Errorf("no recipe titled %q in %s", pick, dir)
+    }
+  }
+  return selected, nil
+}
+
+func runShoppingList(args []string) error {
+  flags := flag.NewFlagSet("shopping-list", flag.ExitOnError)
+  dir := flags.String("dir", outputDir, "folder of converted recipes to pick from")
+  tag := flags.String("tag", "", "add every recipe with this tag")
+  output := flags.String("o", "", "write the list to this file instead of stdout")
+  requiredOnly := flags.Bool("required", false, "leave out optional, to taste and garnish ingredients")
+  flags.Parse(args)
+
+  if flags.NArg() == 0 && *tag == "" {
+    return errors.New("usage: recipekeeper2recipemd shopping-list [-tag tag] [title or file]...")
+  }
+
+  recipes, err := selectRecipes(*dir, flags.Args(), *tag)
+  if err != nil {
+    return err
+  }
+  if len(recipes) == 0 {
+    return fmt.Errorf("no recipes tagged %q in %s", *tag, *dir)
+  }
+
+  var writer io.Writer = os.Stdout
+  if *output != "" {
+    file, err := os.Create(*output)
+    if err != nil {
+      return err
+    }
+    defer file.Close()
+    writer = file
+  }
+  items := ShoppingList(recipes)
+  if *requiredOnly {
+    items = RequiredItems(items)
+  }
+  _, err = io.WriteString(writer, FormatShoppingList(items))
+  return err
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestShoppingListTotals(t *testing.T) {
+  recipes := []Recipe{
+    {Ingredients: ParseIngredients([]string{"1 cup milk", "200 g flour", "1.5 cups stock"})},
+    {Ingredients: ParseIngredients([]string{"4 tbsp milk", "50 g flour", "1 cup stock"})},
+  }
+  want := []string{"1 1/4 cup milk", "250 g flour", "2.5 cups stock"}
+  items := ShoppingList(recipes)
+  if len(items) != len(want) {
+    t.Fatalf("got %d items, want %d", len(items), len(want))
+  }
+  for i, item := range items {
+    if item.String() != want[i] {
+      t.Errorf("item %d is %q, want %q", i, item, want[i])
+    }
+  }
+}
+
+func TestSelectRecipesOnce(t *testing.T) {
+  dir := t.TempDir()
+  path := filepath.Join(dir, "pancakes.md")
+  content := "# Pancakes\n\n*breakfast*\n\n---\n\n- *1 cup* milk\n\n---\n\nMix.\n"
+  if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+    t.Fatal(err)
+  }
+
+  recipes, err := selectRecipes(dir, []string{path, "Pancakes"}, "breakfast")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(recipes) != 1 {
+    t.Errorf("picked by file, title and tag, the recipe was added %d times", len(recipes))
+  }
+}
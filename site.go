@@ -0,0 +1,164 @@
+package main
+
+import (
+  "fmt"
+  "path"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Static site generators want the recipes as content files with their own
+// front matter, one folder per section (here the recipe's first course), and
+// the photos somewhere they get published as is.
+
+func isSiteLayout(layout string) bool {
+  return layout == LayoutHugo || layout == LayoutJekyll
+}
+
+func siteSection(r Recipe, charset Charset) string {
+  for _, course := range r.Metadata.CourseList {
+    if section := tagName(charset.Apply(strings.ReplaceAll(course, "/", "-"))); section != "" {
+      return section
+    }
+  }
+  return "recipes"
+}
+
+func siteRecipeDir(layout string, r Recipe, charset Charset) string {
+  if layout == LayoutHugo {
+    return filepath.Join("content", siteSection(r, charset))
+  }
+  return filepath.Join("_recipes", siteSection(r, charset))
+}
+
+// siteImagesDir is where photos go so the generator copies them to the site.
+func siteImagesDir(layout string) string {
+  if layout == LayoutHugo {
+    return filepath.Join("static", imagesDir)
+  }
+  return filepath.Join("assets", imagesDir)
+}
+
+// siteURL turns a path below the output directory into its URL on the site.
+func siteURL(layout string, stored string) string {
+  stored = filepath.ToSlash(stored)
+  if layout == LayoutHugo {
+    stored = strings.TrimPrefix(stored, "static/")
+  }
+  return "/" + stored
+}
+
+// siteSlugs picks a file name for every recipe based on its title, adding the
+// start of the UUID where titles clash.
+func siteSlugs(recipes []Recipe, charset Charset) map[string]string {
+  slug := func(r Recipe) string {
+    return tagName(charset.Apply(strings.ReplaceAll(r.Title, "/", "-")))
+  }
+
+  counts := map[string]int{}
+  for _, recipe := range recipes {
+    counts[slug(recipe)]++
+  }
+
+  slugs := make(map[string]string, len(recipes))
+  for _, recipe := range recipes {
+    slug := slug(recipe)
+    if slug == "" || counts[slug] > 1 {
+      id := recipe.Metadata.UUID
+      if len(id) > 8 {
+        id = id[:8]
+      }
+      slug = strings.Trim(slug+"-"+id, "-")
+    }
+    slugs[recipe.Metadata.UUID] = slug
+  }
+  return slugs
+}
+
+// Recipes that are missing ingredients or steps are published as drafts.
+func isDraft(r Recipe) bool {
+  return len(r.IngredientLines) == 0 || len(r.InstructionLines) == 0
+}
+
+// SiteRenderer writes RecipeMD with the front matter of a Hugo or Jekyll
+// content file on top.
+type SiteRenderer struct {
+  Layout string
+  // Date is used as the date of the recipes the export has no date for.
+  Date time.Time
+  Options FormatOptions
+}
+
+func (s SiteRenderer) Render(r Recipe) ([]byte, error) {
+  options := s.Options
+  options.Frontmatter = false
+  body, err := FormatRecipeMD(r, options)
+  if err != nil {
+    return nil, err
+  }
+  return []byte(s.frontMatter(r) + body), nil
+}
+
+func (SiteRenderer) Ext() string {
+  return "md"
+}
+
+func (s SiteRenderer) frontMatter(r Recipe) string {
+  var fields [][2]string
+  add := func(key string, value string) {
+    fields = append(fields, [2]string{key, value})
+  }
+
+  if s.Layout == LayoutJekyll {
+    add("layout", strconv.Quote("recipe"))
+  }
+  add("title", strconv.Quote(r.Title))
+  date := s.Date.Format(time.RFC3339)
+  if !r.Metadata.Created.IsZero() {
+    date = formatExportDate(r.Metadata.Created)
+  }
+  add("date", date)
+  if !r.Metadata.Modified.IsZero() {
+    add("lastmod", formatExportDate(r.Metadata.Modified))
+  }
+  if isDraft(r) {
+    if s.Layout == LayoutHugo {
+      add("draft", "true")
+    } else {
+      add("published", "false")
+    }
+  }
+  if r.Description != "" {
+    add("description", strconv.Quote(strings.ReplaceAll(r.Description, "\n\n", " ")))
+  }
+  if r.Metadata.Favorited {
+    add("favorite", "true")
+  }
+  if tags := r.tags(s.Options.TagCharset); len(tags) > 0 {
+    add("tags", yamlList(tags))
+  }
+  if len(r.Metadata.CourseList) > 0 {
+    add("categories", yamlList(r.Metadata.CourseList))
+  }
+  for _, photo := range r.PhotoPaths {
+    if path.IsAbs(photo) || isRemotePhoto(photo) {
+      add("image", strconv.Quote(photo))
+      break
+    }
+  }
+
+  delimiter, separator := "---", ": "
+  if s.Layout == LayoutHugo {
+    delimiter, separator = "+++", " = "
+  }
+
+  var output strings.Builder
+  output.WriteString(delimiter + "\n")
+  for _, field := range fields {
+    output.WriteString(fmt.Sprintf("%s%s%s\n", field[0], separator, field[1]))
+  }
+  output.WriteString(delimiter + "\n\n")
+  return output.String()
+}
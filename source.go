@@ -0,0 +1,159 @@
+package main
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "io"
+
+  "github.com/PuerkitoBio/goquery"
+)
+
+// A Source reads recipes in one input format. Inputs are given to the first
+// source whose Detect recognizes them, so new formats (Paprika, Mela, ...)
+// only need a Source of their own, in their own file, and a place in
+// inputSources.
+type Source interface {
+  // Detect sniffs the start of the input, see sniff.
+  Detect(r io.Reader) bool
+  Parse(r io.Reader) ([]Recipe, error)
+}
+
+// A VersionedSource also tells which generation of its format it read, for
+// formats that changed over time like Recipe Keeper's HTML.
+type VersionedSource interface {
+  Source
+  ParseVersion(r io.Reader) ([]Recipe, ExportVersion, error)
+}
+
+// A StreamingSource hands over its recipes one at a time as it reads them,
+// for formats big enough that holding the whole input parsed is a problem.
+type StreamingSource interface {
+  Source
+  ParseEach(r io.Reader, fn func(Recipe) error) (ExportVersion, error)
+}
+
+// InputSource is a registered Source. Version is what ExportDetected reports
+// for it, unless it is a VersionedSource.
+type InputSource struct {
+  Name string
+  Version ExportVersion
+  Source Source
+}
+
+// inputSources are tried in order, the most particular formats first: a
+// Recipe Keeper export has schema.org microdata in it too.
+var inputSources = []InputSource{
+  {"recipekeeper", ExportUnknown, RecipeKeeperHTML{}},
+  {"json-ld", ExportJSONLD, JSONLDSource{}},
+  {"microdata", ExportMicrodata, MicrodataSource{}},
+  {"csv", ExportCSV, CSVSource{}},
+}
+
+// RegisterSource adds an input format, tried after the others.
+func RegisterSource(name string, version ExportVersion, source Source) {
+  inputSources = append(inputSources, InputSource{name, version, source})
+}
+
+// sniffSize is how much of the input Detect gets to see.
+const sniffSize = 1 << 20
+
+func sniff(r io.Reader) []byte {
+  head, _ := io.ReadAll(io.LimitReader(r, sniffSize))
+  return head
+}
+
+// each hands the recipes of r to fn, one at a time if the source can.
+func (s InputSource) each(r io.Reader, fn func(Recipe) error) (ExportVersion, error) {
+  if streaming, ok := s.Source.(StreamingSource); ok {
+    return streaming.ParseEach(r, fn)
+  }
+  recipes, version, err := s.parse(r)
+  if err != nil {
+    return version, err
+  }
+  for _, recipe := range recipes {
+    if err := fn(recipe); err != nil {
+      return version, err
+    }
+  }
+  return version, nil
+}
+
+func (s InputSource) parse(r io.Reader) ([]Recipe, ExportVersion, error) {
+  if versioned, ok := s.Source.(VersionedSource); ok {
+    return versioned.ParseVersion(r)
+  }
+  recipes, err := s.Source.Parse(r)
+  return recipes, s.Version, err
+}
+
+// ScrapeExport reads the recipes of an input in any of the inputSources,
+// also returning which format, or generation of the export format, it found.
+//
+// Files and other inputs that can seek are read again by each source that
+// tries them instead of being held in memory whole, and sources that stream
+// never parse more than a recipe at a time.
+func ScrapeExport(reader io.Reader) ([]Recipe, ExportVersion, error) {
+  var start int64
+  input, ok := reader.(io.ReadSeeker)
+  if ok {
+    // stdin is a file too, but a pipe can't seek
+    var err error
+    start, err = input.Seek(0, io.SeekCurrent)
+    ok = err == nil
+  }
+  if !ok {
+    content, err := io.ReadAll(reader)
+    if err != nil {
+      return nil, ExportUnknown, err
+    }
+    input, start = bytes.NewReader(content), 0
+  }
+  rewind := func() error {
+    _, err := input.Seek(start, io.SeekStart)
+    return err
+  }
+  head := sniff(input)
+  // a zipped export passed where recipes.html was expected
+  if bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+    return nil, ExportUnknown, fmt.Errorf("%w: this is a zip file, pass it as a .zip or unpack it and use the recipes.html inside", ErrNoRecipes)
+  }
+
+  var firstErr error
+  for _, source := range inputSources {
+    if !source.Source.Detect(bytes.NewReader(head)) {
+      continue
+    }
+    if err := rewind(); err != nil {
+      return nil, ExportUnknown, err
+    }
+    recipes := make([]Recipe, 0)
+    version, err := source.each(input, func(recipe Recipe) error {
+      recipes = append(recipes, recipe)
+      return nil
+    })
+    if err == nil && len(recipes) > 0 {
+      return recipes, version, nil
+    }
+    if err != nil && firstErr == nil {
+      firstErr = err
+    }
+  }
+
+  if firstErr != nil {
+    if !errors.Is(firstErr, ErrNoRecipes) {
+      firstErr = fmt.Errorf("%w: %s", ErrNoRecipes, firstErr)
+    }
+    return nil, ExportUnknown, firstErr
+  }
+  // nothing recognized it, say why if it is HTML at all
+  if err := rewind(); err != nil {
+    return nil, ExportUnknown, err
+  }
+  doc, err := goquery.NewDocumentFromReader(input)
+  if err != nil {
+    return nil, ExportUnknown, err
+  }
+  return nil, DetectExportVersion(doc), diagnoseEmptyExport(doc)
+}
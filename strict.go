@@ -0,0 +1,99 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+  "time"
+)
+
+// formatStrictRecipeMD writes only the structure the RecipeMD spec allows:
+// title, description, tags, yields, ingredients and instructions. The
+// metadata lines and notes become part of the description, ingredient groups
+// become headings, and the photos, nutrition and section headings are left
+// out. Front matter, #tags and amount markup are never written.
+func (r Recipe) formatStrictRecipeMD(options FormatOptions) string {
+  options.AmountMarkup = false
+  var output strings.Builder
+  output.WriteString(fmt.Sprintf("# %s\n", r.Title))
+  output.WriteString(r.sourceComment())
+
+  paragraphs := make([]string, 0)
+  if options.HeroImage && len(r.PhotoPaths) > 0 {
+    paragraphs = append(paragraphs, fmt.Sprintf("![%s](%s)", r.Title, r.PhotoPaths[0]))
+  }
+  if r.Description != "" {
+    paragraphs = append(paragraphs, r.Description)
+  }
+  if len(r.MakeAhead) > 0 {
+    paragraphs = append(paragraphs, r.formatMakeAhead(options))
+  }
+  if len(r.NotesLines) > 0 {
+    paragraphs = append(paragraphs, strings.Join(r.NotesLines, "\n"))
+  }
+
+  metadata := make([]string, 0)
+  addMetadata := func(label string, value string) {
+    if value != "" {
+      metadata = append(metadata, fmt.Sprintf("%s: %s", options.Labels.get(label), value))
+    }
+  }
+  addDuration := func(label string, duration time.Duration) {
+    if duration > 0 {
+      addMetadata(label, duration.String())
+    }
+  }
+  if rating := options.rating(r.Metadata.Rating); rating != "" {
+    addMetadata("Rating", rating)
+  }
+  addMetadata("Collections", strings.Join(r.Metadata.CollectionList, ", "))
+  addMetadata("Course", strings.Join(r.Metadata.CourseList, ", "))
+  if r.Metadata.Source != "" {
+    addMetadata("Source", FormatSource(r.Metadata.Source))
+  }
+  addDuration("Cook Time", r.Metadata.CookTime)
+  addDuration("Prep Time", r.Metadata.PrepTime)
+  addDuration("Rest Time", r.Metadata.RestTime)
+  addDuration("Total Time", r.Metadata.TotalTime)
+  if len(metadata) > 0 {
+    paragraphs = append(paragraphs, strings.Join(metadata, "\n"))
+  }
+
+  if len(r.Metadata.CategoryList) > 0 {
+    paragraphs = append(paragraphs, fmt.Sprintf("*%s*", strings.Join(r.Metadata.CategoryList, ", ")))
+  }
+  if r.Metadata.Yield != "" {
+    paragraphs = append(paragraphs, fmt.Sprintf("**%s**", r.Metadata.Yield))
+  }
+  for _, paragraph := range paragraphs {
+    output.WriteString("\n" + paragraph + "\n")
+  }
+
+  output.WriteString("\n---\n")
+
+  linker := newRecipeLinker(r.Links, "md")
+  list := false
+  for _, line := range r.IngredientLines {
+    if IsSectionHeading(line) {
+      output.WriteString("\n" + options.heading(SectionTitle(line)) + "\n")
+      list = false
+      continue
+    }
+    if !list {
+      output.WriteString("\n")
+      list = true
+    }
+    output.WriteString(fmt.Sprintf("- %s\n", linker.Linkify(options.ingredient(line))))
+  }
+
+  output.WriteString("\n---\n\n")
+
+  instructions := linker.LinkifyAll(r.InstructionLines)
+  highlighter := options.highlighter(r)
+  for i, line := range instructions {
+    instructions[i] = options.fractions(highlighter.Highlight(line))
+  }
+  output.WriteString(options.formatSteps(instructions))
+  output.WriteString("\n")
+
+  return output.String()
+}
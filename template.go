@@ -0,0 +1,79 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "text/template"
+)
+
+// TemplateRenderer renders recipes with a user supplied text/template, which
+// gets the Recipe as its data. The helpers below give templates the same
+// building blocks FormatAsRecipeMD uses, e.g.
+//
+//   # {{.Title}}
+//   {{range .IngredientLines}}- {{ingredient .}}
+//   {{end}}
+//   {{heading "Instructions"}}
+//   {{join (steps .InstructionLines) "\n\n"}}
+type TemplateRenderer struct {
+  Template *template.Template
+  Options FormatOptions
+  ext string
+}
+
+func templateFuncs(options FormatOptions) template.FuncMap {
+  return template.FuncMap{
+    "join": strings.Join,
+    "heading": options.heading,
+    "label": options.Labels.get,
+    "ingredient": options.ingredient,
+    "steps": options.steps,
+    "fractions": options.fractions,
+    "source": FormatSource,
+    "tags": func(r Recipe) []string {
+      return r.tags(options.TagCharset)
+    },
+    "frontmatter": func(r Recipe) string {
+      return r.formatFrontmatter(options)
+    },
+    // the whole recipe the default way, to wrap in something of your own
+    "recipemd": func(r Recipe) string {
+      return r.formatAsRecipeMD(options)
+    },
+  }
+}
+
+// LoadTemplateRenderer parses the template at path. The output files take
+// their extension from the template's name, "recipe.html.tmpl" writes .html
+// files, and are Markdown otherwise.
+func LoadTemplateRenderer(path string, options FormatOptions) (TemplateRenderer, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return TemplateRenderer{}, err
+  }
+  tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs(options)).Parse(string(content))
+  if err != nil {
+    return TemplateRenderer{}, fmt.Errorf("%s: %w", path, err)
+  }
+
+  ext := strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(filepath.Base(path), ".tmpl")), ".")
+  if ext == "" || ext == "tmpl" {
+    ext = "md"
+  }
+  return TemplateRenderer{Template: tmpl, Options: options, ext: ext}, nil
+}
+
+func (t TemplateRenderer) Render(r Recipe) ([]byte, error) {
+  var output bytes.Buffer
+  if err := t.Template.Execute(&output, r); err != nil {
+    return nil, err
+  }
+  return output.Bytes(), nil
+}
+
+func (t TemplateRenderer) Ext() string {
+  return t.ext
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+  "time"
+  "unicode/utf8"
+)
+
+// TextRenderer writes recipes as plain text without any markup, wrapped to
+// fit narrow receipt printers, text messages and the like. Photos are left
+// out.
+type TextRenderer struct {
+  Options FormatOptions
+  // Width is the longest a line may get, 0 to not wrap at all.
+  Width int
+}
+
+// wrapText breaks text into lines of at most width characters, between
+// words, starting the first with prefix and the others with as many spaces.
+// Words longer than a line are left whole.
+func wrapText(text string, width int, prefix string) string {
+  indent := strings.Repeat(" ", utf8.RuneCountInString(prefix))
+  words := strings.Fields(text)
+  if width <= 0 || len(words) == 0 {
+    return prefix + strings.Join(words, " ")
+  }
+  lines := make([]string, 0)
+  line := prefix + words[0]
+  for _, word := range words[1:] {
+    if utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+      lines = append(lines, line)
+      line = indent + word
+      continue
+    }
+    line += " " + word
+  }
+  return strings.Join(append(lines, line), "\n")
+}
+
+func (t TextRenderer) Render(r Recipe) ([]byte, error) {
+  options := t.Options
+  var output strings.Builder
+  paragraph := func(text string, prefix string) {
+    output.WriteString(wrapText(text, t.Width, prefix) + "\n")
+  }
+  section := func(title string) {
+    title = strings.ToUpper(options.Labels.get(title))
+    output.WriteString("\n" + title + "\n" + strings.Repeat("-", utf8.RuneCountInString(title)) + "\n\n")
+  }
+
+  output.WriteString(r.Title + "\n" + strings.Repeat("=", utf8.RuneCountInString(r.Title)) + "\n")
+  if r.Description != "" {
+    for _, text := range strings.Split(r.Description, "\n\n") {
+      output.WriteString("\n")
+      paragraph(text, "")
+    }
+  }
+
+  metadata := make([][2]string, 0)
+  add := func(label string, value string) {
+    if value != "" {
+      metadata = append(metadata, [2]string{options.Labels.get(label), value})
+    }
+  }
+  if r.Metadata.Rating > 0 {
+    add("Rating", options.rating(r.Metadata.Rating))
+  }
+  add("Course", strings.Join(r.Metadata.CourseList, ", "))
+  add("Collections", strings.Join(r.Metadata.CollectionList, ", "))
+  add("Yield", r.Metadata.Yield)
+  for _, duration := range []struct {
+    label string
+    value time.Duration
+  }{
+    {"Prep Time", r.Metadata.PrepTime},
+    {"Cook Time", r.Metadata.CookTime},
+    {"Rest Time", r.Metadata.RestTime},
+    {"Total Time", r.Metadata.TotalTime},
+  } {
+    if duration.value > 0 {
+      add(duration.label, FormatShortDuration(duration.value))
+    }
+  }
+  add("Source", r.Metadata.Source)
+  if len(metadata) > 0 {
+    output.WriteString("\n")
+    for _, item := range metadata {
+      paragraph(item[1], item[0]+": ")
+    }
+  }
+
+  if len(r.IngredientLines) > 0 {
+    section("Ingredients")
+    for i, line := range r.IngredientLines {
+      if IsSectionHeading(line) {
+        if i > 0 {
+          output.WriteString("\n")
+        }
+        output.WriteString(SectionTitle(line) + ":\n")
+        continue
+      }
+      paragraph(options.fractions(line), "- ")
+    }
+  }
+
+  if len(r.InstructionLines) > 0 {
+    section("Instructions")
+    step := 0
+    for i, line := range r.InstructionLines {
+      if i > 0 && !IsSectionHeading(r.InstructionLines[i-1]) {
+        output.WriteString("\n")
+      }
+      if IsSectionHeading(line) {
+        step = 0
+        output.WriteString(SectionTitle(line) + ":\n")
+        continue
+      }
+      step++
+      prefix := ""
+      switch options.StepsStyle {
+      case StepsNumbered:
+        prefix = fmt.Sprintf("%d. ", step)
+      case StepsBulleted:
+        prefix = "- "
+      }
+      paragraph(options.fractions(line), prefix)
+    }
+  }
+
+  if len(r.NotesLines) > 0 {
+    section("Notes")
+    for i, line := range r.NotesLines {
+      if i > 0 {
+        output.WriteString("\n")
+      }
+      paragraph(options.fractions(line), "")
+    }
+  }
+
+  nutrition := make([]NutritionValue, 0)
+  for _, value := range r.Nutrition.Values() {
+    if value.Value != "" {
+      nutrition = append(nutrition, value)
+    }
+  }
+  if len(nutrition) > 0 {
+    section("Nutrition")
+    for _, value := range nutrition {
+      text := value.Value
+      if options.NormalizeNutrition && value.Parsed {
+        text = value.Amount.String()
+      }
+      paragraph(text, options.Labels.get(value.Label)+": ")
+    }
+  }
+  return []byte(output.String()), nil
+}
+
+func (TextRenderer) Ext() string {
+  return "txt"
+}
+
+func init() {
+  RegisterFormat(OutputFormat{Name: "text", Exts: []string{"txt"}, New: func(s RenderSettings) (Renderer, CollectionRenderer, error) {
+    if s.TextWidth < 0 {
+      return nil, nil, fmt.Errorf("text width can't be negative, got %d", s.TextWidth)
+    }
+    return TextRenderer{Options: s.Options, Width: s.TextWidth}, nil, nil
+  }})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "regexp"
+)
+
+// A TransformRule is a regex find/replace run over selected fields of every
+// recipe before formatting, to fix systematic issues in the source data.
+// Replace may refer to groups as $1 or ${name}.
+type TransformRule struct {
+  Fields []string `json:"fields,omitempty"`
+  Find string `json:"find"`
+  Replace string `json:"replace"`
+
+  re *regexp.Regexp
+}
+
+var transformFields = []string{
+  "title", "description", "source", "yield", "categories", "courses",
+  "collections", "ingredients", "instructions", "notes",
+}
+
+func LoadTransformRules(path string) ([]TransformRule, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+
+  rules := make([]TransformRule, 0)
+  if err := json.Unmarshal(content, &rules); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+
+  for i := range rules {
+    if rules[i].re, err = regexp.Compile(rules[i].Find); err != nil {
+      return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+    }
+    for _, field := range rules[i].Fields {
+      if !contains(transformFields, field) {
+        return nil, fmt.Errorf("%s: rule %d: unknown field %q", path, i+1, field)
+      }
+    }
+  }
+
+  return rules, nil
+}
+
+func (rule TransformRule) appliesTo(field string) bool {
+  return len(rule.Fields) == 0 || contains(rule.Fields, field)
+}
+
+// editTextFields calls edit for each of the recipe's text fields by name.
+func editTextFields(r *Recipe, edit func(field string, text string) string) {
+  editList := func(field string, list []string) []string {
+    edited := make([]string, 0, len(list))
+    for _, text := range list {
+      if text = edit(field, text); text != "" {
+        edited = append(edited, text)
+      }
+    }
+    return edited
+  }
+
+  r.Title = edit("title", r.Title)
+  r.Description = edit("description", r.Description)
+  r.Metadata.Source = edit("source", r.Metadata.Source)
+  r.Metadata.Yield = edit("yield", r.Metadata.Yield)
+  r.Metadata.CategoryList = editList("categories", r.Metadata.CategoryList)
+  r.Metadata.CourseList = editList("courses", r.Metadata.CourseList)
+  r.Metadata.CollectionList = editList("collections", r.Metadata.CollectionList)
+  r.IngredientLines = editList("ingredients", r.IngredientLines)
+  r.InstructionLines = editList("instructions", r.InstructionLines)
+  r.NotesLines = editList("notes", r.NotesLines)
+}
+
+func ApplyTransforms(r Recipe, rules []TransformRule) Recipe {
+  if len(rules) == 0 {
+    return r
+  }
+
+  editTextFields(&r, func(field string, text string) string {
+    for _, rule := range rules {
+      if rule.re != nil && rule.appliesTo(field) {
+        text = rule.re.ReplaceAllString(text, rule.Replace)
+      }
+    }
+    return text
+  })
+  return r
+}
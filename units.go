@@ -0,0 +1,295 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+const (
+  // UnitsAsIs leaves amounts in whatever units the recipe uses.
+  UnitsAsIs = ""
+  UnitsMetric = "metric"
+  UnitsImperial = "imperial"
+)
+
+// Spoons are used on both sides, so tsp and tbsp are never converted.
+var imperialUnits = map[string]bool{
+  "cup": true, "fl oz": true, "pint": true, "quart": true, "gallon": true, "oz": true, "lb": true,
+}
+
+var metricUnits = map[string]bool{
+  "ml": true, "cl": true, "dl": true, "l": true, "mg": true, "g": true, "kg": true,
+}
+
+// Grams per millilitre of things commonly measured in cups, used to turn cups
+// into grams when converting to metric. Names are matched against the whole
+// ingredient name, so "brown sugar" doesn't count as sugar. Anything else
+// is only weighed when asked to, see Densities.
+var cupDensities = map[string]float64{
+  "flour": 0.53,
+  "all-purpose flour": 0.53,
+  "all purpose flour": 0.53,
+  "sugar": 0.85,
+  "granulated sugar": 0.85,
+  "brown sugar": 0.93,
+  "powdered sugar": 0.51,
+  "icing sugar": 0.51,
+  "butter": 0.96,
+  "rice": 0.78,
+  "rolled oats": 0.38,
+  "oats": 0.38,
+  "cocoa powder": 0.42,
+}
+
+// ConvertIngredientUnits rewrites the amount of an ingredient line into the
+// other system, e.g. "2 cups flour" to "250 g flour" in metric. With weigh,
+// any volume of an ingredient of known density becomes a weight, in grams
+// or, in imperial, ounces.
+func ConvertIngredientUnits(line string, system string, densities Densities, weigh bool) string {
+  if (system == UnitsAsIs && !weigh) || IsSectionHeading(line) {
+    return line
+  }
+  ingredient := ParseIngredient(line)
+  unit := LookupUnit(ingredient.UnitText)
+  if unit == nil || ingredient.Confidence < minRewriteConfidence {
+    return line
+  }
+  before, rest, ok := ingredient.splitAt(ingredient.Quantity())
+  if !ok {
+    return line
+  }
+  density, dense := densities.Lookup(ingredient.Name)
+  weighed := weigh && dense && unit.Kind == UnitVolume
+  // what the recipe gives beats what we'd work out
+  if quantity, ok := preferQuantity(ingredient, system); ok && !weighed {
+    return before + quantity + rest
+  }
+
+  var convert func(Ingredient) string
+  switch {
+  case weighed:
+    convert = func(i Ingredient) string { return weightQuantity(i.Amount*unit.Factor*density, system) }
+  case system == UnitsMetric && imperialUnits[unit.Name]:
+    convert = func(i Ingredient) string { return metricQuantity(i, unit) }
+  case system == UnitsImperial && metricUnits[unit.Name]:
+    convert = func(i Ingredient) string { return imperialQuantity(i, unit) }
+  default:
+    return line
+  }
+
+  quantity := convert(ingredient)
+  if ingredient.AmountMax > 0 {
+    high := ingredient
+    high.Amount = ingredient.AmountMax
+    quantity = joinQuantityRange(quantity, convert(high))
+  }
+  return before + quantity + rest
+}
+
+// joinQuantityRange writes a range of converted quantities, "250-375 g", or
+// "800 g to 1.2 kg" when the ends came out in different units.
+func joinQuantityRange(low string, high string) string {
+  lowSpace, highSpace := strings.LastIndex(low, " "), strings.LastIndex(high, " ")
+  if lowSpace < 0 || highSpace < 0 || strings.TrimSuffix(low[lowSpace+1:], "s") != strings.TrimSuffix(high[highSpace+1:], "s") {
+    return low + " to " + high
+  }
+  return low[:lowSpace] + "-" + high
+}
+
+func metricQuantity(ingredient Ingredient, unit *Unit) string {
+  value := ingredient.Amount * unit.Factor
+  if unit.Kind == UnitVolume {
+    if density, exists := cupDensities[strings.ToLower(ingredient.Name)]; exists && unit.Name == "cup" {
+      return metricAmount(value*density, "g", "kg")
+    }
+    return metricAmount(value, "ml", "l")
+  }
+  return metricAmount(value, "g", "kg")
+}
+
+// metricAmount rounds to a precision a scale or measuring jug can show.
+func metricAmount(value float64, unit string, large string) string {
+  if value >= 1000 {
+    return strconv.FormatFloat(math.Round(value/100)/10, 'f', -1, 64) + " " + large
+  }
+  switch {
+  case value >= 100:
+    value = math.Round(value/5) * 5
+  case value >= 10:
+    value = math.Round(value)
+  default:
+    value = math.Round(value*10) / 10
+  }
+  return strconv.FormatFloat(value, 'f', -1, 64) + " " + unit
+}
+
+func unitSystem(unit *Unit) string {
+  switch {
+  case unit == nil:
+    return UnitsAsIs
+  case metricUnits[unit.Name]:
+    return UnitsMetric
+  case imperialUnits[unit.Name]:
+    return UnitsImperial
+  }
+  return UnitsAsIs
+}
+
+// preferQuantity picks the quantity in the system out of one given both
+// ways, "240 ml" out of "1 cup (240 ml)" in metric.
+func preferQuantity(ingredient Ingredient, system string) (string, bool) {
+  switch {
+  case ingredient.AltText == "" || system == UnitsAsIs:
+    return "", false
+  case unitSystem(LookupUnit(ingredient.AltUnit)) == system:
+    return ingredient.AltText, true
+  case unitSystem(LookupUnit(ingredient.Unit)) == system:
+    return ingredient.AmountText + " " + ingredient.UnitText, true
+  }
+  return "", false
+}
+
+// PreferIngredientUnits keeps only the quantity in the system of a line
+// giving it both ways, "1 cup (240 ml) milk" is "240 ml milk" in metric.
+// Unlike ConvertIngredientUnits nothing is worked out.
+func PreferIngredientUnits(line string, system string) string {
+  if IsSectionHeading(line) {
+    return line
+  }
+  ingredient := ParseIngredient(line)
+  quantity, ok := preferQuantity(ingredient, system)
+  before, rest, found := ingredient.splitAt(ingredient.Quantity())
+  if !ok || !found {
+    return line
+  }
+  return before + quantity + rest
+}
+
+// weightQuantity is a weight in grams, in the system's units.
+func weightQuantity(grams float64, system string) string {
+  if system != UnitsImperial {
+    return metricAmount(grams, "g", "kg")
+  }
+  if grams >= 454 {
+    return imperialAmount(grams/453.592, "lb", "lb")
+  }
+  return imperialAmount(grams/28.3495, "oz", "oz")
+}
+
+func imperialQuantity(ingredient Ingredient, unit *Unit) string {
+  value := ingredient.Amount * unit.Factor
+  if unit.Kind == UnitWeight {
+    return weightQuantity(value, UnitsImperial)
+  }
+  switch {
+  case value < 14:
+    return imperialAmount(value/4.92892, "tsp", "tsp")
+  case value < 59:
+    return imperialAmount(value/14.7868, "tbsp", "tbsp")
+  }
+  return imperialAmount(value/236.588, "cup", "cups")
+}
+
+func imperialAmount(value float64, singular string, plural string) string {
+  amount := formatFraction(value)
+  if value > 1.0625 {
+    return amount + " " + plural
+  }
+  return amount + " " + singular
+}
+
+// Oven temperatures, "350°F", "180 degrees C" (what --spell-degrees makes of
+// the degree sign), "180 Celsius" or "200 C". A bare letter is only taken for
+// a temperature from 100 up, so "12 C sugar" stays cups.
+var temperatureRe = regexp.MustCompile(`\b(\d{2,3})(?:\s*(°|º|degrees?)\s*|\s?)(F|C|Fahrenheit|Celsius)\b`)
+
+type temperature struct {
+  value float64
+  fahrenheit bool
+  // spelled "degrees" rather than with the degree sign
+  degrees bool
+}
+
+func parseTemperature(match []string) (temperature, bool) {
+  value, _ := strconv.ParseFloat(match[1], 64)
+  if match[2] == "" && len(match[3]) == 1 && value < 100 {
+    return temperature{}, false
+  }
+  unit := match[3]
+  return temperature{value, unit == "F" || unit == "Fahrenheit", strings.HasPrefix(match[2], "degree")}, true
+}
+
+// converted is the temperature in the other scale, rounded to the nearest 5
+// degrees the way oven dials are marked.
+func (t temperature) converted() temperature {
+  if t.fahrenheit {
+    return temperature{math.Round((t.value-32)*5/9/5) * 5, false, t.degrees}
+  }
+  return temperature{math.Round((t.value*9/5+32)/5) * 5, true, t.degrees}
+}
+
+func (t temperature) String() string {
+  scale := "C"
+  if t.fahrenheit {
+    scale = "F"
+  }
+  if t.degrees {
+    return fmt.Sprintf("%g degrees %s", t.value, scale)
+  }
+  return fmt.Sprintf("%g°%s", t.value, scale)
+}
+
+// ConvertTemperatures rewrites temperatures in a line into the system's scale.
+func ConvertTemperatures(line string, system string) string {
+  if system == UnitsAsIs {
+    return line
+  }
+  return temperatureRe.ReplaceAllStringFunc(line, func(match string) string {
+    t, ok := parseTemperature(temperatureRe.FindStringSubmatch(match))
+    if !ok || t.fahrenheit == (system == UnitsImperial) {
+      return match
+    }
+    return t.converted().String()
+  })
+}
+
+// AnnotateTemperatures adds each temperature in the other scale after it,
+// "350°F (175°C)", unless the line already gives both scales.
+func AnnotateTemperatures(line string) string {
+  scales := map[bool]bool{}
+  for _, match := range temperatureRe.FindAllStringSubmatch(line, -1) {
+    if t, ok := parseTemperature(match); ok {
+      scales[t.fahrenheit] = true
+    }
+  }
+  if len(scales) != 1 {
+    return line
+  }
+  return temperatureRe.ReplaceAllStringFunc(line, func(match string) string {
+    t, ok := parseTemperature(temperatureRe.FindStringSubmatch(match))
+    if !ok {
+      return match
+    }
+    return match + " (" + t.converted().String() + ")"
+  })
+}
+
+// ConvertUnits converts a recipe's ingredients and the temperatures in its
+// instructions to the metric or imperial system, see ConvertIngredientUnits.
+func ConvertUnits(r Recipe, system string, densities Densities, weigh bool) Recipe {
+  ingredients := make([]string, 0, len(r.IngredientLines))
+  for _, line := range r.IngredientLines {
+    ingredients = append(ingredients, ConvertIngredientUnits(line, system, densities, weigh))
+  }
+  r.IngredientLines = ingredients
+
+  instructions := make([]string, 0, len(r.InstructionLines))
+  for _, line := range r.InstructionLines {
+    instructions = append(instructions, ConvertTemperatures(line, system))
+  }
+  r.InstructionLines = instructions
+  return r
+}
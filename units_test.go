@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestConvertIngredientUnitsWeighing(t *testing.T) {
+  for _, test := range []struct {
+    line string
+    weigh bool
+    want string
+  }{
+    // plain metric only weighs the usual cups of flour and sugar
+    {"2 cups flour", false, "250 g flour"},
+    {"1 cup honey", false, "235 ml honey"},
+    {"1/2 cup salt", false, "120 ml salt"},
+    {"1 cup chocolate chips", false, "235 ml chocolate chips"},
+    {"1 cup honey", true, "235 ml honey"},
+    {"1 cup maple syrup", true, "235 ml maple syrup"},
+    {"1/2 cup salt", true, "145 g salt"},
+    {"1 cup chocolate chips", true, "170 g chocolate chips"},
+  } {
+    if got := ConvertIngredientUnits(test.line, UnitsMetric, nil, test.weigh); got != test.want {
+      t.Errorf("%q weighed %v: got %q, want %q", test.line, test.weigh, got, test.want)
+    }
+  }
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "regexp"
+  "strings"
+)
+
+// Checks files against the structure required by the RecipeMD spec
+// (https://recipemd.org/specification.html): a title, an optional description,
+// then tags and yields, a divider, the ingredient lists, and another divider
+// before the instructions.
+
+type Violation struct {
+  Line int
+  Message string
+}
+
+func (v Violation) String() string {
+  return fmt.Sprintf("%d: %s", v.Line, v.Message)
+}
+
+var yieldAmountRe = regexp.MustCompile(`^(\d+([.,]\d+)?|\d+\s*/\s*\d+|\d+\s+\d+\s*/\s*\d+)(\s|$)`)
+
+func isEmphasis(line string, marker string) bool {
+  return len(line) > 2*len(marker) &&
+    strings.HasPrefix(line, marker) &&
+    strings.HasSuffix(line, marker) &&
+    !strings.HasPrefix(line[len(marker):], "*")
+}
+
+func ValidateRecipeMD(reader io.Reader) ([]Violation, error) {
+  violations := make([]Violation, 0)
+  addViolation := func(line int, format string, args ...interface{}) {
+    violations = append(violations, Violation{line, fmt.Sprintf(format, args...)})
+  }
+
+  section := sectionHeader
+  breaks := 0
+  titleLine := 0
+  tagsLine := 0
+  yieldsLine := 0
+  ingredientCount := 0
+  lineNumber := 0
+  inFrontmatter := false
+
+  scanner := bufio.NewScanner(reader)
+  scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+  for scanner.Scan() {
+    lineNumber++
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" {
+      continue
+    }
+
+    // a YAML (or TOML) front matter block may precede the title
+    if titleLine == 0 && (inFrontmatter || (lineNumber == 1 && (line == "---" || line == "+++"))) {
+      inFrontmatter = lineNumber == 1 || (line != "---" && line != "+++")
+      continue
+    }
+
+    if titleLine == 0 {
+      if !strings.HasPrefix(line, "# ") {
+        addViolation(lineNumber, "first block must be a level 1 heading with the recipe title")
+        titleLine = -1
+      } else {
+        titleLine = lineNumber
+        if strings.TrimSpace(line[2:]) == "" {
+          addViolation(lineNumber, "title is empty")
+        }
+        continue
+      }
+    }
+
+    if breaks < 2 && isThematicBreak(line) {
+      breaks++
+      section++
+      continue
+    }
+
+    switch section {
+    case sectionHeader:
+      switch {
+      case isEmphasis(line, "**"):
+        if yieldsLine != 0 {
+          addViolation(lineNumber, "yields already given on line %d", yieldsLine)
+        }
+        yieldsLine = lineNumber
+        for _, yield := range strings.Split(line[2:len(line)-2], ",") {
+          yield = strings.TrimSpace(yield)
+          if !yieldAmountRe.MatchString(yield) {
+            addViolation(lineNumber, "yield %q does not start with an amount", yield)
+          }
+        }
+      case isEmphasis(line, "*"):
+        if tagsLine != 0 {
+          addViolation(lineNumber, "tags already given on line %d", tagsLine)
+        }
+        if yieldsLine != 0 {
+          addViolation(lineNumber, "tags must come before the yields on line %d", yieldsLine)
+        }
+        tagsLine = lineNumber
+        for _, tag := range strings.Split(line[1:len(line)-1], ",") {
+          if strings.TrimSpace(tag) == "" {
+            addViolation(lineNumber, "empty tag")
+          }
+        }
+      case strings.HasPrefix(line, "#") && !isHashTagLine(line):
+        addViolation(lineNumber, "headings are not allowed before the ingredients divider")
+      default:
+        if tagsLine != 0 || yieldsLine != 0 {
+          addViolation(lineNumber, "description must come before the tags and yields")
+        }
+      }
+    case sectionIngredients:
+      if strings.HasPrefix(line, "#") {
+        continue
+      }
+      item, ok := listItemText(line)
+      if !ok {
+        addViolation(lineNumber, "ingredients section may only contain lists and group headings")
+        continue
+      }
+      ingredientCount++
+      if strings.HasPrefix(item, "*") && strings.Count(item, "*")%2 != 0 {
+        addViolation(lineNumber, "unbalanced emphasis around ingredient amount")
+      }
+    }
+  }
+
+  if err := scanner.Err(); err != nil {
+    return violations, err
+  }
+
+  if titleLine == 0 {
+    addViolation(1, "file is empty")
+  } else if breaks == 0 {
+    addViolation(lineNumber, "missing the divider before the ingredients")
+  } else if ingredientCount == 0 {
+    addViolation(lineNumber, "no ingredients")
+  }
+
+  return violations, nil
+}
+
+func ValidateRecipeMDFile(path string) ([]Violation, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+
+  return ValidateRecipeMD(file)
+}
+
+func runValidate(args []string) error {
+  if len(args) == 0 {
+    return errors.New("usage: recipekeeper2recipemd validate <dir>...")
+  }
+
+  invalid := 0
+  for _, root := range args {
+    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+      if err != nil {
+        return err
+      }
+      if d.IsDir() || filepath.Ext(path) != ".md" || d.Name() == indexFile {
+        return nil
+      }
+
+      violations, err := ValidateRecipeMDFile(path)
+      if err != nil {
+        return err
+      }
+      if len(violations) > 0 {
+        invalid++
+      }
+      for _, violation := range violations {
+        fmt.Printf("%s:%s\n", path, violation)
+      }
+      return nil
+    })
+    if err != nil {
+      return err
+    }
+  }
+
+  if invalid > 0 {
+    return fmt.Errorf("%d file(s) failed validation", invalid)
+  }
+  return nil
+}
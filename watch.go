@@ -0,0 +1,94 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "time"
+)
+
+// exportFile accepts the folder of an unpacked export as well as the
+// recipes.html inside it. Folders without one are left as they are, they may
+// hold an export with one file per recipe (see recipeFiles).
+func exportFile(path string) string {
+  if info, err := os.Stat(path); err == nil && info.IsDir() {
+    if _, err := os.Stat(filepath.Join(path, "recipes.html")); err == nil {
+      return filepath.Join(path, "recipes.html")
+    }
+  }
+  return path
+}
+
+type fileState struct {
+  modTime time.Time
+  size int64
+}
+
+func statFile(path string) (fileState, error) {
+  info, err := os.Stat(path)
+  if err != nil {
+    return fileState{}, err
+  }
+  return fileState{info.ModTime(), info.Size()}, nil
+}
+
+type fileStates []fileState
+
+func statFiles(paths []string) (fileStates, error) {
+  states := make(fileStates, 0, len(paths))
+  for _, path := range paths {
+    state, err := statFile(path)
+    if err != nil {
+      return nil, err
+    }
+    states = append(states, state)
+  }
+  return states, nil
+}
+
+func (s fileStates) equal(other fileStates) bool {
+  if len(s) != len(other) {
+    return false
+  }
+  for i := range s {
+    if s[i] != other[i] {
+      return false
+    }
+  }
+  return true
+}
+
+// WatchFiles calls onChange whenever one of the files changes, and never
+// returns unless the files can't be checked at all. It polls rather than relying on
+// file system events, which synced and network folders often don't deliver,
+// and waits for the file to stop changing so a half written export isn't
+// picked up.
+func WatchFiles(paths []string, interval time.Duration, onChange func()) error {
+  last, err := statFiles(paths)
+  if err != nil {
+    return err
+  }
+
+  for {
+    time.Sleep(interval)
+    current, err := statFiles(paths)
+    if err != nil {
+      // the export is probably being replaced, try again next time
+      continue
+    }
+    if current.equal(last) {
+      continue
+    }
+
+    for {
+      time.Sleep(interval)
+      settled, err := statFiles(paths)
+      if err == nil && settled.equal(current) {
+        break
+      }
+      current = settled
+    }
+
+    last = current
+    onChange()
+  }
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+  "fmt"
+  "os"
+)
+
+// CheckWritable makes sure files can be created in dir, creating it if need
+// be, so a read-only mount or a share that went away fails before any of the
+// extraction work rather than once per recipe.
+func CheckWritable(dir string) error {
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return fmt.Errorf("output directory %s can't be created: %w", dir, err)
+  }
+
+  probe, err := os.CreateTemp(dir, ".writable-*")
+  if err != nil {
+    return fmt.Errorf("output directory %s is not writable: %w", dir, err)
+  }
+  probe.Close()
+  return os.Remove(probe.Name())
+}
+
+// writeFile writes content to path with exactly the given mode. os.WriteFile
+// alone leaves the mode of existing files, and applies the umask to new ones.
+func writeFile(path string, content []byte, mode os.FileMode) error {
+  if err := os.WriteFile(path, content, mode); err != nil {
+    return err
+  }
+  return os.Chmod(path, mode)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+  "strings"
+)
+
+// DefaultYield picks a yield for a recipe that has none, looking it up by
+// course first and then by category, e.g. "cocktail" -> "1 drink". The key "*"
+// applies to everything else.
+func DefaultYield(r Recipe, defaults map[string]string) string {
+  if strings.TrimSpace(r.Metadata.Yield) != "" || len(defaults) == 0 {
+    return r.Metadata.Yield
+  }
+
+  lookup := make(map[string]string, len(defaults))
+  for key, yield := range defaults {
+    lookup[strings.ToLower(strings.TrimSpace(key))] = yield
+  }
+
+  for _, list := range [][]string{r.Metadata.CourseList, r.Metadata.CategoryList} {
+    for _, name := range list {
+      if yield, exists := lookup[strings.ToLower(strings.TrimSpace(name))]; exists {
+        return yield
+      }
+    }
+  }
+
+  return lookup["*"]
+}